@@ -0,0 +1,60 @@
+package sqlq
+
+import (
+	"fmt"
+
+	"github.com/n-r-w/nerr"
+)
+
+// AdvisoryXactLock - wait for the transaction level advisory lock (pg_advisory_xact_lock).
+// The lock is released automatically at the end of the outermost transaction
+func (t *Tx) AdvisoryXactLock(key int64) error {
+	return NewQueryTx(t, t.ctx).ExecArgs("SELECT pg_advisory_xact_lock($1)", key)
+}
+
+// TryAdvisoryXactLock - obtain the transaction level advisory lock without waiting (pg_try_advisory_xact_lock).
+// Returns false if the lock is held by another session
+func (t *Tx) TryAdvisoryXactLock(key int64) (bool, error) {
+	return t.advisoryFunc("pg_try_advisory_xact_lock", key)
+}
+
+// AdvisoryLock - wait for the session level advisory lock (pg_advisory_lock).
+// The lock survives the end of the transaction and must be released with AdvisoryUnlock before it completes,
+// otherwise it stays on the pool connection
+func (t *Tx) AdvisoryLock(key int64) error {
+	return NewQueryTx(t, t.ctx).ExecArgs("SELECT pg_advisory_lock($1)", key)
+}
+
+// TryAdvisoryLock - obtain the session level advisory lock without waiting (pg_try_advisory_lock).
+// Returns false if the lock is held by another session. See AdvisoryLock
+func (t *Tx) TryAdvisoryLock(key int64) (bool, error) {
+	return t.advisoryFunc("pg_try_advisory_lock", key)
+}
+
+// AdvisoryUnlock - release the session level advisory lock (pg_advisory_unlock).
+// Returns false if the lock was not held
+func (t *Tx) AdvisoryUnlock(key int64) (bool, error) {
+	return t.advisoryFunc("pg_advisory_unlock", key)
+}
+
+// advisoryFunc - call the advisory lock function that returns bool
+func (t *Tx) advisoryFunc(name string, key int64) (bool, error) {
+	q := NewQueryTx(t, t.ctx)
+	if err := q.SelectArgs(fmt.Sprintf("SELECT %s($1)", name), key); err != nil {
+		return false, err
+	}
+
+	var ok bool
+	if !q.Next() {
+		if err := q.Close(); err != nil {
+			return false, nerr.New(err)
+		}
+		return false, nerr.New(fmt.Errorf("%s returned no rows", name))
+	}
+	if err := q.Scan(&ok); err != nil {
+		_ = q.Close()
+		return false, err
+	}
+
+	return ok, nerr.New(q.Close())
+}
@@ -0,0 +1,102 @@
+package sqlq
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgtype"
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+// advisoryLocks - handler of the advisory lock functions, the keys of held are held by another session
+type advisoryLocks struct {
+	mu   sync.Mutex
+	held map[int64]bool
+	own  map[int64]bool
+}
+
+func (l *advisoryLocks) handle(sql string, args []any) (sqlqtest.Result, bool) {
+	name := strings.TrimSuffix(strings.TrimPrefix(sql, "SELECT "), "($1)")
+	if name == sql || !strings.HasPrefix(name, "pg_") {
+		return sqlqtest.Result{}, false
+	}
+
+	res := sqlqtest.Result{Params: []uint32{pgtype.Int8OID}, Columns: []sqlqtest.Column{{Name: name, OID: pgtype.BoolOID}}}
+	if name == "pg_advisory_lock" || name == "pg_advisory_xact_lock" {
+		// void
+		res.Columns[0].OID = pgtype.TextOID
+	}
+	if args == nil {
+		return res, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := args[0].(int64)
+	var value any
+	switch name {
+	case "pg_try_advisory_lock", "pg_try_advisory_xact_lock":
+		value = !l.held[key]
+		if !l.held[key] {
+			l.own[key] = true
+		}
+	case "pg_advisory_unlock":
+		value = l.own[key]
+		delete(l.own, key)
+	case "pg_advisory_lock", "pg_advisory_xact_lock":
+		l.own[key] = true
+		value = nil
+	}
+	res.Rows = [][]any{{value}}
+	return res, true
+}
+
+func TestAdvisoryLocks(t *testing.T) {
+	srv, pool := newTestPool(t)
+	locks := &advisoryLocks{held: map[int64]bool{2: true}, own: map[int64]bool{}}
+	srv.Handle(locks.handle)
+
+	err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+		if ok, err := tx.TryAdvisoryXactLock(1); err != nil || !ok {
+			t.Errorf("try lock 1: %v, %v", ok, err)
+		}
+		// held by another session
+		if ok, err := tx.TryAdvisoryLock(2); err != nil || ok {
+			t.Errorf("try lock 2: %v, %v", ok, err)
+		}
+
+		if err := tx.AdvisoryLock(3); err != nil {
+			return err
+		}
+		if ok, err := tx.AdvisoryUnlock(3); err != nil || !ok {
+			t.Errorf("unlock 3: %v, %v", ok, err)
+		}
+		// not held
+		if ok, err := tx.AdvisoryUnlock(3); err != nil || ok {
+			t.Errorf("unlock 3 again: %v, %v", ok, err)
+		}
+		return tx.AdvisoryXactLock(4)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAdvisoryLockNoRows(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect("SELECT pg_try_advisory_lock($1)", sqlqtest.Result{
+		Params:  []uint32{pgtype.Int8OID},
+		Columns: []sqlqtest.Column{{Name: "pg_try_advisory_lock", OID: pgtype.BoolOID}},
+	})
+
+	err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+		_, err := tx.TryAdvisoryLock(1)
+		return err
+	})
+	if err == nil || !strings.Contains(err.Error(), "no rows") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package sqlq
+
+import (
+	"fmt"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/apache/arrow/go/arrow/memory"
+	"github.com/n-r-w/nerr"
+)
+
+// arrowDataType - Arrow data type of the column type
+func arrowDataType(t ArrowType) arrow.DataType {
+	switch t {
+	case ArrowBool:
+		return arrow.FixedWidthTypes.Boolean
+	case ArrowInt16:
+		return arrow.PrimitiveTypes.Int16
+	case ArrowInt32:
+		return arrow.PrimitiveTypes.Int32
+	case ArrowInt64:
+		return arrow.PrimitiveTypes.Int64
+	case ArrowFloat32:
+		return arrow.PrimitiveTypes.Float32
+	case ArrowFloat64:
+		return arrow.PrimitiveTypes.Float64
+	case ArrowBinary:
+		return arrow.BinaryTypes.Binary
+	case ArrowDate32:
+		return arrow.FixedWidthTypes.Date32
+	case ArrowTimestamp:
+		return arrow.FixedWidthTypes.Timestamp_us
+	default:
+		return arrow.BinaryTypes.String
+	}
+}
+
+// ArrowSchema - Arrow schema of the columns. All fields are nullable
+func ArrowSchema(schema []ColumnSchema) *arrow.Schema {
+	fields := make([]arrow.Field, len(schema))
+	for i, c := range schema {
+		fields[i] = arrow.Field{Name: c.Name, Type: arrowDataType(c.Type), Nullable: true}
+	}
+	return arrow.NewSchema(fields, nil)
+}
+
+// ArrowRecord - the batch as an Arrow record allocated with mem, memory.DefaultAllocator if nil.
+// The record must be released by the caller
+func (b *ColumnBatch) ArrowRecord(mem memory.Allocator) (array.Record, error) {
+	if mem == nil {
+		mem = memory.DefaultAllocator
+	}
+
+	rb := array.NewRecordBuilder(mem, ArrowSchema(b.Schema))
+	defer rb.Release()
+
+	for i := range b.Schema {
+		valid := b.Valid[i]
+
+		switch col := b.Values[i].(type) {
+		case []bool:
+			rb.Field(i).(*array.BooleanBuilder).AppendValues(col, valid)
+		case []int16:
+			rb.Field(i).(*array.Int16Builder).AppendValues(col, valid)
+		case []int32:
+			if fb, ok := rb.Field(i).(*array.Date32Builder); ok {
+				days := make([]arrow.Date32, len(col))
+				for j, v := range col {
+					days[j] = arrow.Date32(v)
+				}
+				fb.AppendValues(days, valid)
+			} else {
+				rb.Field(i).(*array.Int32Builder).AppendValues(col, valid)
+			}
+		case []int64:
+			if fb, ok := rb.Field(i).(*array.TimestampBuilder); ok {
+				ts := make([]arrow.Timestamp, len(col))
+				for j, v := range col {
+					ts[j] = arrow.Timestamp(v)
+				}
+				fb.AppendValues(ts, valid)
+			} else {
+				rb.Field(i).(*array.Int64Builder).AppendValues(col, valid)
+			}
+		case []float32:
+			rb.Field(i).(*array.Float32Builder).AppendValues(col, valid)
+		case []float64:
+			rb.Field(i).(*array.Float64Builder).AppendValues(col, valid)
+		case [][]byte:
+			rb.Field(i).(*array.BinaryBuilder).AppendValues(col, valid)
+		case []string:
+			rb.Field(i).(*array.StringBuilder).AppendValues(col, valid)
+		default:
+			return nil, nerr.New(fmt.Errorf("column %s: unsupported values %T", b.Schema[i].Name, col))
+		}
+	}
+
+	return rb.NewRecord(), nil
+}
+
+// ArrowRecordWriter - ColumnBatchWriter that passes the batches to the function as Arrow records.
+// The record is released after the function returns, call Retain to keep it
+type ArrowRecordWriter func(rec array.Record) error
+
+// WriteBatch - ColumnBatchWriter
+func (f ArrowRecordWriter) WriteBatch(batch *ColumnBatch) error {
+	rec, err := batch.ArrowRecord(nil)
+	if err != nil {
+		return err
+	}
+	defer rec.Release()
+
+	return f(rec)
+}
+
+// WriteArrowRecords - read the remaining rows of the select and pass them to f as Arrow records of batchSize rows.
+// The query is closed
+func (q *Query) WriteArrowRecords(batchSize int, f func(rec array.Record) error) error {
+	return q.WriteColumnBatches(ArrowRecordWriter(f), batchSize)
+}
@@ -0,0 +1,69 @@
+package sqlq
+
+import (
+	"testing"
+
+	"github.com/apache/arrow/go/arrow"
+	"github.com/apache/arrow/go/arrow/array"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgtype"
+)
+
+func TestArrowRecord(t *testing.T) {
+	fields := []pgproto3.FieldDescription{
+		{Name: []byte("id"), DataTypeOID: pgtype.Int8OID},
+		{Name: []byte("rel"), DataTypeOID: pgtype.OIDOID},
+		{Name: []byte("name"), DataTypeOID: pgtype.TextOID},
+	}
+	batch := newColumnBatch(ColumnSchemaOf(fields), 2)
+	// oid above math.MaxInt32
+	for _, row := range [][]any{{int64(1), uint32(4000000000), "a"}, {int64(2), nil, nil}} {
+		for i, v := range row {
+			if err := batch.append(i, v, fields[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		batch.Rows++
+	}
+
+	rec, err := batch.ArrowRecord(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Release()
+
+	if rec.NumRows() != 2 || rec.NumCols() != 3 {
+		t.Fatalf("got %dx%d record", rec.NumRows(), rec.NumCols())
+	}
+	if typ := rec.Schema().Field(1).Type; typ != arrow.PrimitiveTypes.Int64 {
+		t.Errorf("oid type: got %v, want int64", typ)
+	}
+
+	rel := rec.Column(1).(*array.Int64)
+	if rel.Value(0) != 4000000000 || !rel.IsNull(1) {
+		t.Errorf("oid column: got %v", rel)
+	}
+	name := rec.Column(2).(*array.String)
+	if name.Value(0) != "a" || !name.IsNull(1) {
+		t.Errorf("name column: got %v", name)
+	}
+}
+
+func TestWriteArrowRecords(t *testing.T) {
+	q := FromPgxRows(newCachedRows(scanUserRows(5)))
+
+	var rows []int64
+	err := q.WriteArrowRecords(2, func(rec array.Record) error {
+		rows = append(rows, rec.NumRows())
+		if typ := rec.Schema().Field(3).Type; typ != arrow.FixedWidthTypes.Timestamp_us {
+			t.Errorf("created_at type: got %v", typ)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[0] != 2 || rows[2] != 1 {
+		t.Errorf("record sizes: got %v", rows)
+	}
+}
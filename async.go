@@ -0,0 +1,168 @@
+package sqlq
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/n-r-w/nerr"
+)
+
+// AsyncExecutor - executes queries in background goroutines with a concurrency limit
+type AsyncExecutor struct {
+	pool *pgxpool.Pool
+	sem  chan struct{}
+}
+
+// Future - result of an asynchronous query
+type Future struct {
+	done   chan struct{}
+	cancel context.CancelFunc
+
+	q   *Query
+	err error
+}
+
+// NewAsyncExecutor - create an executor that runs at most maxConcurrency queries at the same time
+func NewAsyncExecutor(pool *pgxpool.Pool, maxConcurrency int) *AsyncExecutor {
+	if maxConcurrency <= 0 {
+		maxConcurrency = 1
+	}
+
+	return &AsyncExecutor{
+		pool: pool,
+		sem:  make(chan struct{}, maxConcurrency),
+	}
+}
+
+// SelectAsync - start the select in the background. The rows are read into memory, so the connection is
+// returned to the pool as soon as the query is completed
+func (e *AsyncExecutor) SelectAsync(ctx context.Context, sql string) *Future {
+	return e.start(ctx, func(runCtx context.Context) (*Query, error) {
+		rows, err := NewQuery(e.pool, runCtx).querySql(runCtx, sql)
+		if err != nil {
+			return nil, nerr.New(err)
+		}
+
+		res, err := readCachedResult(rows)
+		if err != nil {
+			return nil, nerr.New(err)
+		}
+
+		q := NewQuery(e.pool, ctx)
+		q.setRows(newCachedRows(res))
+		return q, nil
+	})
+}
+
+// ExecAsync - start the insert, update, delete command in the background
+func (e *AsyncExecutor) ExecAsync(ctx context.Context, sql string) *Future {
+	return e.start(ctx, func(runCtx context.Context) (*Query, error) {
+		q := NewQuery(e.pool, runCtx)
+		if err := q.Exec(sql); err != nil {
+			return nil, err
+		}
+
+		q.ctx = ctx
+		return q, nil
+	})
+}
+
+func (e *AsyncExecutor) start(ctx context.Context, fn func(runCtx context.Context) (*Query, error)) *Future {
+	runCtx, cancel := context.WithCancel(ctx)
+	f := &Future{
+		done:   make(chan struct{}),
+		cancel: cancel,
+	}
+
+	go func() {
+		defer close(f.done)
+		defer cancel()
+
+		select {
+		case e.sem <- struct{}{}:
+		case <-runCtx.Done():
+			f.err = nerr.New(runCtx.Err())
+			return
+		}
+		defer func() { <-e.sem }()
+
+		f.q, f.err = fn(runCtx)
+	}()
+
+	return f
+}
+
+// Wait - wait for the query to complete
+func (f *Future) Wait() (*Query, error) {
+	<-f.done
+	return f.q, f.err
+}
+
+// Done - closed when the query is completed
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Cancel - cancel the query. Wait returns the cancellation error if the query has not completed yet
+func (f *Future) Cancel() {
+	f.cancel()
+}
+
+// ParallelError - errors of the failed queries of Parallel
+type ParallelError struct {
+	// Errors - error of each query, nil for the successful ones
+	Errors []error
+}
+
+func (e *ParallelError) Error() string {
+	var b strings.Builder
+	failed := 0
+	for i, err := range e.Errors {
+		if err == nil {
+			continue
+		}
+		if failed > 0 {
+			b.WriteString("; ")
+		}
+		fmt.Fprintf(&b, "query %d: %v", i, err)
+		failed++
+	}
+	return fmt.Sprintf("%d of %d queries failed: %s", failed, len(e.Errors), b.String())
+}
+
+// Unwrap - error of the first failed query
+func (e *ParallelError) Unwrap() error {
+	for _, err := range e.Errors {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Parallel - execute independent selects concurrently on separate pool connections, at most maxConcurrency
+// at the same time. The rows are read into memory. Returns the result of each query in the order of the queries,
+// nil for the failed ones, and ParallelError if any query failed
+func Parallel(pool *pgxpool.Pool, ctx context.Context, queries []string, maxConcurrency int) ([]*Query, error) {
+	e := NewAsyncExecutor(pool, maxConcurrency)
+
+	futures := make([]*Future, len(queries))
+	for i, sql := range queries {
+		futures[i] = e.SelectAsync(ctx, sql)
+	}
+
+	results := make([]*Query, len(queries))
+	errs := make([]error, len(queries))
+	failed := false
+	for i, f := range futures {
+		results[i], errs[i] = f.Wait()
+		failed = failed || errs[i] != nil
+	}
+
+	if failed {
+		return results, nerr.New(&ParallelError{Errors: errs})
+	}
+	return results, nil
+}
@@ -0,0 +1,115 @@
+package sqlq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+// concurrentStatement - handler of the statement that tracks the number of concurrent executions
+type concurrentStatement struct {
+	sql     string
+	release chan struct{}
+
+	mu      sync.Mutex
+	running int
+	max     int
+}
+
+func (s *concurrentStatement) handle(sql string, args []any) (sqlqtest.Result, bool) {
+	if sql != s.sql {
+		return sqlqtest.Result{}, false
+	}
+
+	s.mu.Lock()
+	s.running++
+	if s.running > s.max {
+		s.max = s.running
+	}
+	s.mu.Unlock()
+
+	if s.release != nil {
+		<-s.release
+	} else {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s.mu.Lock()
+	s.running--
+	s.mu.Unlock()
+	return sqlqtest.Result{Columns: []sqlqtest.Column{{Name: "a"}}, Rows: [][]any{{1}}}, true
+}
+
+func (s *concurrentStatement) maxRunning() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.max
+}
+
+func TestParallel(t *testing.T) {
+	srv, pool := newTestPool(t)
+	stmt := &concurrentStatement{sql: "SELECT 1 AS a"}
+	srv.Handle(stmt.handle)
+	srv.ExpectError("SELECT 2 AS a", "42P01", "relation does not exist")
+
+	queries := []string{"SELECT 1 AS a", "SELECT 1 AS a", "SELECT 2 AS a", "SELECT 1 AS a", "SELECT 1 AS a"}
+	results, err := Parallel(pool, context.Background(), queries, 2)
+
+	var parallelErr *ParallelError
+	if !errors.As(err, &parallelErr) || SqlState(err) != "42P01" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, r := range results {
+		if i == 2 {
+			if r != nil || parallelErr.Errors[i] == nil {
+				t.Errorf("query %d: result %v, error %v", i, r, parallelErr.Errors[i])
+			}
+			continue
+		}
+		if parallelErr.Errors[i] != nil || !r.Next() || r.ValueIndex(0) != int64(1) {
+			t.Errorf("query %d: unexpected result, error %v", i, parallelErr.Errors[i])
+		}
+	}
+
+	if n := stmt.maxRunning(); n > 2 {
+		t.Errorf("%d queries at the same time, limit 2", n)
+	}
+}
+
+func TestFutureCancelWaiting(t *testing.T) {
+	srv, pool := newTestPool(t)
+	stmt := &concurrentStatement{sql: "SELECT 1 AS a", release: make(chan struct{})}
+	srv.Handle(stmt.handle)
+	srv.Expect("UPDATE t SET a = 1", sqlqtest.Result{Tag: "UPDATE 3"})
+
+	e := NewAsyncExecutor(pool, 1)
+	running := e.SelectAsync(context.Background(), "SELECT 1 AS a")
+	for stmt.maxRunning() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	waiting := e.ExecAsync(context.Background(), "UPDATE t SET a = 1")
+
+	// the second query waits for the first one
+	waiting.Cancel()
+	if _, err := waiting.Wait(); !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	close(stmt.release)
+	if _, err := running.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	q, err := e.ExecAsync(context.Background(), "UPDATE t SET a = 1").Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.RowsAffected() != 3 {
+		t.Errorf("rows affected %d, want 3", q.RowsAffected())
+	}
+}
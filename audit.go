@@ -0,0 +1,210 @@
+package sqlq
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// AuditRecord - data-modifying statement recorded by the audit
+type AuditRecord struct {
+	// SqlHash - sha256 of the fingerprint of the statement, see Fingerprint
+	SqlHash string
+	// Fingerprint - statement text without the values
+	Fingerprint string
+	// Actor - user of the statement, see WithAuditActor
+	Actor        string
+	RowsAffected int64
+	Time         time.Time
+}
+
+// AuditSink - receiver of the audit records. q - query of the transaction of the audited statement
+// (or of the pool outside of a transaction), its statements are not audited.
+// An error of the sink is returned by the audited Exec, in a transaction it should be rolled back
+type AuditSink interface {
+	WriteAudit(q *Query, rec AuditRecord) error
+}
+
+// AuditSinkFunc - function implementation of AuditSink
+type AuditSinkFunc func(q *Query, rec AuditRecord) error
+
+func (f AuditSinkFunc) WriteAudit(q *Query, rec AuditRecord) error {
+	return f(q, rec)
+}
+
+type auditActorKey struct{}
+
+// WithAuditActor - user recorded by the audit for the statements executed with the context
+func WithAuditActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+// AuditActor - user of the context, see WithAuditActor
+func AuditActor(ctx context.Context) string {
+	actor, _ := ctx.Value(auditActorKey{}).(string)
+	return actor
+}
+
+var (
+	globalAuditSinkMu sync.RWMutex
+	globalAuditSink   AuditSink
+)
+
+// SetAuditSink - audit of the data-modifying statements of all queries: insert, update, delete commands executed
+// with Exec, batches (SendBatch, ExecBindMany, Pipeline), CopyFrom and Large Object writes. nil - disable.
+// Selects (INSERT ... RETURNING with Select) are not audited.
+// The record is written after the statement. In a transaction it is committed or rolled back with the statement,
+// outside of a transaction the statement is already committed and is not undone if the sink fails:
+// use a transaction when the record and the change must be atomic
+func SetAuditSink(sink AuditSink) {
+	globalAuditSinkMu.Lock()
+	defer globalAuditSinkMu.Unlock()
+	globalAuditSink = sink
+}
+
+// SetAuditSink - audit of the statements of the transaction instead of the sink set with the package SetAuditSink
+func (t *Tx) SetAuditSink(sink AuditSink) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.auditSink = sink
+}
+
+// auditSink - audit receiver of the query, nil if not set
+func (q *Query) auditSink() AuditSink {
+	if q.noAudit {
+		return nil
+	}
+
+	if q.tx != nil {
+		q.tx.mu.Lock()
+		sink := q.tx.auditSink
+		q.tx.mu.Unlock()
+		if sink != nil {
+			return sink
+		}
+	}
+
+	globalAuditSinkMu.RLock()
+	defer globalAuditSinkMu.RUnlock()
+	return globalAuditSink
+}
+
+// audit - record the executed statement if it modified data
+func (q *Query) audit(ctx context.Context, sql string, tag pgconn.CommandTag) error {
+	if !tag.Insert() && !tag.Update() && !tag.Delete() {
+		return nil
+	}
+	return q.auditWrite(ctx, sql, tag.RowsAffected())
+}
+
+// auditWrite - record the data modification: the statement, COPY or Large Object write
+func (q *Query) auditWrite(ctx context.Context, sql string, rows int64) error {
+	sink := q.auditSink()
+	if sink == nil {
+		return nil
+	}
+
+	fp := Fingerprint(sql)
+	hash := sha256.Sum256([]byte(fp))
+
+	var aq *Query
+	if q.tx != nil {
+		aq = NewQueryTx(q.tx, ctx)
+	} else {
+		aq = NewQuery(q.pool, ctx)
+	}
+	aq.noAudit = true
+
+	return sink.WriteAudit(aq, AuditRecord{
+		SqlHash:      hex.EncodeToString(hash[:]),
+		Fingerprint:  fp,
+		Actor:        AuditActor(ctx),
+		RowsAffected: rows,
+		Time:         time.Now(),
+	})
+}
+
+// batchWrite - executed command of the batch
+type batchWrite struct {
+	sql string
+	tag pgconn.CommandTag
+}
+
+// auditBatch - record the commands of the successfully executed batch
+func (q *Query) auditBatch(writes []batchWrite) error {
+	for _, w := range writes {
+		if err := q.audit(q.ctx, w.sql, w.tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// auditBatchResults - results of the batch that collect the executed commands for the audit
+type auditBatchResults struct {
+	pgx.BatchResults
+	sqls   []string
+	next   int
+	writes []batchWrite
+}
+
+func (r *auditBatchResults) Exec() (pgconn.CommandTag, error) {
+	sql := r.sqls[r.next]
+	r.next++
+
+	tag, err := r.BatchResults.Exec()
+	if err == nil {
+		r.writes = append(r.writes, batchWrite{sql: sql, tag: tag})
+	}
+	return tag, err
+}
+
+func (r *auditBatchResults) Query() (pgx.Rows, error) {
+	r.next++
+	return r.BatchResults.Query()
+}
+
+func (r *auditBatchResults) QueryRow() pgx.Row {
+	r.next++
+	return r.BatchResults.QueryRow()
+}
+
+// auditTx - record the data modification of the transaction made outside of the statements of the queries:
+// COPY FROM and Large Object functions. Must be called after the transaction is released
+func auditTx(tx *Tx, sql string, rows int64) error {
+	return NewQueryTx(tx, tx.ctx).auditWrite(tx.ctx, sql, rows)
+}
+
+// AuditTable - AuditSink that inserts the records into the table
+type AuditTable struct {
+	table string
+}
+
+// NewAuditTable - create the sink. table - audit table, may be schema-qualified
+func NewAuditTable(table string) *AuditTable {
+	return &AuditTable{table: table}
+}
+
+// CreateTable - create the audit table if it doesn't exist
+func (a *AuditTable) CreateTable(q *Query) error {
+	return q.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id bigserial PRIMARY KEY,
+	sql_hash text NOT NULL,
+	fingerprint text NOT NULL,
+	actor text NOT NULL,
+	rows_affected bigint NOT NULL,
+	created_at timestamptz NOT NULL
+)`, quoteName(a.table)))
+}
+
+// WriteAudit - implementation of AuditSink
+func (a *AuditTable) WriteAudit(q *Query, rec AuditRecord) error {
+	return q.ExecArgs(fmt.Sprintf("INSERT INTO %s (sql_hash, fingerprint, actor, rows_affected, created_at) VALUES ($1, $2, $3, $4, $5)",
+		quoteName(a.table)), rec.SqlHash, rec.Fingerprint, rec.Actor, rec.RowsAffected, rec.Time)
+}
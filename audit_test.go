@@ -0,0 +1,82 @@
+package sqlq
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+// recordingSink - AuditSink that keeps the records in memory
+type recordingSink struct {
+	mu      sync.Mutex
+	records []AuditRecord
+}
+
+func (s *recordingSink) WriteAudit(q *Query, rec AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, rec)
+	return nil
+}
+
+func (s *recordingSink) fingerprints() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := make([]string, len(s.records))
+	for i, r := range s.records {
+		res[i] = r.Fingerprint
+	}
+	return res
+}
+
+func TestAuditBatches(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect("INSERT INTO t (a) VALUES (1)", sqlqtest.Result{Tag: "INSERT 0 1"})
+	srv.Expect("UPDATE t SET a = 2", sqlqtest.Result{Tag: "UPDATE 3"})
+	srv.Expect("SELECT 1 AS a", sqlqtest.Result{Columns: []sqlqtest.Column{{Name: "a"}}, Rows: [][]any{{1}}})
+	srv.ExpectError("DELETE FROM t", "23503", "foreign key violation")
+
+	sink := &recordingSink{}
+	SetAuditSink(sink)
+	t.Cleanup(func() { SetAuditSink(nil) })
+
+	ctx := context.Background()
+
+	b := NewBatch()
+	b.Exec("INSERT INTO t (a) VALUES (1)")
+	b.Select("SELECT 1 AS a")
+	b.Exec("UPDATE t SET a = 2")
+	if _, err := SendBatch(pool, ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"INSERT INTO t (a) VALUES (?)", "UPDATE t SET a = ?"}
+	if got := sink.fingerprints(); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("SendBatch: got %q, want %q", got, want)
+	}
+	if sink.records[1].RowsAffected != 3 {
+		t.Errorf("rows affected: %d", sink.records[1].RowsAffected)
+	}
+
+	sink.records = nil
+	if _, err := ExecBindMany(pool, ctx, "INSERT INTO t (a) VALUES (:a)", []map[string]any{{"a": 1}}, ":"); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.fingerprints(); len(got) != 1 || got[0] != want[0] {
+		t.Fatalf("ExecBindMany: got %q", got)
+	}
+
+	// the failed batch is rolled back, nothing is recorded
+	sink.records = nil
+	b = NewBatch()
+	b.Exec("INSERT INTO t (a) VALUES (1)")
+	b.Exec("DELETE FROM t")
+	if _, err := SendBatch(pool, ctx, b); err == nil {
+		t.Fatal("expected error")
+	}
+	if got := sink.fingerprints(); len(got) != 0 {
+		t.Fatalf("failed batch: got %q", got)
+	}
+}
@@ -0,0 +1,181 @@
+package sqlq
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/n-r-w/nerr"
+)
+
+// Batch - Exec and Select statements that are sent to the server in one round trip, see Query.SendBatch
+type Batch struct {
+	items []batchItem
+}
+
+type batchItem struct {
+	sql  string
+	args []any
+	// bind template, the sql is bound with the binder of the query when the batch is sent
+	values map[string]any
+	key    string
+	bind   bool
+
+	isSelect bool
+}
+
+// BatchResult - result of a statement of the batch
+type BatchResult struct {
+	RowsAffected int64
+	// Rows - rows of the Select read into memory, nil for Exec
+	Rows *Query
+	Err  error
+}
+
+// NewBatch - create an empty batch
+func NewBatch() *Batch {
+	return &Batch{}
+}
+
+// Len - number of statements in the batch
+func (b *Batch) Len() int {
+	return len(b.items)
+}
+
+// Exec - add the insert, update, delete command with $1, $2... parameters. Returns the index of the result
+func (b *Batch) Exec(sql string, args ...any) int {
+	b.items = append(b.items, batchItem{sql: sql, args: args})
+	return len(b.items) - 1
+}
+
+// ExecBind - add the command with the substitution of values in the template. Returns the index of the result
+func (b *Batch) ExecBind(sqlTemplate string, values map[string]any, key string) int {
+	b.items = append(b.items, batchItem{sql: sqlTemplate, values: values, key: key, bind: true})
+	return len(b.items) - 1
+}
+
+// Select - add the select with $1, $2... parameters. Returns the index of the result
+func (b *Batch) Select(sql string, args ...any) int {
+	b.items = append(b.items, batchItem{sql: sql, args: args, isSelect: true})
+	return len(b.items) - 1
+}
+
+// SelectBind - add the select with the substitution of values in the template. Returns the index of the result
+func (b *Batch) SelectBind(sqlTemplate string, values map[string]any, key string) int {
+	b.items = append(b.items, batchItem{sql: sqlTemplate, values: values, key: key, bind: true, isSelect: true})
+	return len(b.items) - 1
+}
+
+// SendBatch - send the statements of the batch in one round trip. The rows of the selects are read into memory.
+// Outside of a transaction the batch is executed as one implicit transaction: after the first failed statement
+// the rest are not applied and get errors too, the preceding ones are rolled back and get "rolled back" errors.
+// Statements that fail to bind are not sent.
+// Returns the result of each statement and the first error
+func (q *Query) SendBatch(b *Batch) ([]BatchResult, error) {
+	q.rows = nil
+	q.lastValues = nil
+	q.lastDescriptions = nil
+	q.resetFields()
+
+	results := make([]BatchResult, len(b.items))
+	batch := &pgx.Batch{}
+	queued := make([]int, 0, len(b.items))
+	sqls := make([]string, 0, len(b.items))
+	hasExec := false
+
+	for i, item := range b.items {
+		var (
+			sql  string
+			args []any
+			err  error
+		)
+		if item.bind {
+			sql, args, err = bindSql(q.Binder(), item.sql, item.values, item.key)
+		} else {
+			sql, args, err = exprArgs(item.sql, item.args)
+		}
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		sqls = append(sqls, sql)
+		batch.Queue(sql, args...)
+		queued = append(queued, i)
+		hasExec = hasExec || !item.isSelect
+	}
+
+	if cache := requestCacheFromContext(q.ctx); cache != nil && hasExec {
+		cache.clear()
+	}
+
+	if batch.Len() > 0 {
+		var batchErr error
+		writes, err := q.sendBatch(batch, sqls, func(br pgx.BatchResults) {
+			for _, i := range queued {
+				if b.items[i].isSelect {
+					results[i] = q.readBatchSelect(br)
+				} else {
+					tag, err := br.Exec()
+					results[i] = BatchResult{RowsAffected: tag.RowsAffected(), Err: err}
+				}
+				if results[i].Err != nil && batchErr == nil {
+					batchErr = results[i].Err
+				}
+			}
+		})
+		if batchErr == nil {
+			batchErr = err
+		}
+
+		// outside of a transaction the successful statements are rolled back with the implicit transaction
+		if batchErr != nil && (q.tx == nil || err != nil) {
+			for _, i := range queued {
+				if results[i].Err == nil {
+					results[i] = BatchResult{Err: fmt.Errorf("rolled back: %w", batchErr)}
+				}
+			}
+		}
+
+		if batchErr == nil {
+			if err := q.auditBatch(writes); err != nil {
+				return results, err
+			}
+		}
+	}
+
+	for i, r := range results {
+		if r.Err != nil {
+			return results, nerr.New(fmt.Errorf("statement %d: %w", i, r.Err))
+		}
+	}
+	return results, nil
+}
+
+// readBatchSelect - read the rows of the select of the batch into memory
+func (q *Query) readBatchSelect(br pgx.BatchResults) BatchResult {
+	rows, err := br.Query()
+	if err != nil {
+		return BatchResult{Err: err}
+	}
+
+	res, err := readCachedResult(rows)
+	if err != nil {
+		return BatchResult{Err: err}
+	}
+
+	rq := FromPgxRows(newCachedRows(res))
+	rq.ctx = q.ctx
+	rq.mapper = q.mapper
+	rq.strictMapping = q.strictMapping
+	return BatchResult{RowsAffected: res.tag.RowsAffected(), Rows: rq}
+}
+
+func SendBatch(pool *pgxpool.Pool, ctx context.Context, b *Batch) ([]BatchResult, error) {
+	return NewQuery(pool, ctx).SendBatch(b)
+}
+
+func SendBatchTx(tx *Tx, b *Batch) ([]BatchResult, error) {
+	return NewQueryTx(tx, tx.ctx).SendBatch(b)
+}
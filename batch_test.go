@@ -0,0 +1,73 @@
+package sqlq
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+func TestSendBatch(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect("UPDATE t SET a = $1", sqlqtest.Result{Tag: "UPDATE 2"})
+	srv.Expect("SELECT id FROM t WHERE a = 1", sqlqtest.Result{
+		Columns: []sqlqtest.Column{{Name: "id"}},
+		Rows:    [][]any{{1}, {2}},
+	})
+
+	b := NewBatch()
+	update := b.Exec("UPDATE t SET a = $1", 1)
+	sel := b.SelectBind("SELECT id FROM t WHERE a = :a", map[string]any{"a": 1}, ":")
+
+	results, err := SendBatch(pool, context.Background(), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if results[update].RowsAffected != 2 || results[update].Rows != nil {
+		t.Errorf("update result %+v", results[update])
+	}
+
+	rows := results[sel].Rows
+	var ids []int64
+	for rows.Next() {
+		ids = append(ids, rows.ValueIndex(0).(int64))
+	}
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Errorf("ids %v", ids)
+	}
+}
+
+func TestSendBatchErrors(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect("UPDATE t SET a = 1", sqlqtest.Result{Tag: "UPDATE 1"})
+	srv.ExpectError("UPDATE t SET b = 1", "23505", "duplicate key value")
+
+	b := NewBatch()
+	ok := b.Exec("UPDATE t SET a = 1")
+	unbound := b.Exec("UPDATE t SET c = $1", Expr{Sql: "f(?, ?)", Args: []any{1}})
+	failed := b.Exec("UPDATE t SET b = 1")
+
+	results, err := SendBatch(pool, context.Background(), b)
+	if SqlState(err) != "23505" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the implicit transaction of the batch is rolled back
+	if e := results[ok].Err; e == nil || !strings.HasPrefix(e.Error(), "rolled back") {
+		t.Errorf("statement %d: unexpected error: %v", ok, e)
+	}
+	if results[unbound].Err == nil {
+		t.Errorf("statement %d: expected bind error", unbound)
+	}
+	if SqlState(results[failed].Err) != "23505" {
+		t.Errorf("statement %d: unexpected error: %v", failed, results[failed].Err)
+	}
+
+	for _, sql := range srv.Queries() {
+		if strings.Contains(sql, "SET c") {
+			t.Errorf("statement that failed to bind is sent: %s", sql)
+		}
+	}
+}
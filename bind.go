@@ -0,0 +1,315 @@
+package sqlq
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/n-r-w/sqlb"
+)
+
+// Binder - substitution of values into sql templates: key + name placeholders are replaced with sql literals.
+// Optional sections, slices and Ident values are processed by sqlq. The statement is scanned by sqlq,
+// the binder is called for each value with the template of its placeholder only (":name" for key ":"),
+// so the substituted literals are never treated as placeholders
+type Binder interface {
+	Bind(template string, values map[string]any, key string) (string, error)
+}
+
+// BinderFunc - function implementation of Binder
+type BinderFunc func(template string, values map[string]any, key string) (string, error)
+
+// Bind - implementation of Binder
+func (f BinderFunc) Bind(template string, values map[string]any, key string) (string, error) {
+	return f(template, values, key)
+}
+
+// DefaultBinder - binder based on github.com/n-r-w/sqlb
+type DefaultBinder struct{}
+
+// Bind - implementation of Binder
+func (DefaultBinder) Bind(template string, values map[string]any, key string) (string, error) {
+	return sqlb.Bind(template, values, key)
+}
+
+var defaultBinder Binder = DefaultBinder{}
+
+// SetDefaultBinder - binder used by queries without their own binder and by the package functions.
+// Must be called at initialization, before queries are executed
+func SetDefaultBinder(binder Binder) {
+	if binder == nil {
+		binder = DefaultBinder{}
+	}
+	defaultBinder = binder
+}
+
+var (
+	// placeholder inside IN (...)
+	inListRe = regexp.MustCompile(`(?i)\bin\s*\(\s*$`)
+	// placeholder right after IN
+	inRe = regexp.MustCompile(`(?i)\bin\s*$`)
+)
+
+// bindSql - substitute the values into the template.
+// Optional sections [[ ... ]] are included only if all their placeholders have not nil values:
+// "SELECT * FROM t WHERE true [[AND status = :status]]".
+// Slice values used in IN are expanded into lists of literals: "id IN (:ids)" or "id IN :ids" -> "id IN (1, 2, 3)".
+// An empty slice is expanded into NULL, which matches no rows.
+// In other places slices are substituted as arrays: "id = ANY(:ids)" -> "id = ANY(ARRAY[1, 2, 3])", empty - '{}'.
+// Ident values are substituted as quoted identifiers. Expr values are substituted as expressions with $n parameters,
+// their arguments are returned. The remaining values are substituted by the binder
+func bindSql(binder Binder, template string, values map[string]any, key string) (string, []any, error) {
+	template, err := expandSections(template, map[string]map[string]any{key: values}, []string{key})
+	if err != nil {
+		return "", nil, err
+	}
+
+	return bindValues(binder, template, values, key)
+}
+
+// bindValues - substitute the values into the template without optional sections processing
+func bindValues(binder Binder, template string, values map[string]any, key string) (string, []any, error) {
+	return bindPlaceholders(binder, template, map[string]map[string]any{key: values}, []string{key})
+}
+
+// bindSqlKeys - substitute several placeholder groups with their own keys in one pass, so values substituted
+// for one key are never treated as placeholders of another one
+func bindSqlKeys(binder Binder, template string, values map[string]map[string]any) (string, []any, error) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		if key == "" {
+			return "", nil, fmt.Errorf("empty placeholder key")
+		}
+		keys = append(keys, key)
+	}
+	// longer keys first: ":f:" must be matched before ":"
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+
+	template, err := expandSections(template, values, keys)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return bindPlaceholders(binder, template, values, keys)
+}
+
+// bindPlaceholders - replace the placeholders of the keys (longer first) with the values in a single pass over the
+// template. Placeholders inside string literals, quoted identifiers and comments are not replaced, substituted values
+// are never scanned again. Placeholders without values are left as is. Returns the arguments of the Expr values
+func bindPlaceholders(binder Binder, template string, values map[string]map[string]any, keys []string) (string, []any, error) {
+	var (
+		b        strings.Builder
+		rendered = map[string]string{}
+		args     []any
+	)
+
+	for i := 0; i < len(template); {
+		end, err := skipSqlToken(template, i)
+		if err != nil {
+			return "", nil, err
+		}
+		if end > i {
+			b.WriteString(template[i:end])
+			i = end
+			continue
+		}
+
+		key := placeholderKey(template[i:], keys)
+		if key == "" {
+			b.WriteByte(template[i])
+			i++
+			continue
+		}
+
+		start := i + len(key)
+		// type cast for ":"
+		if strings.HasPrefix(template[start:], key) {
+			b.WriteString(template[i : start+len(key)])
+			i = start + len(key)
+			continue
+		}
+
+		end = start
+		for end < len(template) && isIdentRune(rune(template[end])) {
+			end++
+		}
+		name := template[start:end]
+		value, ok := values[key][name]
+		if name == "" || !ok {
+			b.WriteString(template[i:end])
+			i = end
+			continue
+		}
+
+		s, err := placeholderValue(binder, b.String(), key, name, value, rendered, &args)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s: %w", name, err)
+		}
+		b.WriteString(s)
+		i = end
+	}
+
+	return b.String(), args, nil
+}
+
+// placeholderKey - the key the text starts with, empty if none
+func placeholderKey(text string, keys []string) string {
+	for _, key := range keys {
+		if strings.HasPrefix(text, key) {
+			return key
+		}
+	}
+	return ""
+}
+
+// placeholderValue - sql text of the value. before - the statement text preceding the placeholder.
+// Slices used in IN are expanded into lists of literals, in other places into array literals: "id = ANY(ARRAY[1, 2, 3])".
+// Ident values are quoted identifiers, Expr values are expressions with $n parameters, their arguments are added to args.
+// The remaining values are rendered by the binder, rendered caches them by key + name
+func placeholderValue(binder Binder, before string, key string, name string, value any, rendered map[string]string, args *[]any) (string, error) {
+	if ident, ok := value.(Ident); ok {
+		return quoteName(string(ident)), nil
+	}
+
+	if isListValue(value) {
+		if inListRe.MatchString(before) {
+			return listLiteral(value)
+		}
+		if inRe.MatchString(before) {
+			list, err := listLiteral(value)
+			if err != nil {
+				return "", err
+			}
+			return "(" + list + ")", nil
+		}
+		return Literal(value)
+	}
+
+	if s, ok := rendered[key+name]; ok {
+		return s, nil
+	}
+
+	var (
+		s   string
+		err error
+	)
+	if e, ok := value.(Expr); ok {
+		if s, err = e.sql(len(*args)); err != nil {
+			return "", err
+		}
+		*args = append(*args, e.Args...)
+	} else if s, err = binder.Bind(key+name, map[string]any{name: value}, key); err != nil {
+		return "", err
+	}
+	rendered[key+name] = s
+	return s, nil
+}
+
+const (
+	sectionStart = "[["
+	sectionEnd   = "]]"
+)
+
+// expandSections - include the optional sections whose placeholders have values, remove the others.
+// keys - placeholder keys, longer first
+func expandSections(template string, values map[string]map[string]any, keys []string) (string, error) {
+	if !strings.Contains(template, sectionStart) {
+		return template, nil
+	}
+
+	var b strings.Builder
+	for {
+		start := strings.Index(template, sectionStart)
+		if start < 0 {
+			b.WriteString(template)
+			return b.String(), nil
+		}
+
+		end := strings.Index(template[start:], sectionEnd)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated optional section at offset %d", start)
+		}
+		end += start
+
+		section := template[start+len(sectionStart) : end]
+		if strings.Contains(section, sectionStart) {
+			return "", fmt.Errorf("nested optional sections are not supported")
+		}
+
+		b.WriteString(template[:start])
+		if sectionPresent(section, values, keys) {
+			b.WriteString(section)
+		}
+		template = template[end+len(sectionEnd):]
+	}
+}
+
+// sectionPresent - all placeholders of the section have not nil values
+func sectionPresent(section string, values map[string]map[string]any, keys []string) bool {
+	for _, key := range keys {
+		rest := section
+		for {
+			i := strings.Index(rest, key)
+			if i < 0 {
+				break
+			}
+			rest = rest[i+len(key):]
+
+			// type cast for ":"
+			if strings.HasPrefix(rest, key) {
+				rest = rest[len(key):]
+				continue
+			}
+
+			n := 0
+			for n < len(rest) && isIdentRune(rune(rest[n])) {
+				n++
+			}
+			if n == 0 {
+				continue
+			}
+
+			if v, ok := values[key][rest[:n]]; !ok || v == nil {
+				return false
+			}
+			// hide from the shorter keys
+			section = strings.Replace(section, key+rest[:n], "", 1)
+			rest = rest[n:]
+		}
+	}
+	return true
+}
+
+// isListValue - slice or array, except []byte
+func isListValue(value any) bool {
+	if value == nil {
+		return false
+	}
+	t := reflect.TypeOf(value)
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() != reflect.Uint8
+}
+
+// listLiteral - comma separated literals of the slice elements, NULL for an empty slice
+func listLiteral(value any) (string, error) {
+	v := reflect.ValueOf(value)
+	if v.Len() == 0 {
+		return "NULL", nil
+	}
+
+	items := make([]string, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		s, err := Literal(v.Index(i).Interface())
+		if err != nil {
+			return "", err
+		}
+		items[i] = s
+	}
+	return strings.Join(items, ", "), nil
+}
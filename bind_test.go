@@ -0,0 +1,127 @@
+package sqlq
+
+import (
+	"strings"
+	"testing"
+)
+
+// literalBinder - binder that substitutes Literal of the values
+var literalBinder = BinderFunc(func(template string, values map[string]any, key string) (string, error) {
+	for name, v := range values {
+		s, err := Literal(v)
+		if err != nil {
+			return "", err
+		}
+		template = strings.ReplaceAll(template, key+name, s)
+	}
+	return template, nil
+})
+
+func TestBindSqlNoResubstitution(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		values   map[string]any
+		want     string
+	}{
+		{
+			name:     "slice element with placeholder",
+			template: "SELECT * FROM t WHERE a IN (:list) AND b = :pwd",
+			values:   map[string]any{"list": []string{"x', :pwd, '"}, "pwd": "secret"},
+			want:     "SELECT * FROM t WHERE a IN ('x'', :pwd, ''') AND b = 'secret'",
+		},
+		{
+			name:     "ident with placeholder",
+			template: "SELECT * FROM :table WHERE id = :other",
+			values:   map[string]any{"table": Ident(":other"), "other": 1},
+			want:     `SELECT * FROM ":other" WHERE id = 1`,
+		},
+		{
+			name:     "scalar with placeholder",
+			template: "UPDATE t SET a = :a, b = :b",
+			values:   map[string]any{"a": ":b", "b": "x"},
+			want:     "UPDATE t SET a = ':b', b = 'x'",
+		},
+		{
+			name:     "array with placeholder",
+			template: "SELECT * FROM t WHERE a = ANY(:list) AND b = :b",
+			values:   map[string]any{"list": []string{":b"}, "b": 2},
+			want:     "SELECT * FROM t WHERE a = ANY(ARRAY[':b']) AND b = 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := bindSql(literalBinder, tt.template, tt.values, ":")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindSqlSkipsQuotedText(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     string
+	}{
+		{"string literal", "SELECT ':a', :a", "SELECT ':a', 1"},
+		{"escape string", `SELECT E'\':a', :a`, `SELECT E'\':a', 1`},
+		{"quoted identifier", `SELECT ":a" FROM t WHERE b = :a`, `SELECT ":a" FROM t WHERE b = 1`},
+		{"line comment", "SELECT :a -- :a\n, :a", "SELECT 1 -- :a\n, 1"},
+		{"block comment", "SELECT /* :a /* :a */ :a */ :a", "SELECT /* :a /* :a */ :a */ 1"},
+		{"dollar quote", "SELECT $x$ :a $x$, $$:a$$, :a", "SELECT $x$ :a $x$, $$:a$$, 1"},
+		{"type cast", "SELECT :a::text", "SELECT 1::text"},
+		{"missing value", "SELECT :a, :b", "SELECT 1, :b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := bindSql(literalBinder, tt.template, map[string]any{"a": 1}, ":")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBindSqlKeys(t *testing.T) {
+	got, _, err := bindSqlKeys(literalBinder, "SELECT :f:a, :a, ':f:a'", map[string]map[string]any{
+		":":   {"a": ":f:a"},
+		":f:": {"a": 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "SELECT 2, ':f:a', ':f:a'"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestBindSqlUnterminated(t *testing.T) {
+	for _, template := range []string{"SELECT ':a", `SELECT ":a`, "SELECT /* :a", "SELECT $$ :a"} {
+		if _, _, err := bindSql(literalBinder, template, map[string]any{"a": 1}, ":"); err == nil {
+			t.Errorf("%q: expected error", template)
+		}
+	}
+}
+
+func TestBindSqlIdent(t *testing.T) {
+	got, _, err := bindSql(literalBinder, "SELECT * FROM :table", map[string]any{"table": Ident("tenant_1.us\x00er\"s")}, ":")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `SELECT * FROM "tenant_1"."user""s"`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if want := QuoteQualified("tenant_1", "user\"s"); got != "SELECT * FROM "+want {
+		t.Errorf("got %q, QuoteQualified %q", got, want)
+	}
+}
@@ -0,0 +1,157 @@
+package sqlq
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/n-r-w/nerr"
+)
+
+// ExecResult - result of a single statement of ExecBindMany
+type ExecResult struct {
+	RowsAffected int64
+	Err          error
+}
+
+// ExecBindMany - execute the template for each set of values. The statements are sent in one round trip with pgx.Batch.
+// Outside of a transaction the batch is executed as one implicit transaction: after the first failed statement
+// the rest are not applied and get errors too, the preceding ones are rolled back and get "rolled back" errors.
+// Sets that fail to bind are not sent.
+// Returns the result of each set and the first error
+func (q *Query) ExecBindMany(sqlTemplate string, valueSets []map[string]any, key string) ([]ExecResult, error) {
+	q.rows = nil
+	q.lastValues = nil
+	q.lastDescriptions = nil
+	q.resetFields()
+
+	if cache := requestCacheFromContext(q.ctx); cache != nil {
+		cache.clear()
+	}
+
+	results := make([]ExecResult, len(valueSets))
+	batch := &pgx.Batch{}
+	queued := make([]int, 0, len(valueSets))
+	sqls := make([]string, 0, len(valueSets))
+
+	for i, values := range valueSets {
+		sql, args, err := bindSql(q.Binder(), sqlTemplate, values, key)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		sqls = append(sqls, sql)
+		batch.Queue(sql, args...)
+		queued = append(queued, i)
+	}
+
+	if batch.Len() > 0 {
+		var batchErr error
+		writes, err := q.sendBatch(batch, sqls, func(br pgx.BatchResults) {
+			for _, i := range queued {
+				tag, err := br.Exec()
+				results[i] = ExecResult{RowsAffected: tag.RowsAffected(), Err: err}
+				if err != nil && batchErr == nil {
+					batchErr = err
+				}
+			}
+		})
+		if batchErr == nil {
+			batchErr = err
+		}
+
+		// outside of a transaction the successful statements are rolled back with the implicit transaction
+		if batchErr != nil && (q.tx == nil || err != nil) {
+			for _, i := range queued {
+				if results[i].Err == nil {
+					results[i].Err = fmt.Errorf("rolled back: %w", batchErr)
+				}
+			}
+		}
+
+		if batchErr == nil {
+			if err := q.auditBatch(writes); err != nil {
+				return results, err
+			}
+		}
+	}
+
+	for i, r := range results {
+		if r.Err != nil {
+			return results, nerr.New(fmt.Errorf("value set %d: %w", i, r.Err))
+		}
+	}
+	return results, nil
+}
+
+// sendBatch - send the batch on the transaction or the pool and read the results. sqls - statements of the batch
+// for the transaction journal and the audit. Returns the commands executed by the batch, they must be audited
+// with auditBatch if the whole batch succeeded
+func (q *Query) sendBatch(batch *pgx.Batch, sqls []string, read func(pgx.BatchResults)) ([]batchWrite, error) {
+	var ar *auditBatchResults
+	if q.auditSink() != nil {
+		ar = &auditBatchResults{sqls: sqls}
+	}
+
+	err := q.sendBatchResults(batch, sqls, func(br pgx.BatchResults) {
+		if ar == nil {
+			read(br)
+			return
+		}
+		ar.BatchResults = br
+		read(ar)
+	})
+	if ar == nil || err != nil {
+		return nil, err
+	}
+	return ar.writes, nil
+}
+
+// sendBatchResults - send the batch and pass its results to read. The transaction is released before return,
+// so the audit records can be written in it
+func (q *Query) sendBatchResults(batch *pgx.Batch, sqls []string, read func(pgx.BatchResults)) error {
+	ctx := q.ctx
+	timeout, hasTimeout := q.statementTimeout(ctx)
+
+	var br pgx.BatchResults
+	switch {
+	case q.tx != nil:
+		tx, release, err := q.tx.use(sqls...)
+		if err != nil {
+			return err
+		}
+		defer release()
+
+		if err := q.applyLocalSettings(ctx, tx, timeout, hasTimeout); err != nil {
+			return err
+		}
+		br = tx.SendBatch(ctx, batch)
+
+	case len(q.localSettings) > 0:
+		return nerr.New("local settings require a transaction")
+
+	case hasTimeout || q.dedicatedConn():
+		c, release, err := q.acquire(ctx, timeout, hasTimeout)
+		if err != nil {
+			return err
+		}
+		defer release(c)
+		br = c.SendBatch(ctx, batch)
+
+	default:
+		br = q.pool.SendBatch(ctx, batch)
+	}
+
+	read(br)
+	return br.Close()
+}
+
+func ExecBindMany(pool *pgxpool.Pool, ctx context.Context, template string, valueSets []map[string]any, key string) ([]ExecResult, error) {
+	return NewQuery(pool, ctx).ExecBindMany(template, valueSets, key)
+}
+
+func ExecTxBindMany(tx *Tx, template string, valueSets []map[string]any, key string) ([]ExecResult, error) {
+	return NewQueryTx(tx, tx.ctx).ExecBindMany(template, valueSets, key)
+}
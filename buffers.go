@@ -0,0 +1,118 @@
+package sqlq
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/jackc/pgtype"
+)
+
+// fieldsPool - reused field name -> index maps of the queries, see Query.Release
+var fieldsPool = sync.Pool{
+	New: func() any {
+		return map[string]int{}
+	},
+}
+
+// valuesPool - reused value slices of the rows, see Query.Values and Query.Release
+var valuesPool = sync.Pool{
+	New: func() any {
+		return new([]any)
+	},
+}
+
+// resetFields - empty field index map, the map of the previous selection is reused
+func (q *Query) resetFields() {
+	if q.fields == nil {
+		q.fields = fieldsPool.Get().(map[string]int)
+		return
+	}
+
+	for k := range q.fields {
+		delete(q.fields, k)
+	}
+}
+
+// Release - close the selection and return the internal buffers of the query for reuse by other queries.
+// Optional, reduces allocations on hot paths. The query must not be used after Release
+func (q *Query) Release() {
+	_ = q.Close()
+
+	if q.fields != nil {
+		for k := range q.fields {
+			delete(q.fields, k)
+		}
+		fieldsPool.Put(q.fields)
+		q.fields = nil
+	}
+	if q.valuesBuf != nil {
+		values := (*q.valuesBuf)[:cap(*q.valuesBuf)]
+		for i := range values {
+			values[i] = nil
+		}
+		*q.valuesBuf = values[:0]
+		valuesPool.Put(q.valuesBuf)
+		q.valuesBuf = nil
+	}
+	q.lastValues = nil
+	q.lastDescriptions = nil
+	q.rowValues = nil
+	q.rowValuesOf = nil
+}
+
+// decodeValues - decode the current row into the value slice of the query, reused by all rows.
+// Same as pgx.Rows.Values, which allocates a new slice for every row. The data types of the connection
+// are used for decoding, so ci must be the type information of the connection of the rows
+func (q *Query) decodeValues(ci *pgtype.ConnInfo) ([]any, error) {
+	fields := q.rows.FieldDescriptions()
+	raw := q.rows.RawValues()
+	if len(raw) != len(fields) {
+		return nil, errors.New("no current row")
+	}
+
+	if q.valuesBuf == nil {
+		q.valuesBuf = valuesPool.Get().(*[]any)
+	}
+	values := (*q.valuesBuf)[:0]
+
+	for i := range fields {
+		buf := raw[i]
+		fd := &fields[i]
+
+		if buf == nil {
+			values = append(values, nil)
+			continue
+		}
+
+		var value pgtype.Value
+		if dt, ok := ci.DataTypeForOID(fd.DataTypeOID); ok {
+			value = dt.Value
+		}
+
+		switch fd.Format {
+		case pgtype.TextFormatCode:
+			decoder, ok := value.(pgtype.TextDecoder)
+			if !ok {
+				decoder = &pgtype.GenericText{}
+			}
+			if err := decoder.DecodeText(ci, buf); err != nil {
+				return nil, err
+			}
+			values = append(values, decoder.(pgtype.Value).Get())
+		case pgtype.BinaryFormatCode:
+			decoder, ok := value.(pgtype.BinaryDecoder)
+			if !ok {
+				decoder = &pgtype.GenericBinary{}
+			}
+			if err := decoder.DecodeBinary(ci, buf); err != nil {
+				return nil, err
+			}
+			values = append(values, decoder.(pgtype.Value).Get())
+		default:
+			return nil, errors.New("unknown format code")
+		}
+	}
+
+	*q.valuesBuf = values
+	return values, nil
+}
@@ -0,0 +1,125 @@
+package sqlq
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+const bufferRows = 100
+
+func expectBufferRows(srv *sqlqtest.FakePostgres) {
+	res := sqlqtest.Result{Columns: []sqlqtest.Column{{Name: "id"}, {Name: "name"}, {Name: "note"}}}
+	for i := 0; i < bufferRows; i++ {
+		res.Rows = append(res.Rows, []any{int64(i), "user", nil})
+	}
+	srv.Expect("SELECT id, name, note FROM users", res)
+}
+
+func TestValuesSharedReuseSlice(t *testing.T) {
+	srv, pool := newTestPool(t)
+	expectBufferRows(srv)
+
+	q := NewQuery(pool, context.Background())
+	defer q.Release()
+	if err := q.Select("SELECT id, name, note FROM users"); err != nil {
+		t.Fatal(err)
+	}
+
+	var first *any
+	for i := int64(0); q.Next(); i++ {
+		values, err := q.ValuesShared()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []any{i, "user", nil}; !reflect.DeepEqual(values, want) {
+			t.Fatalf("row %d: got %v, want %v", i, values, want)
+		}
+
+		if first == nil {
+			first = &values[0]
+		} else if &values[0] != first {
+			t.Fatalf("row %d: value slice is not reused", i)
+		}
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValuesKeptByCaller(t *testing.T) {
+	srv, pool := newTestPool(t)
+	expectBufferRows(srv)
+
+	q := NewQuery(pool, context.Background())
+	defer q.Release()
+	if err := q.Select("SELECT id, name, note FROM users"); err != nil {
+		t.Fatal(err)
+	}
+
+	var all [][]any
+	for q.Next() {
+		// the accessors decode the row into the shared slice first
+		_ = q.Int64("id")
+
+		values, err := q.Values()
+		if err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, values)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(all) != bufferRows {
+		t.Fatalf("got %d rows, want %d", len(all), bufferRows)
+	}
+	for i, values := range all {
+		if want := []any{int64(i), "user", nil}; !reflect.DeepEqual(values, want) {
+			t.Fatalf("row %d: got %v, want %v", i, values, want)
+		}
+	}
+}
+
+func BenchmarkSelectValues(b *testing.B) {
+	srv, pool := newTestPool(b)
+	expectBufferRows(srv)
+	ctx := context.Background()
+
+	// values decoded by pgx: a new slice for every row
+	b.Run("pgx", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			q := NewQuery(pool, ctx)
+			if err := q.Select("SELECT id, name, note FROM users"); err != nil {
+				b.Fatal(err)
+			}
+			for q.Next() {
+				if _, err := q.PgxRows().Values(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			_ = q.Close()
+		}
+	})
+
+	// values decoded into the pooled slice, the buffers are returned by Release
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			q := NewQuery(pool, ctx)
+			if err := q.Select("SELECT id, name, note FROM users"); err != nil {
+				b.Fatal(err)
+			}
+			for q.Next() {
+				if _, err := q.ValuesShared(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			q.Release()
+		}
+	})
+}
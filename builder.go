@@ -0,0 +1,261 @@
+package sqlq
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/n-r-w/nerr"
+)
+
+// QueryBuilder - select statement builder. Conditions use ? placeholders that are converted to $n parameters,
+// ?? is a literal question mark (jsonb operators). Column and table expressions are not quoted
+type QueryBuilder struct {
+	columns []string
+	from    string
+	joins   []builderPart
+	where   []builderPart
+	groupBy []string
+	having  []builderPart
+	orderBy []string
+	limit   int
+	offset  int
+}
+
+type builderPart struct {
+	sql  string
+	args []any
+}
+
+// Builder - create a select statement builder
+func Builder() *QueryBuilder {
+	return &QueryBuilder{
+		limit: -1,
+	}
+}
+
+// Select - columns of the selection, * by default
+func (b *QueryBuilder) Select(columns ...string) *QueryBuilder {
+	b.columns = append(b.columns, columns...)
+	return b
+}
+
+// From - table or subquery
+func (b *QueryBuilder) From(table string) *QueryBuilder {
+	b.from = table
+	return b
+}
+
+// FromTable - quoted schema-qualified table, for tables chosen at runtime (tenant schemas). Empty schema - table only
+func (b *QueryBuilder) FromTable(schema string, table string) *QueryBuilder {
+	b.from = QuoteQualified(schema, table)
+	return b
+}
+
+// Join - join clause: Join("JOIN orders o ON o.user_id = u.id AND o.status = ?", status)
+func (b *QueryBuilder) Join(join string, args ...any) *QueryBuilder {
+	b.joins = append(b.joins, builderPart{sql: join, args: args})
+	return b
+}
+
+// Where - condition, conditions are combined with AND: Where("id = ?", id)
+func (b *QueryBuilder) Where(cond string, args ...any) *QueryBuilder {
+	b.where = append(b.where, builderPart{sql: cond, args: args})
+	return b
+}
+
+// WhereIf - condition that is added only if include is true. Used for optional filters
+func (b *QueryBuilder) WhereIf(include bool, cond string, args ...any) *QueryBuilder {
+	if include {
+		return b.Where(cond, args...)
+	}
+	return b
+}
+
+// GroupBy - grouping expressions
+func (b *QueryBuilder) GroupBy(exprs ...string) *QueryBuilder {
+	b.groupBy = append(b.groupBy, exprs...)
+	return b
+}
+
+// Having - condition on groups, conditions are combined with AND
+func (b *QueryBuilder) Having(cond string, args ...any) *QueryBuilder {
+	b.having = append(b.having, builderPart{sql: cond, args: args})
+	return b
+}
+
+// OrderBy - sorting expressions: OrderBy("name", "id DESC")
+func (b *QueryBuilder) OrderBy(exprs ...string) *QueryBuilder {
+	b.orderBy = append(b.orderBy, exprs...)
+	return b
+}
+
+// Limit - maximum number of rows. Negative - no limit
+func (b *QueryBuilder) Limit(n int) *QueryBuilder {
+	b.limit = n
+	return b
+}
+
+// Offset - number of rows to skip
+func (b *QueryBuilder) Offset(n int) *QueryBuilder {
+	b.offset = n
+	return b
+}
+
+// Sql - sql and parameters for Query.SelectArgs
+func (b *QueryBuilder) Sql() (string, []any, error) {
+	var (
+		sql  strings.Builder
+		args []any
+	)
+
+	// parts are separated by sep, conditions are wrapped in parentheses so that OR inside them is not mixed with AND
+	addParts := func(parts []builderPart, sep string, wrap bool) error {
+		for i, part := range parts {
+			s, n, err := builderPlaceholders(part.sql, len(args))
+			if err != nil {
+				return err
+			}
+			if n != len(part.args) {
+				return fmt.Errorf("%s: %d placeholders, %d arguments", part.sql, n, len(part.args))
+			}
+
+			if i > 0 {
+				sql.WriteString(sep)
+			}
+			if wrap && len(parts) > 1 {
+				s = "(" + s + ")"
+			}
+			sql.WriteString(s)
+			args = append(args, part.args...)
+		}
+		return nil
+	}
+
+	sql.WriteString("SELECT ")
+	if len(b.columns) == 0 {
+		sql.WriteString("*")
+	} else {
+		sql.WriteString(strings.Join(b.columns, ", "))
+	}
+
+	if b.from != "" {
+		sql.WriteString(" FROM ")
+		sql.WriteString(b.from)
+	}
+
+	if len(b.joins) > 0 {
+		sql.WriteString(" ")
+		if err := addParts(b.joins, " ", false); err != nil {
+			return "", nil, nerr.New(err)
+		}
+	}
+
+	if len(b.where) > 0 {
+		sql.WriteString(" WHERE ")
+		if err := addParts(b.where, " AND ", true); err != nil {
+			return "", nil, nerr.New(err)
+		}
+	}
+
+	if len(b.groupBy) > 0 {
+		sql.WriteString(" GROUP BY ")
+		sql.WriteString(strings.Join(b.groupBy, ", "))
+	}
+
+	if len(b.having) > 0 {
+		sql.WriteString(" HAVING ")
+		if err := addParts(b.having, " AND ", true); err != nil {
+			return "", nil, nerr.New(err)
+		}
+	}
+
+	if len(b.orderBy) > 0 {
+		sql.WriteString(" ORDER BY ")
+		sql.WriteString(strings.Join(b.orderBy, ", "))
+	}
+
+	if b.limit >= 0 {
+		sql.WriteString(" LIMIT ")
+		sql.WriteString(strconv.Itoa(b.limit))
+	}
+
+	if b.offset > 0 {
+		sql.WriteString(" OFFSET ")
+		sql.WriteString(strconv.Itoa(b.offset))
+	}
+
+	return sql.String(), args, nil
+}
+
+// builderPlaceholders - replace ? with $n starting after the given number of parameters. String literals, quoted
+// identifiers and comments are not changed. ?? is an escaped ? operator. Returns the number of placeholders
+func builderPlaceholders(sql string, offset int) (string, int, error) {
+	var b strings.Builder
+	n := offset
+
+	for i := 0; i < len(sql); i++ {
+		end, err := skipSqlToken(sql, i)
+		if err != nil {
+			return "", 0, fmt.Errorf("%s: %w", sql, err)
+		}
+		if end > i {
+			b.WriteString(sql[i:end])
+			i = end - 1
+			continue
+		}
+
+		c := sql[i]
+		switch {
+		case c == '?' && i+1 < len(sql) && sql[i+1] == '?':
+			b.WriteByte('?')
+			i++
+
+		case c == '?':
+			n++
+			b.WriteString("$")
+			b.WriteString(strconv.Itoa(n))
+
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String(), n - offset, nil
+}
+
+// hasDollarPlaceholders - the statement uses $n placeholders outside of string literals, quoted identifiers and comments
+func hasDollarPlaceholders(sql string) (bool, error) {
+	n, err := ParamCount(sql)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", sql, err)
+	}
+	return n > 0, nil
+}
+
+// SelectBuilder - executing the select command built by the QueryBuilder
+func (q *Query) SelectBuilder(b *QueryBuilder) error {
+	sql, args, err := b.Sql()
+	if err != nil {
+		return err
+	}
+	return q.SelectArgs(sql, args...)
+}
+
+func SelectBuilder(pool *pgxpool.Pool, ctx context.Context, b *QueryBuilder) (*Query, error) {
+	q := NewQuery(pool, ctx)
+	if err := q.SelectBuilder(b); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func SelectTxBuilder(tx *Tx, b *QueryBuilder) (*Query, error) {
+	q := NewQueryTx(tx, tx.ctx)
+	if err := q.SelectBuilder(b); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
@@ -0,0 +1,141 @@
+package sqlq
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgtype"
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+func TestQueryBuilderSql(t *testing.T) {
+	tests := []struct {
+		name     string
+		builder  *QueryBuilder
+		wantSql  string
+		wantArgs []any
+		wantErr  bool
+	}{
+		{
+			name:    "defaults",
+			builder: Builder().From("users"),
+			wantSql: "SELECT * FROM users",
+		},
+		{
+			name: "all clauses",
+			builder: Builder().
+				Select("u.id", "count(o.id) AS n").
+				From("users u").
+				Join("JOIN orders o ON o.user_id = u.id AND o.status = ?", "paid").
+				Where("u.active").
+				Where("u.role = ? OR u.role = ?", "admin", "owner").
+				WhereIf(false, "u.id = ?", 1).
+				WhereIf(true, "u.created_at > ?", "2024-01-01").
+				GroupBy("u.id").
+				Having("count(o.id) > ?", 2).
+				OrderBy("n DESC", "u.id").
+				Limit(10).
+				Offset(20),
+			wantSql: "SELECT u.id, count(o.id) AS n FROM users u JOIN orders o ON o.user_id = u.id AND o.status = $1 " +
+				"WHERE (u.active) AND (u.role = $2 OR u.role = $3) AND (u.created_at > $4) " +
+				"GROUP BY u.id HAVING count(o.id) > $5 ORDER BY n DESC, u.id LIMIT 10 OFFSET 20",
+			wantArgs: []any{"paid", "admin", "owner", "2024-01-01", 2},
+		},
+		{
+			name:     "single condition is not wrapped",
+			builder:  Builder().From("users").Where("id = ? OR id = ?", 1, 2),
+			wantSql:  "SELECT * FROM users WHERE id = $1 OR id = $2",
+			wantArgs: []any{1, 2},
+		},
+		{
+			name:     "literals and escaped operator",
+			builder:  Builder().From("docs").Where(`data ?? 'key' AND note <> '?' AND "a?" = ?`, 1),
+			wantSql:  `SELECT * FROM docs WHERE data ? 'key' AND note <> '?' AND "a?" = $1`,
+			wantArgs: []any{1},
+		},
+		{
+			name:    "zero limit, no offset",
+			builder: Builder().From("users").Limit(0).Offset(0),
+			wantSql: "SELECT * FROM users LIMIT 0",
+		},
+		{
+			name:    "quoted table",
+			builder: Builder().Select("id").FromTable("tenant 1", "users"),
+			wantSql: `SELECT id FROM "tenant 1"."users"`,
+		},
+		{
+			name:    "too few arguments",
+			builder: Builder().From("users").Where("id = ? AND name = ?", 1),
+			wantErr: true,
+		},
+		{
+			name:    "too many arguments",
+			builder: Builder().From("users").Having("count(*) > 1", 1),
+			wantErr: true,
+		},
+		{
+			name:    "unterminated literal",
+			builder: Builder().From("users").Join("JOIN t ON t.name = 'x"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args, err := tt.builder.Sql()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if sql != tt.wantSql {
+				t.Errorf("got  %s\nwant %s", sql, tt.wantSql)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestSelectBuilder(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect("SELECT id, name FROM users WHERE name = $1 LIMIT 1", sqlqtest.Result{
+		Columns: []sqlqtest.Column{{Name: "id"}, {Name: "name"}},
+		Rows:    [][]any{{int64(1), "admin"}},
+		Params:  []uint32{pgtype.TextOID},
+	})
+
+	b := Builder().Select("id", "name").From("users").Where("name = ?", "admin").Limit(1)
+
+	q, err := SelectBuilder(pool, context.Background(), b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !q.Next() || q.ValueIndex(1) != "admin" {
+		t.Error("unexpected result")
+	}
+	q.Release()
+
+	err = RunInTx(pool, context.Background(), func(tx *Tx) error {
+		q, err := SelectTxBuilder(tx, b)
+		if err != nil {
+			return err
+		}
+		defer q.Release()
+
+		if !q.Next() || q.ValueIndex(0) != int64(1) {
+			t.Error("tx: unexpected result")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := SelectBuilder(pool, context.Background(), Builder().From("users").Where("id = ?")); err == nil {
+		t.Error("expected error")
+	}
+}
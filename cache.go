@@ -0,0 +1,197 @@
+package sqlq
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgx/v4"
+	"github.com/n-r-w/nerr"
+)
+
+type requestCacheKey struct{}
+
+// requestCache - select results cached within the context
+type requestCache struct {
+	mu      sync.Mutex
+	results map[string]*cachedResult
+}
+
+type cachedResult struct {
+	fields []pgproto3.FieldDescription
+	values [][]any
+	raw    [][][]byte
+	tag    pgconn.CommandTag
+}
+
+// WithRequestCache - enable caching of select results within the context (for example, an http request).
+// Repeated selects with the same sql text and arguments outside of transactions return the cached result. Any Exec with this
+// context clears the cache. Cached values are shared, they must not be modified
+func WithRequestCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestCacheKey{}, &requestCache{results: map[string]*cachedResult{}})
+}
+
+// ClearRequestCache - drop all results cached within the context
+func ClearRequestCache(ctx context.Context) {
+	if c := requestCacheFromContext(ctx); c != nil {
+		c.clear()
+	}
+}
+
+func requestCacheFromContext(ctx context.Context) *requestCache {
+	c, _ := ctx.Value(requestCacheKey{}).(*requestCache)
+	return c
+}
+
+func (c *requestCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = map[string]*cachedResult{}
+}
+
+// query - cached result of the select, executes and caches it if necessary
+func (c *requestCache) query(q *Query, ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	key := sql
+	if len(args) > 0 {
+		key += fmt.Sprintf("\x00%#v", args)
+	}
+
+	c.mu.Lock()
+	res, ok := c.results[key]
+	c.mu.Unlock()
+	if ok {
+		return newCachedRows(res), nil
+	}
+
+	rows, err := q.querySql(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err = readCachedResult(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.results[key] = res
+	c.mu.Unlock()
+
+	return newCachedRows(res), nil
+}
+
+// readCachedResult - read all rows into memory and close them
+func readCachedResult(rows pgx.Rows) (*cachedResult, error) {
+	defer rows.Close()
+
+	res := &cachedResult{
+		fields: append([]pgproto3.FieldDescription{}, rows.FieldDescriptions()...),
+	}
+
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return nil, nerr.New(err)
+		}
+
+		// raw values are reused by pgx between rows
+		raw := make([][]byte, len(rows.RawValues()))
+		for i, r := range rows.RawValues() {
+			if r != nil {
+				raw[i] = append([]byte{}, r...)
+			}
+		}
+
+		res.values = append(res.values, values)
+		res.raw = append(res.raw, raw)
+	}
+
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	res.tag = rows.CommandTag()
+
+	return res, nil
+}
+
+// cachedRows - pgx.Rows over the cached result
+type cachedRows struct {
+	res    *cachedResult
+	pos    int
+	closed bool
+}
+
+func newCachedRows(res *cachedResult) *cachedRows {
+	return &cachedRows{res: res, pos: -1}
+}
+
+func (r *cachedRows) Close() {
+	r.closed = true
+}
+
+func (r *cachedRows) Err() error {
+	return nil
+}
+
+// CommandTag - like pgx rows, the command tag is available after the rows are closed
+func (r *cachedRows) CommandTag() pgconn.CommandTag {
+	if !r.closed {
+		return nil
+	}
+	return r.res.tag
+}
+
+func (r *cachedRows) FieldDescriptions() []pgproto3.FieldDescription {
+	return r.res.fields
+}
+
+func (r *cachedRows) Next() bool {
+	if r.closed {
+		return false
+	}
+
+	r.pos++
+	if r.pos >= len(r.res.values) {
+		r.closed = true
+		return false
+	}
+	return true
+}
+
+func (r *cachedRows) Scan(dest ...any) error {
+	values, err := r.Values()
+	if err != nil {
+		return err
+	}
+	if len(dest) != len(values) {
+		return nerr.New("number of field descriptions must equal number of destinations")
+	}
+
+	raw := r.RawValues()
+	for i, d := range dest {
+		if d == nil {
+			continue
+		}
+		if err := scanValue(d, values[i], raw[i], &r.res.fields[i]); err != nil {
+			return nerr.New(err)
+		}
+	}
+	return nil
+}
+
+func (r *cachedRows) Values() ([]any, error) {
+	if r.pos < 0 || r.pos >= len(r.res.values) {
+		return nil, nerr.New("no current row")
+	}
+	return r.res.values[r.pos], nil
+}
+
+func (r *cachedRows) RawValues() [][]byte {
+	if r.pos < 0 || r.pos >= len(r.res.raw) {
+		return nil
+	}
+	return r.res.raw[r.pos]
+}
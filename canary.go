@@ -0,0 +1,164 @@
+package sqlq
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const (
+	// number of runs before the baseline is considered established
+	canaryWarmup = 5
+	// weight of the latest latency in the baseline
+	canaryAlpha = 0.1
+	// default regression threshold: latency / baseline
+	canaryDefaultFactor = 2.0
+	// default interval between runs
+	canaryDefaultInterval = time.Minute
+)
+
+// CanaryQuery - representative lightweight query
+type CanaryQuery struct {
+	Name string
+	SQL  string
+	// Factor - regression threshold: the alert is raised when latency > baseline * Factor. 0 - 2
+	Factor float64
+}
+
+// CanaryAlert - latency regression or error of a canary query
+type CanaryAlert struct {
+	Name     string
+	SQL      string
+	Latency  time.Duration
+	Baseline time.Duration
+	// Err - query error, nil for latency regressions
+	Err error
+}
+
+// CanaryStats - latency baseline of a canary query
+type CanaryStats struct {
+	Name     string
+	Runs     int64
+	Errors   int64
+	Last     time.Duration
+	Baseline time.Duration
+}
+
+// CanaryRunner - periodically runs canary queries, see Canary
+type CanaryRunner struct {
+	pool    *pgxpool.Pool
+	queries []CanaryQuery
+	alert   func(CanaryAlert)
+
+	mu    sync.Mutex
+	stats []CanaryStats
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Canary - start running the queries with the specified interval (1m if interval <= 0) until ctx is done or Stop is called.
+// Each query maintains an exponentially weighted latency baseline, alert is called on errors and when
+// the latency exceeds the baseline by the query Factor
+func Canary(pool *pgxpool.Pool, ctx context.Context, interval time.Duration, queries []CanaryQuery, alert func(CanaryAlert)) *CanaryRunner {
+	if interval <= 0 {
+		interval = canaryDefaultInterval
+	}
+
+	c := &CanaryRunner{
+		pool:    pool,
+		queries: queries,
+		alert:   alert,
+		stats:   make([]CanaryStats, len(queries)),
+		done:    make(chan struct{}),
+	}
+	for i, q := range queries {
+		c.stats[i].Name = q.Name
+	}
+
+	ctx, c.cancel = context.WithCancel(ctx)
+	go c.run(ctx, interval)
+
+	return c
+}
+
+// Stop - stop running the queries
+func (c *CanaryRunner) Stop() {
+	c.cancel()
+	<-c.done
+}
+
+// Stats - latency baselines of the queries
+func (c *CanaryRunner) Stats() []CanaryStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]CanaryStats{}, c.stats...)
+}
+
+func (c *CanaryRunner) run(ctx context.Context, interval time.Duration) {
+	defer close(c.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for i := range c.queries {
+			if ctx.Err() != nil {
+				return
+			}
+			c.check(ctx, i)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *CanaryRunner) check(ctx context.Context, i int) {
+	cq := c.queries[i]
+
+	start := time.Now()
+	err := ForEach(c.pool, ctx, cq.SQL, func(q *Query) error { return nil })
+	latency := time.Since(start)
+
+	if err != nil && ctx.Err() != nil {
+		// stopped
+		return
+	}
+
+	c.mu.Lock()
+	st := &c.stats[i]
+	st.Runs++
+	st.Last = latency
+	var alert *CanaryAlert
+
+	if err != nil {
+		st.Errors++
+		alert = &CanaryAlert{Name: cq.Name, SQL: cq.SQL, Latency: latency, Baseline: st.Baseline, Err: err}
+	} else {
+		factor := cq.Factor
+		if factor <= 0 {
+			factor = canaryDefaultFactor
+		}
+
+		if st.Runs-st.Errors > canaryWarmup && float64(latency) > float64(st.Baseline)*factor {
+			alert = &CanaryAlert{Name: cq.Name, SQL: cq.SQL, Latency: latency, Baseline: st.Baseline}
+		}
+
+		if st.Baseline == 0 {
+			st.Baseline = latency
+		} else {
+			st.Baseline = time.Duration(canaryAlpha*float64(latency) + (1-canaryAlpha)*float64(st.Baseline))
+		}
+	}
+	c.mu.Unlock()
+
+	if alert != nil && c.alert != nil {
+		c.alert(*alert)
+	}
+}
@@ -0,0 +1,18 @@
+package sqlq
+
+import (
+	"context"
+	"testing"
+
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+func TestCanaryDefaultInterval(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect("SELECT 1 AS a", sqlqtest.Result{Columns: []sqlqtest.Column{{Name: "a"}}, Rows: [][]any{{1}}})
+
+	c := Canary(pool, context.Background(), 0, []CanaryQuery{{Name: "one", SQL: "SELECT 1 AS a"}}, func(a CanaryAlert) {
+		t.Errorf("unexpected alert: %+v", a)
+	})
+	c.Stop()
+}
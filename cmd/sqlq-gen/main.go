@@ -0,0 +1,86 @@
+// sqlq-gen - generate typed Go functions on top of sqlq.Query from annotated sql files, see package sqlqgen.
+//
+//	sqlq-gen -pkg queries -out queries/queries.gen.go queries/*.sql
+//
+// Directories in the arguments are scanned for *.sql files
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/n-r-w/sqlq/sqlqgen"
+)
+
+func main() {
+	pkg := flag.String("pkg", "queries", "package name of the generated file")
+	out := flag.String("out", "", "output file, stdout if empty")
+	flag.Parse()
+
+	if err := run(*pkg, *out, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "sqlq-gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(pkg string, out string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no sql files")
+	}
+
+	files, err := sqlFiles(args)
+	if err != nil {
+		return err
+	}
+
+	var queries []*sqlqgen.QueryDef
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defs, err := sqlqgen.Parse(file, f)
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+		queries = append(queries, defs...)
+	}
+
+	src, err := sqlqgen.Generate(pkg, queries)
+	if err != nil {
+		return err
+	}
+
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(out, src, 0o644)
+}
+
+// sqlFiles - files of the arguments, directories are expanded into their *.sql files
+func sqlFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+
+		matches, err := filepath.Glob(filepath.Join(arg, "*.sql"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	return files, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// the golden file of the generator for the same sql files
+const testdata = "../../sqlqgen/testdata"
+
+func TestRunDirectory(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "queries.gen.go")
+	if err := run("queries", out, []string{testdata}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join(testdata, "queries.gen.go.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("generated file differs from the golden file:\n%s", got)
+	}
+}
+
+func TestRunFiles(t *testing.T) {
+	files := []string{filepath.Join(testdata, "users.sql"), filepath.Join(testdata, "events.sql")}
+	out := filepath.Join(t.TempDir(), "queries.gen.go")
+	if err := run("queries", out, files); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the queries follow the order of the arguments
+	if i, j := bytes.Index(got, []byte("func GetUser(")), bytes.Index(got, []byte("func ListEvents(")); i < 0 || j < 0 || i > j {
+		t.Errorf("unexpected order of the queries:\n%s", got)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	if err := run("queries", "", nil); err == nil {
+		t.Error("no files: expected error")
+	}
+	if err := run("queries", "", []string{filepath.Join(testdata, "missing.sql")}); err == nil {
+		t.Error("missing file: expected error")
+	}
+}
@@ -0,0 +1,239 @@
+package sqlq
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgtype"
+	"github.com/n-r-w/nerr"
+)
+
+// ArrowType - Apache Arrow logical type of the column
+type ArrowType string
+
+const (
+	ArrowBool    ArrowType = "bool"
+	ArrowInt16   ArrowType = "int16"
+	ArrowInt32   ArrowType = "int32"
+	ArrowInt64   ArrowType = "int64"
+	ArrowFloat32 ArrowType = "float32"
+	ArrowFloat64 ArrowType = "float64"
+	// ArrowString - utf8. Also used for numeric (to keep precision), json, uuid and unknown types
+	ArrowString ArrowType = "utf8"
+	ArrowBinary ArrowType = "binary"
+	// ArrowDate32 - days since the Unix epoch
+	ArrowDate32 ArrowType = "date32"
+	// ArrowTimestamp - microseconds since the Unix epoch, UTC
+	ArrowTimestamp ArrowType = "timestamp[us, UTC]"
+)
+
+// ColumnSchema - column of the columnar batch
+type ColumnSchema struct {
+	Name string
+	Type ArrowType
+	// OID - PostgreSQL type of the column
+	OID uint32
+}
+
+// ColumnBatch - rows of the select in the columnar form, see ArrowRecord.
+// Values[i] is a typed slice according to Schema[i].Type: []bool, []int16, []int32, []int64, []float32, []float64,
+// []string, [][]byte, []int32 (date32), []int64 (timestamp). Valid[i][row] is false for NULL
+type ColumnBatch struct {
+	Schema []ColumnSchema
+	Values []any
+	Valid  [][]bool
+	Rows   int
+}
+
+// ColumnBatchWriter - consumer of columnar batches, see ArrowRecordWriter and ParquetWriter
+type ColumnBatchWriter interface {
+	WriteBatch(batch *ColumnBatch) error
+}
+
+// ArrowTypeForOID - Arrow type for the PostgreSQL type
+func ArrowTypeForOID(oid uint32) ArrowType {
+	switch oid {
+	case pgtype.BoolOID:
+		return ArrowBool
+	case pgtype.Int2OID:
+		return ArrowInt16
+	case pgtype.Int4OID:
+		return ArrowInt32
+	case pgtype.Int8OID, pgtype.OIDOID:
+		// oid is unsigned 32-bit, it doesn't fit int32
+		return ArrowInt64
+	case pgtype.Float4OID:
+		return ArrowFloat32
+	case pgtype.Float8OID:
+		return ArrowFloat64
+	case pgtype.ByteaOID:
+		return ArrowBinary
+	case pgtype.DateOID:
+		return ArrowDate32
+	case pgtype.TimestampOID, pgtype.TimestamptzOID:
+		return ArrowTimestamp
+	default:
+		return ArrowString
+	}
+}
+
+// ColumnSchemaOf - columnar schema of the select fields
+func ColumnSchemaOf(fields []pgproto3.FieldDescription) []ColumnSchema {
+	res := make([]ColumnSchema, len(fields))
+	for i, f := range fields {
+		res[i] = ColumnSchema{Name: string(f.Name), Type: ArrowTypeForOID(f.DataTypeOID), OID: f.DataTypeOID}
+	}
+	return res
+}
+
+// WriteColumnBatches - read the remaining rows of the select and pass them to w in batches of batchSize rows. The query is closed
+func (q *Query) WriteColumnBatches(w ColumnBatchWriter, batchSize int) error {
+	if batchSize <= 0 {
+		_ = q.Close()
+		return nerr.New(fmt.Errorf("invalid batch size: %d", batchSize))
+	}
+
+	fields := q.Fields()
+	schema := ColumnSchemaOf(fields)
+	batch := newColumnBatch(schema, batchSize)
+
+	for q.Next() {
+		values, err := q.ValuesShared()
+		if err != nil {
+			_ = q.Close()
+			return nerr.New(err)
+		}
+
+		for i, v := range values {
+			if err := batch.append(i, v, fields[i]); err != nil {
+				_ = q.Close()
+				return nerr.New(fmt.Errorf("field %s: %w", fields[i].Name, err))
+			}
+		}
+		batch.Rows++
+
+		if batch.Rows == batchSize {
+			if err := w.WriteBatch(batch); err != nil {
+				_ = q.Close()
+				return err
+			}
+			batch = newColumnBatch(schema, batchSize)
+		}
+	}
+
+	if err := q.Close(); err != nil {
+		return nerr.New(err)
+	}
+
+	if batch.Rows > 0 {
+		return w.WriteBatch(batch)
+	}
+	return nil
+}
+
+func newColumnBatch(schema []ColumnSchema, capacity int) *ColumnBatch {
+	b := &ColumnBatch{
+		Schema: schema,
+		Values: make([]any, len(schema)),
+		Valid:  make([][]bool, len(schema)),
+	}
+
+	for i, c := range schema {
+		b.Valid[i] = make([]bool, 0, capacity)
+		switch c.Type {
+		case ArrowBool:
+			b.Values[i] = make([]bool, 0, capacity)
+		case ArrowInt16:
+			b.Values[i] = make([]int16, 0, capacity)
+		case ArrowInt32, ArrowDate32:
+			b.Values[i] = make([]int32, 0, capacity)
+		case ArrowInt64, ArrowTimestamp:
+			b.Values[i] = make([]int64, 0, capacity)
+		case ArrowFloat32:
+			b.Values[i] = make([]float32, 0, capacity)
+		case ArrowFloat64:
+			b.Values[i] = make([]float64, 0, capacity)
+		case ArrowBinary:
+			b.Values[i] = make([][]byte, 0, capacity)
+		default:
+			b.Values[i] = make([]string, 0, capacity)
+		}
+	}
+
+	return b
+}
+
+// append - add the value to the column i. NULL is stored as the zero value with Valid = false
+func (b *ColumnBatch) append(i int, v any, fd pgproto3.FieldDescription) error {
+	b.Valid[i] = append(b.Valid[i], v != nil)
+
+	var err error
+	switch col := b.Values[i].(type) {
+	case []bool:
+		var x bool
+		if v != nil {
+			err = assignValue(&x, v)
+		}
+		b.Values[i] = append(col, x)
+	case []int16:
+		var x int16
+		if v != nil {
+			err = assignValue(&x, v)
+		}
+		b.Values[i] = append(col, x)
+	case []int32:
+		var x int32
+		if v != nil {
+			if b.Schema[i].Type == ArrowDate32 {
+				var t time.Time
+				if err = assignValue(&t, v); err == nil {
+					x = int32(math.Floor(float64(t.Unix()) / 86400))
+				}
+			} else {
+				err = assignValue(&x, v)
+			}
+		}
+		b.Values[i] = append(col, x)
+	case []int64:
+		var x int64
+		if v != nil {
+			if b.Schema[i].Type == ArrowTimestamp {
+				var t time.Time
+				if err = assignValue(&t, v); err == nil {
+					x = t.UnixMicro()
+				}
+			} else {
+				err = assignValue(&x, v)
+			}
+		}
+		b.Values[i] = append(col, x)
+	case []float32:
+		var x float32
+		if v != nil {
+			err = assignValue(&x, v)
+		}
+		b.Values[i] = append(col, x)
+	case []float64:
+		var x float64
+		if v != nil {
+			err = assignValue(&x, v)
+		}
+		b.Values[i] = append(col, x)
+	case [][]byte:
+		var x []byte
+		if v != nil {
+			err = assignValue(&x, v)
+		}
+		b.Values[i] = append(col, x)
+	case []string:
+		var x string
+		if v != nil {
+			x, err = ExportOptions{}.exportText(v, fd)
+		}
+		b.Values[i] = append(col, x)
+	}
+
+	return err
+}
@@ -0,0 +1,67 @@
+package sqlq
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/n-r-w/nerr"
+)
+
+// ConnectOption - Connect option, changes the pool configuration parsed from the connection string
+type ConnectOption func(cfg *pgxpool.Config)
+
+// WithPgxLogger - driver logging of the pool connections: pgx logs the statements, connection events and errors
+// with the level not lower than level
+func WithPgxLogger(logger pgx.Logger, level pgx.LogLevel) ConnectOption {
+	return func(cfg *pgxpool.Config) {
+		cfg.ConnConfig.Logger = logger
+		cfg.ConnConfig.LogLevel = level
+	}
+}
+
+// WithPoolConfig - arbitrary change of the pool configuration: MaxConns, AfterConnect etc.
+func WithPoolConfig(f func(cfg *pgxpool.Config)) ConnectOption {
+	return func(cfg *pgxpool.Config) {
+		f(cfg)
+	}
+}
+
+// Connect - create the pool for the connection string (postgres://... or key=value) with the options
+func Connect(ctx context.Context, connString string, opts ...ConnectOption) (*pgxpool.Pool, error) {
+	cfg, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		return nil, nerr.New(err)
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	pool, err := pgxpool.ConnectConfig(ctx, cfg)
+	if err != nil {
+		return nil, nerr.New(err)
+	}
+	return pool, nil
+}
+
+// PgxLogger - Logger that writes the statements to pgx.Logger: successful statements with the level,
+// failed ones with pgx.LogLevelError. For using the driver logger for the statements logged by sqlq
+func PgxLogger(logger pgx.Logger, level pgx.LogLevel) Logger {
+	return LoggerFunc(func(ctx context.Context, entry LogEntry) {
+		data := make(map[string]any, len(entry.Fields)+4)
+		for k, v := range entry.Fields {
+			data[k] = v
+		}
+		data["sql"] = entry.Sql
+		data["time"] = entry.Duration
+		data["rows"] = entry.Rows
+
+		if entry.Err != nil {
+			data["err"] = entry.Err
+			logger.Log(ctx, pgx.LogLevelError, "Statement", data)
+			return
+		}
+		logger.Log(ctx, level, "Statement", data)
+	})
+}
@@ -0,0 +1,99 @@
+package sqlq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+// pgxLogRecorder - pgx.Logger that keeps the messages
+type pgxLogRecorder struct {
+	mu      sync.Mutex
+	entries []pgxLogEntry
+}
+
+type pgxLogEntry struct {
+	level pgx.LogLevel
+	msg   string
+	data  map[string]any
+}
+
+func (r *pgxLogRecorder) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries = append(r.entries, pgxLogEntry{level: level, msg: msg, data: data})
+}
+
+func (r *pgxLogRecorder) messages() []pgxLogEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]pgxLogEntry{}, r.entries...)
+}
+
+func TestConnect(t *testing.T) {
+	srv, err := sqlqtest.NewFakePostgres()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	srv.Expect("UPDATE t SET a = 1", sqlqtest.Result{Tag: "UPDATE 1"})
+
+	logs := &pgxLogRecorder{}
+	pool, err := Connect(context.Background(), srv.ConnString(),
+		WithPgxLogger(logs, pgx.LogLevelInfo),
+		WithPoolConfig(func(cfg *pgxpool.Config) { cfg.MaxConns = 3 }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	if n := pool.Config().MaxConns; n != 3 {
+		t.Errorf("max conns %d, want 3", n)
+	}
+	if _, err := pool.Exec(context.Background(), "UPDATE t SET a = 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, e := range logs.messages() {
+		found = found || (e.msg == "Exec" && e.data["sql"] == "UPDATE t SET a = 1")
+	}
+	if !found {
+		t.Errorf("statement is not logged: %v", logs.messages())
+	}
+}
+
+func TestConnectInvalidConnString(t *testing.T) {
+	if _, err := Connect(context.Background(), "postgres://host:port/db"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestPgxLogger(t *testing.T) {
+	logs := &pgxLogRecorder{}
+	logger := PgxLogger(logs, pgx.LogLevelDebug)
+	failure := errors.New("failure")
+
+	logger.LogStatement(context.Background(), LogEntry{Sql: "SELECT 1", Duration: time.Second, Rows: 1, Fields: map[string]any{"user": 7}})
+	logger.LogStatement(context.Background(), LogEntry{Sql: "SELECT 2", Err: failure})
+
+	entries := logs.messages()
+	if len(entries) != 2 {
+		t.Fatalf("%d entries, want 2", len(entries))
+	}
+	if e := entries[0]; e.level != pgx.LogLevelDebug || e.data["sql"] != "SELECT 1" || e.data["time"] != time.Second ||
+		e.data["rows"] != int64(1) || e.data["user"] != 7 {
+		t.Errorf("entry %+v", e)
+	}
+	if e := entries[1]; e.level != pgx.LogLevelError || e.data["err"] != failure {
+		t.Errorf("entry %+v", e)
+	}
+}
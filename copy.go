@@ -0,0 +1,82 @@
+package sqlq
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/n-r-w/nerr"
+)
+
+// CopyFrom - bulk insert of the rows into the table with COPY FROM STDIN. tableName can be schema-qualified (schema.table).
+// Returns the number of inserted rows
+func CopyFrom(tx *Tx, tableName string, columns []string, rows [][]any) (int64, error) {
+	return CopyFromSource(tx, tableName, columns, pgx.CopyFromRows(rows))
+}
+
+// CopyFromSource - CopyFrom that reads the rows from the source, so they don't have to be kept in memory.
+// See pgx.CopyFromSlice to adapt a slice of structs
+func CopyFromSource(tx *Tx, tableName string, columns []string, src pgx.CopyFromSource) (int64, error) {
+	if tableName == "" {
+		return 0, nerr.New("empty table name")
+	}
+	if len(columns) == 0 {
+		return 0, nerr.New("no columns to copy")
+	}
+
+	copySql := "COPY " + quoteName(tableName) + " FROM STDIN"
+	n, err := copyFromSource(tx, copySql, tableName, columns, src)
+	if err != nil {
+		return n, err
+	}
+	return n, auditTx(tx, copySql, n)
+}
+
+func copyFromSource(tx *Tx, copySql string, tableName string, columns []string, src pgx.CopyFromSource) (int64, error) {
+	t, release, err := tx.use(copySql)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	n, err := t.CopyFrom(tx.ctx, pgx.Identifier(strings.Split(tableName, ".")), columns, src)
+	if err != nil {
+		return n, nerr.New(err)
+	}
+	return n, nil
+}
+
+// CopyFormat - data format of CopyTo
+type CopyFormat string
+
+const (
+	CopyText   CopyFormat = "text"
+	CopyCSV    CopyFormat = "csv"
+	CopyBinary CopyFormat = "binary"
+)
+
+// CopyTo - stream the result of the select into w with COPY (sql) TO STDOUT, without loading the rows into memory.
+// Returns the number of copied rows
+func CopyTo(tx *Tx, sql string, w io.Writer, format CopyFormat) (int64, error) {
+	switch format {
+	case CopyText, CopyCSV, CopyBinary:
+	default:
+		return 0, nerr.New(fmt.Errorf("unsupported copy format: %s", format))
+	}
+
+	sql = strings.TrimRight(strings.TrimSpace(sql), ";")
+	copySql := fmt.Sprintf("COPY (%s) TO STDOUT WITH (FORMAT %s)", sql, format)
+
+	t, release, err := tx.use(copySql)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	tag, err := t.Conn().PgConn().CopyTo(tx.ctx, w, copySql)
+	if err != nil {
+		return 0, nerr.New(err)
+	}
+	return tag.RowsAffected(), nil
+}
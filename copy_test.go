@@ -0,0 +1,145 @@
+package sqlq
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgtype"
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+// errCopySource - copy source that fails after the first row
+type errCopySource struct {
+	next bool
+	err  error
+}
+
+func (s *errCopySource) Next() bool {
+	s.next = !s.next
+	return s.next
+}
+
+func (s *errCopySource) Values() ([]any, error) { return []any{int64(1), "a"}, nil }
+
+func (s *errCopySource) Err() error {
+	if s.next {
+		return nil
+	}
+	return s.err
+}
+
+func expectCopyColumns(srv *sqlqtest.FakePostgres) {
+	// pgx takes the column types from the select
+	srv.Expect(`select "id", "name" from "public"."users"`, sqlqtest.Result{
+		Columns: []sqlqtest.Column{{Name: "id", OID: pgtype.Int8OID}, {Name: "name", OID: pgtype.TextOID}},
+	})
+}
+
+func TestCopyFrom(t *testing.T) {
+	srv, pool := newTestPool(t)
+	expectCopyColumns(srv)
+
+	data := make(chan []byte, 1)
+	srv.Handle(func(sql string, args []any) (sqlqtest.Result, bool) {
+		if sql != `copy "public"."users" ( "id", "name" ) from stdin binary;` {
+			return sqlqtest.Result{}, false
+		}
+		data <- args[0].([]byte)
+		return sqlqtest.Result{}, true
+	})
+
+	var n int64
+	err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+		var err error
+		n, err = CopyFrom(tx, "public.users", []string{"id", "name"}, [][]any{{int64(1), "alice"}, {int64(2), nil}, {int64(3), "carol"}})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("copied %d rows, want 3", n)
+	}
+	if got := <-data; !bytes.Contains(got, []byte("alice")) || !bytes.Contains(got, []byte("carol")) {
+		t.Errorf("unexpected copy data %q", got)
+	}
+}
+
+func TestCopyFromErrors(t *testing.T) {
+	srv, pool := newTestPool(t)
+	expectCopyColumns(srv)
+	srv.Expect(`copy "public"."users" ( "id", "name" ) from stdin binary`, sqlqtest.Result{})
+
+	tests := []struct {
+		name      string
+		table     string
+		columns   []string
+		src       *errCopySource
+		wantState string
+	}{
+		{"empty table", "", []string{"id"}, nil, ""},
+		{"no columns", "public.users", nil, nil, ""},
+		{"source error", "public.users", []string{"id", "name"}, &errCopySource{err: errors.New("source failed")}, "57014"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+				src := tt.src
+				if src == nil {
+					src = &errCopySource{}
+				}
+				_, err := CopyFromSource(tx, tt.table, tt.columns, src)
+				return err
+			})
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if SqlState(err) != tt.wantState {
+				t.Errorf("sql state %q, want %q: %v", SqlState(err), tt.wantState, err)
+			}
+		})
+	}
+}
+
+func TestCopyTo(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect("COPY (SELECT id, name FROM users) TO STDOUT WITH (FORMAT csv)", sqlqtest.Result{
+		CopyData: []byte("1,alice\n2,bob\n"),
+	})
+	srv.ExpectError("COPY (SELECT id FROM missing) TO STDOUT WITH (FORMAT text)", "42P01", "relation does not exist")
+
+	var buf strings.Builder
+	err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+		n, err := CopyTo(tx, " SELECT id, name FROM users; ", &buf, CopyCSV)
+		if err == nil && n != 2 {
+			t.Errorf("copied %d rows, want 2", n)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "1,alice\n2,bob\n" {
+		t.Errorf("got %q", buf.String())
+	}
+
+	err = RunInTx(pool, context.Background(), func(tx *Tx) error {
+		_, err := CopyTo(tx, "SELECT id FROM missing", &buf, CopyText)
+		return err
+	})
+	if SqlState(err) != "42P01" {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err = RunInTx(pool, context.Background(), func(tx *Tx) error {
+		_, err := CopyTo(tx, "SELECT 1", &buf, "xml")
+		return err
+	})
+	if err == nil {
+		t.Error("unsupported format: expected error")
+	}
+}
@@ -0,0 +1,78 @@
+package sqlq
+
+import "fmt"
+
+// CryptAlgorithm - pgcrypto gen_salt algorithm
+type CryptAlgorithm string
+
+const (
+	CryptBlowfish CryptAlgorithm = "bf"
+	CryptMD5      CryptAlgorithm = "md5"
+	CryptXDES     CryptAlgorithm = "xdes"
+	CryptDES      CryptAlgorithm = "des"
+)
+
+// Crypt - expression for hashing a password: crypt(password, gen_salt(algorithm)). The password is sent as
+// a statement parameter. Requires the pgcrypto extension
+func Crypt(password string, algorithm CryptAlgorithm) Expr {
+	return Expr{Sql: "crypt(?, gen_salt(?))", Args: []any{password, string(algorithm)}}
+}
+
+// CryptRounds - same as Crypt, with the number of iterations for bf and xdes algorithms
+func CryptRounds(password string, algorithm CryptAlgorithm, rounds int) Expr {
+	return Expr{Sql: "crypt(?, gen_salt(?, ?))", Args: []any{password, string(algorithm), int32(rounds)}}
+}
+
+// CryptCheck - condition for checking the password against the hash stored in column (column is an sql expression).
+// The password is sent as a statement parameter
+func CryptCheck(column string, password string) Expr {
+	column = exprSql(column)
+	return Expr{Sql: fmt.Sprintf("(%s = crypt(?, %s))", column, column), Args: []any{password}}
+}
+
+// PgpSymEncrypt - expression for symmetric encryption of the data: pgp_sym_encrypt(data, key). The data and the key
+// are sent as statement parameters. Result type is bytea
+func PgpSymEncrypt(data string, key string) Expr {
+	return Expr{Sql: "pgp_sym_encrypt(?, ?)", Args: []any{data, key}}
+}
+
+// PgpSymDecrypt - expression for decrypting the bytea column (column is an sql expression).
+// The key is sent as a statement parameter. Result type is text
+func PgpSymDecrypt(column string, key string) Expr {
+	return Expr{Sql: fmt.Sprintf("pgp_sym_decrypt(%s, ?)", exprSql(column)), Args: []any{key}}
+}
+
+// CryptExpr - sql expression for hashing a password: crypt('password', gen_salt('bf')). Requires the pgcrypto extension
+//
+// Deprecated: the password gets into the sql text, use Crypt
+func CryptExpr(password string, algorithm CryptAlgorithm) string {
+	return fmt.Sprintf("crypt(%s, gen_salt(%s))", quoteLiteral(password), quoteLiteral(string(algorithm)))
+}
+
+// CryptExprRounds - same as CryptExpr, with the number of iterations for bf and xdes algorithms
+//
+// Deprecated: the password gets into the sql text, use CryptRounds
+func CryptExprRounds(password string, algorithm CryptAlgorithm, rounds int) string {
+	return fmt.Sprintf("crypt(%s, gen_salt(%s, %d))", quoteLiteral(password), quoteLiteral(string(algorithm)), rounds)
+}
+
+// CryptCheckExpr - sql condition for checking the password against the hash stored in column (column is an sql expression)
+//
+// Deprecated: the password gets into the sql text, use CryptCheck
+func CryptCheckExpr(column string, password string) string {
+	return fmt.Sprintf("(%s = crypt(%s, %s))", column, quoteLiteral(password), column)
+}
+
+// PgpSymEncryptExpr - sql expression for symmetric encryption of the data: pgp_sym_encrypt('data', 'key'). Result type is bytea
+//
+// Deprecated: the data and the key get into the sql text, use PgpSymEncrypt
+func PgpSymEncryptExpr(data string, key string) string {
+	return fmt.Sprintf("pgp_sym_encrypt(%s, %s)", quoteLiteral(data), quoteLiteral(key))
+}
+
+// PgpSymDecryptExpr - sql expression for decrypting the bytea column (column is an sql expression). Result type is text
+//
+// Deprecated: the key gets into the sql text, use PgpSymDecrypt
+func PgpSymDecryptExpr(column string, key string) string {
+	return fmt.Sprintf("pgp_sym_decrypt(%s, %s)", column, quoteLiteral(key))
+}
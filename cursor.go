@@ -0,0 +1,139 @@
+package sqlq
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/n-r-w/nerr"
+)
+
+// number of the last declared cursor, for unique cursor names
+var cursorCounter uint64
+
+// Cursor - server side cursor of the transaction. The rows are fetched in batches of a limited size, so a result set
+// of any size is read with bounded memory. Between the fetches the transaction can be used for other statements
+type Cursor struct {
+	tx        *Tx
+	name      string
+	batchSize int
+
+	// current batch for Next
+	q    *Query
+	done bool
+	err  error
+}
+
+// OpenCursor - declare the cursor for the select with $1, $2... parameters. batchSize - number of rows fetched at once
+func OpenCursor(tx *Tx, sql string, batchSize int, args ...any) (*Cursor, error) {
+	if batchSize <= 0 {
+		return nil, nerr.New("cursor batch size must be positive")
+	}
+
+	c := &Cursor{
+		tx:        tx,
+		name:      fmt.Sprintf("sqlq_cursor_%d", atomic.AddUint64(&cursorCounter, 1)),
+		batchSize: batchSize,
+	}
+
+	if err := NewQueryTx(tx, tx.ctx).ExecArgs(fmt.Sprintf("DECLARE %s NO SCROLL CURSOR FOR %s", c.name, sql), args...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// FetchBatch - fetch the next batch of rows into memory. Returns nil when there are no more rows
+func (c *Cursor) FetchBatch() (*Query, error) {
+	if c.done {
+		return nil, nil
+	}
+
+	q := NewQueryTx(c.tx, c.tx.ctx)
+	if err := q.Select(fmt.Sprintf("FETCH FORWARD %d FROM %s", c.batchSize, c.name)); err != nil {
+		return nil, err
+	}
+
+	res, err := readCachedResult(q.rows)
+	if err != nil {
+		return nil, nerr.New(err)
+	}
+
+	if len(res.values) < c.batchSize {
+		c.done = true
+	}
+	if len(res.values) == 0 {
+		return nil, nil
+	}
+
+	q.setRows(newCachedRows(res))
+	return q, nil
+}
+
+// Next - move to the next row, fetching the next batch if necessary. The values of the row are read with Query.
+// Returns false when there are no more rows or on error, see Err
+func (c *Cursor) Next() bool {
+	if c.err != nil {
+		return false
+	}
+
+	for {
+		if c.q != nil && c.q.Next() {
+			return true
+		}
+
+		c.q, c.err = c.FetchBatch()
+		if c.err != nil || c.q == nil {
+			return false
+		}
+	}
+}
+
+// Query - the batch with the current row of Next
+func (c *Cursor) Query() *Query {
+	return c.q
+}
+
+// Err - error of Next
+func (c *Cursor) Err() error {
+	return c.err
+}
+
+// Close - close the cursor. The cursor is also closed at the end of the transaction
+func (c *Cursor) Close() error {
+	c.done = true
+	c.q = nil
+	return NewQueryTx(c.tx, c.tx.ctx).Exec("CLOSE " + c.name)
+}
+
+// SelectChunks - execute the select in a read only transaction and call fn for each chunk of at most chunkSize rows.
+// The chunk is read with Next. Only one chunk is kept in memory, the rows are fetched with a cursor
+func SelectChunks(pool *pgxpool.Pool, ctx context.Context, sql string, chunkSize int, fn func(q *Query) error) error {
+	return RunInReadTx(pool, ctx, func(tx *Tx) error {
+		return SelectTxChunks(tx, sql, chunkSize, fn)
+	})
+}
+
+// SelectTxChunks - SelectChunks in the transaction
+func SelectTxChunks(tx *Tx, sql string, chunkSize int, fn func(q *Query) error) error {
+	c, err := OpenCursor(tx, sql, chunkSize)
+	if err != nil {
+		return err
+	}
+
+	for {
+		q, err := c.FetchBatch()
+		if err != nil {
+			_ = c.Close()
+			return err
+		}
+		if q == nil {
+			return c.Close()
+		}
+
+		if err := fn(q); err != nil {
+			_ = c.Close()
+			return err
+		}
+	}
+}
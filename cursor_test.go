@@ -0,0 +1,170 @@
+package sqlq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgtype"
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+// cursorServer - handler of the cursor statements for the select of the rows
+type cursorServer struct {
+	mu      sync.Mutex
+	rows    []int
+	pos     int
+	fetches []int
+	closed  bool
+}
+
+func (s *cursorServer) handle(sql string, args []any) (sqlqtest.Result, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(sql, "DECLARE sqlq_cursor_") && strings.HasSuffix(sql, " NO SCROLL CURSOR FOR SELECT id FROM t"):
+		return sqlqtest.Result{Tag: "DECLARE CURSOR"}, true
+
+	case strings.HasPrefix(sql, "CLOSE sqlq_cursor_"):
+		s.closed = true
+		return sqlqtest.Result{Tag: "CLOSE CURSOR"}, true
+
+	case strings.HasPrefix(sql, "FETCH FORWARD "):
+		var n int
+		if _, err := fmt.Sscanf(sql, "FETCH FORWARD %d FROM", &n); err != nil {
+			return sqlqtest.Result{}, false
+		}
+		res := sqlqtest.Result{Columns: []sqlqtest.Column{{Name: "id", OID: pgtype.Int8OID}}}
+		for ; n > 0 && s.pos < len(s.rows); n-- {
+			res.Rows = append(res.Rows, []any{s.rows[s.pos]})
+			s.pos++
+		}
+		res.Tag = fmt.Sprintf("FETCH %d", len(res.Rows))
+		s.fetches = append(s.fetches, len(res.Rows))
+		return res, true
+	}
+	return sqlqtest.Result{}, false
+}
+
+func (s *cursorServer) state() ([]int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]int{}, s.fetches...), s.closed
+}
+
+func TestCursorBatches(t *testing.T) {
+	tests := []struct {
+		name        string
+		rows        int
+		batchSize   int
+		wantFetches []int
+	}{
+		// the short batch is the last one
+		{"short last batch", 5, 2, []int{2, 2, 1}},
+		{"full last batch", 4, 2, []int{2, 2, 0}},
+		{"no rows", 0, 2, []int{0}},
+		{"one batch", 3, 10, []int{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, pool := newTestPool(t)
+			cs := &cursorServer{}
+			for i := 0; i < tt.rows; i++ {
+				cs.rows = append(cs.rows, i)
+			}
+			srv.Handle(cs.handle)
+
+			var got []int
+			err := RunInReadTx(pool, context.Background(), func(tx *Tx) error {
+				c, err := OpenCursor(tx, "SELECT id FROM t", tt.batchSize)
+				if err != nil {
+					return err
+				}
+				for c.Next() {
+					got = append(got, int(c.Query().ValueIndex(0).(int64)))
+				}
+				if c.Err() != nil {
+					return c.Err()
+				}
+				// no more fetches after the end
+				if c.Next() {
+					return errors.New("row after the end")
+				}
+				return c.Close()
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, cs.rows) {
+				t.Errorf("rows %v, want %v", got, cs.rows)
+			}
+			if fetches, closed := cs.state(); !reflect.DeepEqual(fetches, tt.wantFetches) || !closed {
+				t.Errorf("fetches %v, want %v, closed %v", fetches, tt.wantFetches, closed)
+			}
+		})
+	}
+}
+
+func TestOpenCursorBatchSize(t *testing.T) {
+	_, pool := newTestPool(t)
+	tx := NewTx(pool, context.Background())
+
+	if _, err := OpenCursor(tx, "SELECT id FROM t", 0); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestSelectChunks(t *testing.T) {
+	srv, pool := newTestPool(t)
+	cs := &cursorServer{rows: []int{0, 1, 2, 3, 4}}
+	srv.Handle(cs.handle)
+
+	var chunks [][]int
+	err := SelectChunks(pool, context.Background(), "SELECT id FROM t", 2, func(q *Query) error {
+		var chunk []int
+		for q.Next() {
+			chunk = append(chunk, int(q.ValueIndex(0).(int64)))
+		}
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := [][]int{{0, 1}, {2, 3}, {4}}; !reflect.DeepEqual(chunks, want) {
+		t.Errorf("chunks %v, want %v", chunks, want)
+	}
+	if _, closed := cs.state(); !closed {
+		t.Error("cursor is not closed")
+	}
+}
+
+func TestSelectChunksStops(t *testing.T) {
+	srv, pool := newTestPool(t)
+	cs := &cursorServer{rows: []int{0, 1, 2, 3, 4}}
+	srv.Handle(cs.handle)
+	failure := errors.New("failure")
+
+	calls := 0
+	err := SelectChunks(pool, context.Background(), "SELECT id FROM t", 2, func(q *Query) error {
+		calls++
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the next chunks are not fetched
+	if fetches, closed := cs.state(); calls != 1 || len(fetches) != 1 || !closed {
+		t.Errorf("calls %d, fetches %v, closed %v", calls, fetches, closed)
+	}
+}
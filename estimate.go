@@ -0,0 +1,50 @@
+package sqlq
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/n-r-w/nerr"
+)
+
+// EstimateCount - approximate number of rows returned by the query, according to the planner estimate (EXPLAIN).
+// Much faster than count(*) on huge tables, but the result depends on the freshness of the statistics
+func EstimateCount(pool *pgxpool.Pool, ctx context.Context, sql string) (int64, error) {
+	q, err := SelectRow(pool, ctx, "EXPLAIN (FORMAT JSON) "+sql)
+	if err != nil {
+		return 0, err
+	}
+	if q == nil {
+		return 0, nerr.New("empty explain result")
+	}
+
+	var plans []struct {
+		Plan struct {
+			Rows float64 `json:"Plan Rows"`
+		} `json:"Plan"`
+	}
+	if err := json.Unmarshal(q.Json("QUERY PLAN"), &plans); err != nil {
+		return 0, nerr.New(err)
+	}
+	if len(plans) == 0 {
+		return 0, nerr.New("empty explain result")
+	}
+
+	return int64(plans[0].Plan.Rows), nil
+}
+
+// EstimateTableCount - approximate number of rows in the table, according to pg_class.reltuples.
+// Returns -1 if the table has never been analyzed
+func EstimateTableCount(pool *pgxpool.Pool, ctx context.Context, table string) (int64, error) {
+	q, err := SelectRow(pool, ctx, fmt.Sprintf("SELECT reltuples::bigint AS estimate FROM pg_class WHERE oid = %s::regclass", quoteLiteral(table)))
+	if err != nil {
+		return 0, err
+	}
+	if q == nil {
+		return 0, nerr.New(fmt.Errorf("table not found: %s", table))
+	}
+
+	return q.Int64("estimate"), nil
+}
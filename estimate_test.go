@@ -0,0 +1,51 @@
+package sqlq
+
+import (
+	"context"
+	"testing"
+
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+func TestEstimateCount(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect("EXPLAIN (FORMAT JSON) SELECT * FROM events WHERE kind = 1",
+		explainResult(`[{"Plan": {"Node Type": "Seq Scan", "Plan Rows": 12345.6}}]`))
+	srv.Expect("EXPLAIN (FORMAT JSON) SELECT * FROM empty", explainResult(`[]`))
+
+	n, err := EstimateCount(pool, context.Background(), "SELECT * FROM events WHERE kind = 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 12345 {
+		t.Errorf("got %d, want 12345", n)
+	}
+
+	if _, err := EstimateCount(pool, context.Background(), "SELECT * FROM empty"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestEstimateTableCount(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect(`SELECT reltuples::bigint AS estimate FROM pg_class WHERE oid = 'app.events'::regclass`, sqlqtest.Result{
+		Columns: []sqlqtest.Column{{Name: "estimate"}},
+		Rows:    [][]any{{int64(-1)}},
+	})
+	srv.Expect(`SELECT reltuples::bigint AS estimate FROM pg_class WHERE oid = 'o''neil'::regclass`, sqlqtest.Result{
+		Columns: []sqlqtest.Column{{Name: "estimate"}},
+	})
+
+	// never analyzed
+	n, err := EstimateTableCount(pool, context.Background(), "app.events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != -1 {
+		t.Errorf("got %d, want -1", n)
+	}
+
+	if _, err := EstimateTableCount(pool, context.Background(), "o'neil"); err == nil {
+		t.Error("expected error")
+	}
+}
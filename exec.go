@@ -0,0 +1,323 @@
+package sqlq
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/n-r-w/nerr"
+)
+
+// execSql - execute the command through the middleware chain
+func (q *Query) execSql(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	ctx = q.middlewareContext(ctx)
+	f := ExecFunc(q.execDirect)
+	mws := q.middlewares()
+	for i := len(mws) - 1; i >= 0; i-- {
+		f = mws[i].Exec(f)
+	}
+	if m := q.statementMetrics(); m != nil {
+		f = metricsExec(m, f)
+	}
+	if logger := q.logger(); logger != nil {
+		return q.logExec(ctx, logger, f, sql, args)
+	}
+	return f(ctx, sql, args...)
+}
+
+// querySql - execute the select through the middleware chain
+func (q *Query) querySql(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	ctx = q.middlewareContext(ctx)
+	f := QueryFunc(q.queryDirect)
+	mws := q.middlewares()
+	for i := len(mws) - 1; i >= 0; i-- {
+		f = mws[i].Query(f)
+	}
+	if m := q.statementMetrics(); m != nil {
+		f = metricsQuery(m, f)
+	}
+	if logger := q.logger(); logger != nil {
+		return q.logQuery(ctx, logger, f, sql, args)
+	}
+	return f(ctx, sql, args...)
+}
+
+// execDirect - execute the command on the transaction or the pool
+func (q *Query) execDirect(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	timeout, hasTimeout := q.statementTimeout(ctx)
+	cache := q.statementCache(args)
+
+	if q.tx != nil {
+		tx, release, err := q.tx.use(sql)
+		if err != nil {
+			return nil, err
+		}
+		release = q.watchNotices(tx.Conn().PgConn(), release)
+		defer release()
+
+		if err := q.applyLocalSettings(ctx, tx, timeout, hasTimeout); err != nil {
+			return nil, err
+		}
+		if cache != nil {
+			return execPrepared(ctx, cache, tx.Conn(), sql, args)
+		}
+		return tx.Exec(ctx, sql, q.queryArgs(args)...)
+	}
+
+	if len(q.localSettings) > 0 {
+		return nil, nerr.New("local settings require a transaction")
+	}
+
+	if !hasTimeout && cache == nil && !q.dedicatedConn() {
+		return q.pool.Exec(ctx, sql, q.queryArgs(args)...)
+	}
+
+	c, release, err := q.acquire(ctx, timeout, hasTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer release(c)
+
+	if cache != nil {
+		return execPrepared(ctx, cache, c.Conn(), sql, args)
+	}
+	return c.Exec(ctx, sql, q.queryArgs(args)...)
+}
+
+// queryDirect - execute the select on the transaction or the pool
+func (q *Query) queryDirect(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	timeout, hasTimeout := q.statementTimeout(ctx)
+	cache := q.statementCache(args)
+
+	if q.tx != nil {
+		tx, release, err := q.tx.use(sql)
+		if err != nil {
+			return nil, err
+		}
+		release = q.watchNotices(tx.Conn().PgConn(), release)
+
+		// type information of the fields is taken from the connection of the select
+		q.typeInfo = tx.Conn().ConnInfo()
+
+		var rows pgx.Rows
+		if err = q.applyLocalSettings(ctx, tx, timeout, hasTimeout); err == nil {
+			if cache != nil {
+				rows, err = queryPrepared(ctx, cache, tx.Conn(), sql, args)
+			} else {
+				rows, err = tx.Query(ctx, sql, q.queryArgs(args)...)
+			}
+		}
+		if err != nil {
+			release()
+			return nil, err
+		}
+
+		// the transaction is busy until the rows are closed
+		return &closeHookRows{Rows: rows, onClose: func(pgx.Rows) { release() }}, nil
+	}
+
+	if len(q.localSettings) > 0 {
+		return nil, nerr.New("local settings require a transaction")
+	}
+
+	// the connection is acquired explicitly to capture its type information
+	c, release, err := q.acquire(ctx, timeout, hasTimeout)
+	if err != nil {
+		return nil, err
+	}
+	q.typeInfo = c.Conn().ConnInfo()
+
+	var rows pgx.Rows
+	if cache != nil {
+		rows, err = queryPrepared(ctx, cache, c.Conn(), sql, args)
+	} else {
+		rows, err = c.Query(ctx, sql, q.queryArgs(args)...)
+	}
+	if err != nil {
+		release(c)
+		return nil, err
+	}
+
+	return &closeHookRows{Rows: rows, onClose: func(pgx.Rows) { release(c) }}, nil
+}
+
+// acquire - dedicated connection for the statement, with the session statement_timeout if needed
+func (q *Query) acquire(ctx context.Context, timeout time.Duration, hasTimeout bool) (*pgxpool.Conn, func(*pgxpool.Conn), error) {
+	if m := q.statementMetrics(); m != nil {
+		start := time.Now()
+		defer func() { m.ConnAcquired(time.Since(start)) }()
+	}
+
+	var c *pgxpool.Conn
+	var err error
+	release := (*pgxpool.Conn).Release
+	if hasTimeout {
+		c, err = acquireWithTimeout(q.pool, ctx, timeout)
+		release = releaseWithTimeout
+	} else {
+		c, err = acquirePoolConn(q.pool, ctx)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	watched := q.watchNotices(c.Conn().PgConn(), func() { release(c) })
+	return c, func(*pgxpool.Conn) { watched() }, nil
+}
+
+// dedicatedConn - the connection of the statement outside of a transaction is acquired explicitly:
+// to measure the wait or to receive the notices
+func (q *Query) dedicatedConn() bool {
+	return q.statementMetrics() != nil || q.noticeHandler() != nil || poolMonitor(q.pool) != nil
+}
+
+// statementCache - statement cache for the statement, nil if the statement is not cached
+func (q *Query) statementCache(args []any) *StatementCache {
+	if len(args) == 0 || q.protocol == ProtocolSimple {
+		return nil
+	}
+	if q.stmtCache != nil {
+		return q.stmtCache
+	}
+	if q.tx != nil {
+		return q.tx.stmtCache
+	}
+	return nil
+}
+
+// execPrepared - execute the command as a prepared statement from the cache
+func execPrepared(ctx context.Context, cache *StatementCache, conn *pgx.Conn, sql string, args []any) (pgconn.CommandTag, error) {
+	name, err := cache.prepare(ctx, conn, sql)
+	if err != nil {
+		return nil, err
+	}
+	return conn.Exec(ctx, name, args...)
+}
+
+// queryPrepared - execute the select as a prepared statement from the cache
+func queryPrepared(ctx context.Context, cache *StatementCache, conn *pgx.Conn, sql string, args []any) (pgx.Rows, error) {
+	name, err := cache.prepare(ctx, conn, sql)
+	if err != nil {
+		return nil, err
+	}
+	return conn.Query(ctx, name, args...)
+}
+
+// applyLocalSettings - SET LOCAL of the transaction and query settings before the statement
+func (q *Query) applyLocalSettings(ctx context.Context, tx pgx.Tx, timeout time.Duration, hasTimeout bool) error {
+	settings := make(map[string]string, len(q.tx.localSettings)+len(q.localSettings)+1)
+	for k, v := range q.tx.localSettings {
+		settings[k] = v
+	}
+	for k, v := range q.localSettings {
+		settings[k] = v
+	}
+	if hasTimeout {
+		settings["statement_timeout"] = strconv.FormatInt(timeout.Milliseconds(), 10)
+	}
+
+	if len(settings) == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(ctx, setLocalSql(settings), pgx.QuerySimpleProtocol(true))
+	return err
+}
+
+// setLocalSql - statement that sets the transaction level settings
+func setLocalSql(settings map[string]string) string {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	calls := make([]string, len(names))
+	for i, name := range names {
+		calls[i] = fmt.Sprintf("set_config(%s, %s, true)", quoteLiteral(name), quoteLiteral(settings[name]))
+	}
+	return "SELECT " + strings.Join(calls, ", ")
+}
+
+// Protocol - wire protocol of the statements
+type Protocol int
+
+const (
+	// ProtocolAuto - the simple protocol for statements without parameters,
+	// the extended protocol with binary encoded parameters otherwise
+	ProtocolAuto Protocol = iota
+	// ProtocolSimple - always the simple protocol, parameters are substituted into the sql text by pgx.
+	// Compatible with pgbouncer in the transaction mode, the statement cache is not used
+	ProtocolSimple
+	// ProtocolExtended - always the extended protocol with binary results, faster for large result sets
+	ProtocolExtended
+)
+
+// SetProtocol - wire protocol of the statements of the query, ProtocolAuto by default
+func (q *Query) SetProtocol(protocol Protocol) {
+	q.protocol = protocol
+}
+
+// queryArgs - pgx arguments with the protocol option
+func (q *Query) queryArgs(args []any) []any {
+	switch {
+	case q.protocol == ProtocolSimple:
+		return append([]any{pgx.QuerySimpleProtocol(true)}, args...)
+	case q.protocol == ProtocolExtended:
+		return append([]any{pgx.QuerySimpleProtocol(false)}, args...)
+	case len(args) == 0:
+		return []any{pgx.QuerySimpleProtocol(true)}
+	default:
+		return args
+	}
+}
+
+// statementTimeout - statement_timeout that corresponds to the context deadline
+func (q *Query) statementTimeout(ctx context.Context) (time.Duration, bool) {
+	if !q.deadlineTimeout {
+		return 0, false
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+
+	timeout := time.Until(deadline)
+	if timeout < time.Millisecond {
+		// 0 means no timeout
+		timeout = time.Millisecond
+	}
+
+	return timeout, true
+}
+
+// acquireWithTimeout - acquire a connection and set the session statement_timeout
+func acquireWithTimeout(pool *pgxpool.Pool, ctx context.Context, timeout time.Duration) (*pgxpool.Conn, error) {
+	c, err := acquirePoolConn(pool, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := c.Exec(ctx, fmt.Sprintf("SET statement_timeout = %d", timeout.Milliseconds())); err != nil {
+		c.Release()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// releaseWithTimeout - reset the session statement_timeout and return the connection to the pool.
+// If the reset fails, the connection is closed so that it is not reused with the modified setting
+func releaseWithTimeout(c *pgxpool.Conn) {
+	if _, err := c.Exec(context.Background(), "RESET statement_timeout"); err != nil {
+		_ = c.Conn().Close(context.Background())
+	}
+	c.Release()
+}
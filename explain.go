@@ -0,0 +1,111 @@
+package sqlq
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/n-r-w/nerr"
+)
+
+// Plan - execution plan of the statement, see Query.Explain
+type Plan struct {
+	Root PlanNode `json:"Plan"`
+	// PlanningTime, ExecutionTime - only for EXPLAIN ANALYZE
+	PlanningTime  float64 `json:"Planning Time"`
+	ExecutionTime float64 `json:"Execution Time"`
+}
+
+// PlanNode - node of the plan. Costs are in the planner units, times are in milliseconds.
+// Actual* fields are filled only for EXPLAIN ANALYZE
+type PlanNode struct {
+	NodeType     string  `json:"Node Type"`
+	RelationName string  `json:"Relation Name"`
+	Alias        string  `json:"Alias"`
+	IndexName    string  `json:"Index Name"`
+	JoinType     string  `json:"Join Type"`
+	StartupCost  float64 `json:"Startup Cost"`
+	TotalCost    float64 `json:"Total Cost"`
+	PlanRows     float64 `json:"Plan Rows"`
+	PlanWidth    int     `json:"Plan Width"`
+	IndexCond    string  `json:"Index Cond"`
+	Filter       string  `json:"Filter"`
+
+	ActualStartupTime float64 `json:"Actual Startup Time"`
+	ActualTotalTime   float64 `json:"Actual Total Time"`
+	ActualRows        float64 `json:"Actual Rows"`
+	ActualLoops       float64 `json:"Actual Loops"`
+
+	Plans []PlanNode `json:"Plans"`
+}
+
+// TotalCost - estimated total cost of the statement
+func (p *Plan) TotalCost() float64 {
+	return p.Root.TotalCost
+}
+
+// ActualTime - execution time of EXPLAIN ANALYZE, 0 without ANALYZE
+func (p *Plan) ActualTime() time.Duration {
+	return time.Duration(p.ExecutionTime * float64(time.Millisecond))
+}
+
+// Nodes - all nodes of the plan, depth first
+func (p *Plan) Nodes() []PlanNode {
+	var res []PlanNode
+	var walk func(n PlanNode)
+	walk = func(n PlanNode) {
+		res = append(res, n)
+		for _, c := range n.Plans {
+			walk(c)
+		}
+	}
+	walk(p.Root)
+	return res
+}
+
+// HasNode - the plan contains a node of the type: "Seq Scan", "Index Scan", "Hash Join" etc.
+// relation - optional table name of the node
+func (p *Plan) HasNode(nodeType string, relation string) bool {
+	for _, n := range p.Nodes() {
+		if n.NodeType == nodeType && (relation == "" || n.RelationName == relation) {
+			return true
+		}
+	}
+	return false
+}
+
+// Explain - execution plan of the statement (EXPLAIN (FORMAT JSON)). With analyze the statement is executed
+// (EXPLAIN ANALYZE), so the changes of insert, update, delete are applied unless the transaction is rolled back
+func (q *Query) Explain(sql string, analyze bool) (Plan, error) {
+	explain := "EXPLAIN (FORMAT JSON) "
+	if analyze {
+		explain = "EXPLAIN (ANALYZE, FORMAT JSON) "
+	}
+
+	ok, err := q.SelectRow(explain + sql)
+	if err != nil {
+		return Plan{}, err
+	}
+	if !ok {
+		return Plan{}, nerr.New("empty explain result")
+	}
+
+	var plans []Plan
+	if err := json.Unmarshal(q.Json("QUERY PLAN"), &plans); err != nil {
+		return Plan{}, nerr.New(err)
+	}
+	if len(plans) == 0 {
+		return Plan{}, nerr.New("empty explain result")
+	}
+
+	return plans[0], nil
+}
+
+func Explain(pool *pgxpool.Pool, ctx context.Context, sql string, analyze bool) (Plan, error) {
+	return NewQuery(pool, ctx).Explain(sql, analyze)
+}
+
+func ExplainTx(tx *Tx, sql string, analyze bool) (Plan, error) {
+	return NewQueryTx(tx, tx.ctx).Explain(sql, analyze)
+}
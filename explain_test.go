@@ -0,0 +1,74 @@
+package sqlq
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+const testPlan = `[{
+	"Plan": {
+		"Node Type": "Hash Join", "Join Type": "Inner", "Total Cost": 42.5, "Actual Rows": 3,
+		"Plans": [
+			{"Node Type": "Seq Scan", "Relation Name": "orders", "Total Cost": 20},
+			{"Node Type": "Hash", "Plans": [
+				{"Node Type": "Index Scan", "Relation Name": "users", "Index Name": "users_pkey"}
+			]}
+		]
+	},
+	"Planning Time": 0.1,
+	"Execution Time": 1.5
+}]`
+
+func explainResult(plan string) sqlqtest.Result {
+	return sqlqtest.Result{
+		Columns: []sqlqtest.Column{{Name: "QUERY PLAN", OID: pgtype.JSONOID}},
+		Rows:    [][]any{{plan}},
+	}
+}
+
+func TestExplain(t *testing.T) {
+	srv, pool := newTestPool(t)
+	const sql = "SELECT * FROM orders JOIN users ON users.id = orders.user_id"
+	srv.Expect("EXPLAIN (ANALYZE, FORMAT JSON) "+sql, explainResult(testPlan))
+
+	plan, err := Explain(pool, context.Background(), sql, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if plan.TotalCost() != 42.5 || plan.Root.ActualRows != 3 {
+		t.Errorf("root %+v", plan.Root)
+	}
+	if plan.ActualTime() != 1500*time.Microsecond {
+		t.Errorf("actual time %v", plan.ActualTime())
+	}
+
+	var types []string
+	for _, n := range plan.Nodes() {
+		types = append(types, n.NodeType)
+	}
+	if want := "Hash Join,Seq Scan,Hash,Index Scan"; strings.Join(types, ",") != want {
+		t.Errorf("nodes %v, want %s", types, want)
+	}
+
+	if !plan.HasNode("Index Scan", "users") || !plan.HasNode("Seq Scan", "") {
+		t.Error("node is not found")
+	}
+	if plan.HasNode("Seq Scan", "users") {
+		t.Error("unexpected node")
+	}
+}
+
+func TestExplainEmpty(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect("EXPLAIN (FORMAT JSON) SELECT 1", explainResult("[]"))
+
+	if _, err := Explain(pool, context.Background(), "SELECT 1", false); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,177 @@
+package sqlq
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgtype"
+	"github.com/n-r-w/nerr"
+)
+
+// ExportOptions - formatting of values in CSV and JSON export
+type ExportOptions struct {
+	// DecimalSeparator - separator of the fractional part of numbers. Empty - ".". In JSON numbers with another separator are written as strings
+	DecimalSeparator string
+	// TimeLayout - layout of timestamps. Empty - time.RFC3339Nano
+	TimeLayout string
+	// DateLayout - layout of dates. Empty - "2006-01-02"
+	DateLayout string
+	// Location - timezone of timestamps. nil - as received from the database
+	Location *time.Location
+	// Comma - CSV field delimiter. 0 - ','
+	Comma rune
+	// NullValue - CSV representation of NULL
+	NullValue string
+	// NoHeader - do not write the CSV header with field names
+	NoHeader bool
+}
+
+func (o ExportOptions) decimalSeparator() string {
+	if o.DecimalSeparator == "" {
+		return "."
+	}
+	return o.DecimalSeparator
+}
+
+// formatNumber - number in the text form with the decimal separator
+func (o ExportOptions) formatNumber(s string) string {
+	if sep := o.decimalSeparator(); sep != "." {
+		return strings.Replace(s, ".", sep, 1)
+	}
+	return s
+}
+
+// formatTime - timestamp or date according to the field type
+func (o ExportOptions) formatTime(t time.Time, fd pgproto3.FieldDescription) string {
+	if fd.DataTypeOID == pgtype.DateOID {
+		layout := o.DateLayout
+		if layout == "" {
+			layout = "2006-01-02"
+		}
+		return t.Format(layout)
+	}
+
+	if o.Location != nil {
+		t = t.In(o.Location)
+	}
+	layout := o.TimeLayout
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	return t.Format(layout)
+}
+
+// numberText - text form of a numeric value. false - the value is not a number
+func numberText(v any) (string, bool) {
+	switch d := v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", d), true
+	case float32:
+		return strconv.FormatFloat(float64(d), 'f', -1, 32), true
+	case float64:
+		return strconv.FormatFloat(d, 'f', -1, 64), true
+	case pgtype.Numeric:
+		if d.Status != pgtype.Present || d.NaN || d.InfinityModifier != pgtype.None {
+			return "", false
+		}
+		text, err := d.EncodeText(nil, nil)
+		if err != nil {
+			return "", false
+		}
+		return string(text), true
+	}
+	return "", false
+}
+
+// exportText - text form of the value for CSV
+func (o ExportOptions) exportText(v any, fd pgproto3.FieldDescription) (string, error) {
+	if v == nil {
+		return o.NullValue, nil
+	}
+	if n, ok := numberText(v); ok {
+		return o.formatNumber(n), nil
+	}
+
+	switch d := v.(type) {
+	case time.Time:
+		return o.formatTime(d, fd), nil
+	case []byte:
+		return string(d), nil
+	case [16]byte:
+		return formatUUID(d), nil
+	case pgtype.TextEncoder:
+		text, err := d.EncodeText(nil, nil)
+		if err != nil {
+			return "", err
+		}
+		if text == nil {
+			return o.NullValue, nil
+		}
+		return string(text), nil
+	case map[string]any, []any:
+		j, err := jsonValue(d, fd, o)
+		if err != nil {
+			return "", err
+		}
+		return string(j), nil
+	default:
+		return stringConvertHelper(d), nil
+	}
+}
+
+func formatUUID(d [16]byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", d[0:4], d[4:6], d[6:8], d[8:10], d[10:16])
+}
+
+// WriteCSV - write the remaining rows of the select to w in CSV format. The query is closed
+func (q *Query) WriteCSV(w io.Writer, opts ExportOptions) error {
+	cw := csv.NewWriter(w)
+	if opts.Comma != 0 {
+		cw.Comma = opts.Comma
+	}
+
+	fields := q.Fields()
+	if !opts.NoHeader {
+		header := make([]string, len(fields))
+		for i, f := range fields {
+			header[i] = string(f.Name)
+		}
+		if err := cw.Write(header); err != nil {
+			_ = q.Close()
+			return nerr.New(err)
+		}
+	}
+
+	record := make([]string, len(fields))
+	for q.Next() {
+		values, err := q.ValuesShared()
+		if err != nil {
+			_ = q.Close()
+			return nerr.New(err)
+		}
+
+		for i, v := range values {
+			if record[i], err = opts.exportText(v, fields[i]); err != nil {
+				_ = q.Close()
+				return nerr.New(fmt.Errorf("field %s: %w", fields[i].Name, err))
+			}
+		}
+
+		if err := cw.Write(record); err != nil {
+			_ = q.Close()
+			return nerr.New(err)
+		}
+	}
+
+	if err := q.Close(); err != nil {
+		return nerr.New(err)
+	}
+
+	cw.Flush()
+	return nerr.New(cw.Error())
+}
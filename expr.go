@@ -0,0 +1,132 @@
+package sqlq
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Expr - sql expression with ? parameters, its arguments are sent as statement parameters and never get into
+// the sql text: Expr{Sql: "pgp_sym_encrypt(?, ?)", Args: []any{data, key}}. ?? is a literal question mark.
+// Accepted as an argument of ExecArgs, SelectArgs, QueryBuilder conditions and Batch items ($n is replaced with the expression)
+// and as a value of the named binding: ExecBind, SelectBind, TemplateStore etc. (the placeholder is replaced with the expression)
+type Expr struct {
+	Sql  string
+	Args []any
+}
+
+// sql - the expression with $n parameters starting from offset + 1
+func (e Expr) sql(offset int) (string, error) {
+	sql, n, err := builderPlaceholders(e.Sql, offset)
+	if err != nil {
+		return "", err
+	}
+	if n != len(e.Args) {
+		return "", fmt.Errorf("expression %s has %d parameters, %d arguments", e.Sql, n, len(e.Args))
+	}
+	return sql, nil
+}
+
+// exprSql - sql text as a part of Expr.Sql without parameters: question marks outside of string literals,
+// quoted identifiers and comments are doubled
+func exprSql(sql string) string {
+	var b strings.Builder
+	for i := 0; i < len(sql); {
+		end, err := skipSqlToken(sql, i)
+		if err != nil {
+			// unterminated token, reported when the expression is rendered
+			b.WriteString(sql[i:])
+			break
+		}
+		if end > i {
+			b.WriteString(sql[i:end])
+			i = end
+			continue
+		}
+
+		if sql[i] == '?' {
+			b.WriteByte('?')
+		}
+		b.WriteByte(sql[i])
+		i++
+	}
+	return b.String()
+}
+
+// exprArgs - replace the $n parameters whose arguments are Expr with the expressions. Parameters are renumbered
+// in the order of appearance, the arguments of the expressions take their places
+func exprArgs(sql string, args []any) (string, []any, error) {
+	hasExpr := false
+	for _, a := range args {
+		if _, ok := a.(Expr); ok {
+			hasExpr = true
+			break
+		}
+	}
+	if !hasExpr {
+		return sql, args, nil
+	}
+
+	var (
+		b strings.Builder
+		// new sql text of the parameter $n
+		rendered = make(map[int]string)
+		res      []any
+	)
+
+	for i := 0; i < len(sql); {
+		end, err := skipSqlToken(sql, i)
+		if err != nil {
+			return "", nil, err
+		}
+		if end > i {
+			b.WriteString(sql[i:end])
+			i = end
+			continue
+		}
+
+		if sql[i] != '$' || (i > 0 && isIdentRune(rune(sql[i-1]))) {
+			b.WriteByte(sql[i])
+			i++
+			continue
+		}
+
+		end = i + 1
+		for end < len(sql) && sql[end] >= '0' && sql[end] <= '9' {
+			end++
+		}
+		n, err := strconv.Atoi(sql[i+1 : end])
+		if err != nil || n < 1 || n > len(args) {
+			b.WriteString(sql[i:end])
+			i = end
+			continue
+		}
+
+		s, ok := rendered[n]
+		if !ok {
+			if e, isExpr := args[n-1].(Expr); isExpr {
+				if s, err = e.sql(len(res)); err != nil {
+					return "", nil, err
+				}
+				res = append(res, e.Args...)
+			} else {
+				res = append(res, args[n-1])
+				s = "$" + strconv.Itoa(len(res))
+			}
+			rendered[n] = s
+		}
+		b.WriteString(s)
+		i = end
+	}
+
+	// arguments without parameters are kept, the server reports the mismatch as before
+	for n, a := range args {
+		if _, ok := rendered[n+1]; !ok {
+			if _, isExpr := a.(Expr); !isExpr {
+				res = append(res, a)
+			}
+		}
+	}
+
+	return b.String(), res, nil
+}
@@ -0,0 +1,133 @@
+package sqlq
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+func TestExprArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		sql      string
+		args     []any
+		wantSql  string
+		wantArgs []any
+	}{
+		{
+			name:     "no expressions",
+			sql:      "SELECT $1, $2",
+			args:     []any{1, 2},
+			wantSql:  "SELECT $1, $2",
+			wantArgs: []any{1, 2},
+		},
+		{
+			name:     "renumbered",
+			sql:      "UPDATE t SET secret = $2 WHERE id = $1",
+			args:     []any{7, PgpSymEncrypt("data", "key")},
+			wantSql:  "UPDATE t SET secret = pgp_sym_encrypt($1, $2) WHERE id = $3",
+			wantArgs: []any{"data", "key", 7},
+		},
+		{
+			name:     "repeated parameter",
+			sql:      "SELECT $1, $1, $2",
+			args:     []any{PgpSymDecrypt("secret", "key"), 1},
+			wantSql:  "SELECT pgp_sym_decrypt(secret, $1), pgp_sym_decrypt(secret, $1), $2",
+			wantArgs: []any{"key", 1},
+		},
+		{
+			name:     "quoted text",
+			sql:      "SELECT '$1', $1 -- $1",
+			args:     []any{Expr{Sql: "? || '?' || ??", Args: []any{"a"}}},
+			wantSql:  "SELECT '$1', $1 || '?' || ? -- $1",
+			wantArgs: []any{"a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args, err := exprArgs(tt.sql, tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sql != tt.wantSql {
+				t.Errorf("sql %q, want %q", sql, tt.wantSql)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestExprArgsMismatch(t *testing.T) {
+	if _, _, err := exprArgs("SELECT $1", []any{Expr{Sql: "f(?, ?)", Args: []any{1}}}); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestBindSqlExpr(t *testing.T) {
+	sql, args, err := bindSql(literalBinder, "INSERT INTO t (a, b, c) VALUES (:a, :b, :a)", map[string]any{
+		"a": Crypt("pwd", CryptBlowfish),
+		"b": 1,
+	}, ":")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "INSERT INTO t (a, b, c) VALUES (crypt($1, gen_salt($2)), 1, crypt($1, gen_salt($2)))"; sql != want {
+		t.Errorf("sql %q, want %q", sql, want)
+	}
+	if want := []any{"pwd", "bf"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args %v, want %v", args, want)
+	}
+}
+
+func TestExprKeepsSecretsOutOfSql(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect("INSERT INTO t (id, secret) VALUES ($1, pgp_sym_encrypt($2, $3))", sqlqtest.Result{Tag: "INSERT 0 1"})
+	srv.Expect("UPDATE t SET secret = pgp_sym_encrypt($1, $2) WHERE id = 2", sqlqtest.Result{Tag: "UPDATE 1"})
+
+	if _, err := ExecArgs(pool, context.Background(), "INSERT INTO t (id, secret) VALUES ($1, $2)",
+		1, PgpSymEncrypt("data", "key")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ExecBind(pool, context.Background(), "UPDATE t SET secret = :secret WHERE id = :id",
+		map[string]any{"secret": PgpSymEncrypt("data", "key"), "id": 2}, ":"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, sql := range srv.Queries() {
+		if strings.Contains(sql, "key") || strings.Contains(sql, "data") {
+			t.Errorf("secret in the sql text: %s", sql)
+		}
+	}
+}
+
+func TestCryptoColumnQuestionMarks(t *testing.T) {
+	sql, args, err := exprArgs("SELECT $1, $2", []any{
+		PgpSymDecrypt(`"se?cret"`, "key"),
+		CryptCheck(`doc->>'pwd?'`, "pwd"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `SELECT pgp_sym_decrypt("se?cret", $1), (doc->>'pwd?' = crypt($2, doc->>'pwd?'))`; sql != want {
+		t.Errorf("sql %q, want %q", sql, want)
+	}
+	if want := []any{"key", "pwd"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args %v, want %v", args, want)
+	}
+
+	// jsonb ? operator outside of quoted text
+	sql, _, err = exprArgs("SELECT $1", []any{PgpSymDecrypt(`CASE WHEN doc ? 'k' THEN a END`, "key")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `SELECT pgp_sym_decrypt(CASE WHEN doc ? 'k' THEN a END, $1)`; sql != want {
+		t.Errorf("sql %q, want %q", sql, want)
+	}
+}
@@ -0,0 +1,82 @@
+package sqlq
+
+import "unicode"
+
+// maximum length of the fingerprint
+const maxFingerprintLength = 500
+
+// Fingerprint - normalized statement text: string and numeric literals are replaced with ?, whitespace is collapsed,
+// the result is truncated. Statements that differ only in values have the same fingerprint
+func Fingerprint(sql string) string {
+	in := []rune(sql)
+	out := make([]rune, 0, len(in))
+	space := false
+
+	emit := func(r ...rune) {
+		if space && len(out) > 0 {
+			out = append(out, ' ')
+		}
+		space = false
+		out = append(out, r...)
+	}
+
+	for i := 0; i < len(in) && len(out) < maxFingerprintLength; i++ {
+		r := in[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			space = true
+
+		case r == '\'':
+			// E'...' escape string: the prefix is a part of the literal, backslash escapes the next character
+			escape := !space && i > 0 && (in[i-1] == 'E' || in[i-1] == 'e') && (i == 1 || !isIdentRune(in[i-2]))
+			if escape {
+				out = out[:len(out)-1]
+			}
+			// quotes inside the literal are doubled
+			for i++; i < len(in); i++ {
+				if escape && in[i] == '\\' {
+					i++
+					continue
+				}
+				if in[i] == '\'' {
+					if i+1 < len(in) && in[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			emit('?')
+
+		case r == '"':
+			// quoted identifier is kept as is
+			start := i
+			for i++; i < len(in) && in[i] != '"'; i++ {
+			}
+			end := i + 1
+			if end > len(in) {
+				end = len(in)
+			}
+			emit(in[start:end]...)
+
+		case unicode.IsDigit(r) && (i == 0 || !isIdentRune(in[i-1])):
+			for i+1 < len(in) && (unicode.IsDigit(in[i+1]) || in[i+1] == '.') {
+				i++
+			}
+			emit('?')
+
+		default:
+			emit(r)
+		}
+	}
+
+	if len(out) > maxFingerprintLength {
+		out = out[:maxFingerprintLength]
+	}
+	return string(out)
+}
+
+func isIdentRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '$'
+}
@@ -0,0 +1,174 @@
+package sqlq
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/n-r-w/nerr"
+)
+
+// SelectScalar - select the first column of a single row, converted to T. Returns false if there are no rows
+func SelectScalar[T any](pool *pgxpool.Pool, ctx context.Context, sql string) (T, bool, error) {
+	return selectScalarHelper[T](NewQuery(pool, ctx), sql)
+}
+
+// SelectTxScalar - select the first column of a single row inside the transaction, converted to T. Returns false if there are no rows
+func SelectTxScalar[T any](tx *Tx, sql string) (T, bool, error) {
+	return selectScalarHelper[T](NewQueryTx(tx, tx.ctx), sql)
+}
+
+func selectScalarHelper[T any](q *Query, sql string) (T, bool, error) {
+	defer q.Release()
+
+	var res T
+
+	if ok, err := q.SelectRow(sql); err != nil || !ok {
+		return res, false, err
+	}
+
+	values, err := q.ValuesShared()
+	if err != nil {
+		return res, false, nerr.New(err)
+	}
+	if len(values) == 0 {
+		return res, false, nerr.New("no columns in the result")
+	}
+
+	if err := q.scanField(&res, 0, values); err != nil {
+		return res, false, nerr.New(err)
+	}
+
+	return res, true, nil
+}
+
+// Column - select the field values of all rows, converted to T
+func Column[T any](pool *pgxpool.Pool, ctx context.Context, sql string, field string) ([]T, error) {
+	return columnHelper[T](NewQuery(pool, ctx), sql, field)
+}
+
+// ColumnTx - select the field values of all rows inside the transaction, converted to T
+func ColumnTx[T any](tx *Tx, sql string, field string) ([]T, error) {
+	return columnHelper[T](NewQueryTx(tx, tx.ctx), sql, field)
+}
+
+func columnHelper[T any](q *Query, sql string, field string) ([]T, error) {
+	defer q.Release()
+
+	res := []T{}
+	err := forEachHelper(q, sql, func(q *Query) error {
+		values, err := q.ValuesShared()
+		if err != nil {
+			return nerr.New(err)
+		}
+
+		var v T
+		if err := q.scanNamedField(&v, field, values); err != nil {
+			return err
+		}
+		res = append(res, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// DuplicatePolicy - handling of duplicate keys in SelectMap
+type DuplicatePolicy int
+
+const (
+	// DuplicateError - return an error on a duplicate key
+	DuplicateError DuplicatePolicy = iota
+	// DuplicateKeepFirst - keep the value of the first row
+	DuplicateKeepFirst
+	// DuplicateKeepLast - keep the value of the last row
+	DuplicateKeepLast
+)
+
+// SelectMap - select the keyField -> valField map from all rows. Returns an error on duplicate keys
+func SelectMap[K comparable, V any](pool *pgxpool.Pool, ctx context.Context, sql string, keyField string, valField string) (map[K]V, error) {
+	return selectMapHelper[K, V](NewQuery(pool, ctx), sql, keyField, valField, DuplicateError)
+}
+
+// SelectMapPolicy - select the keyField -> valField map from all rows, duplicate keys are handled according to the policy
+func SelectMapPolicy[K comparable, V any](pool *pgxpool.Pool, ctx context.Context, sql string, keyField string, valField string, policy DuplicatePolicy) (map[K]V, error) {
+	return selectMapHelper[K, V](NewQuery(pool, ctx), sql, keyField, valField, policy)
+}
+
+// SelectTxMap - select the keyField -> valField map from all rows inside the transaction, duplicate keys are handled according to the policy
+func SelectTxMap[K comparable, V any](tx *Tx, sql string, keyField string, valField string, policy DuplicatePolicy) (map[K]V, error) {
+	return selectMapHelper[K, V](NewQueryTx(tx, tx.ctx), sql, keyField, valField, policy)
+}
+
+func selectMapHelper[K comparable, V any](q *Query, sql string, keyField string, valField string, policy DuplicatePolicy) (map[K]V, error) {
+	defer q.Release()
+
+	res := map[K]V{}
+	err := forEachHelper(q, sql, func(q *Query) error {
+		values, err := q.ValuesShared()
+		if err != nil {
+			return nerr.New(err)
+		}
+
+		var (
+			k K
+			v V
+		)
+		if err := q.scanNamedField(&k, keyField, values); err != nil {
+			return err
+		}
+		if err := q.scanNamedField(&v, valField, values); err != nil {
+			return err
+		}
+
+		if _, ok := res[k]; ok {
+			switch policy {
+			case DuplicateKeepFirst:
+				return nil
+			case DuplicateKeepLast:
+			default:
+				return nerr.New(fmt.Errorf("duplicate key: %v", k))
+			}
+		}
+
+		res[k] = v
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// SelectStructs - select all rows into a slice of structs, see Query.ScanStruct for the field mapping
+func SelectStructs[T any](pool *pgxpool.Pool, ctx context.Context, sql string) ([]T, error) {
+	return selectStructsHelper[T](NewQuery(pool, ctx), sql)
+}
+
+// SelectTxStructs - select all rows inside the transaction into a slice of structs, see Query.ScanStruct for the field mapping
+func SelectTxStructs[T any](tx *Tx, sql string) ([]T, error) {
+	return selectStructsHelper[T](NewQueryTx(tx, tx.ctx), sql)
+}
+
+func selectStructsHelper[T any](q *Query, sql string) ([]T, error) {
+	defer q.Release()
+
+	res := []T{}
+	err := forEachHelper(q, sql, func(q *Query) error {
+		var v T
+		if err := q.ScanStruct(&v); err != nil {
+			return err
+		}
+		res = append(res, v)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
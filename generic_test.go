@@ -0,0 +1,100 @@
+package sqlq
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+func TestSelectScalar(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect("SELECT count(*) AS n FROM users", sqlqtest.Result{
+		Columns: []sqlqtest.Column{{Name: "n"}},
+		Rows:    [][]any{{int64(42)}},
+	})
+	srv.Expect("SELECT name FROM users WHERE false", sqlqtest.Result{Columns: []sqlqtest.Column{{Name: "name"}}})
+	srv.Expect("SELECT name FROM users LIMIT 1", sqlqtest.Result{
+		Columns: []sqlqtest.Column{{Name: "name"}},
+		Rows:    [][]any{{"admin"}},
+	})
+
+	n, ok, err := SelectScalar[int](pool, context.Background(), "SELECT count(*) AS n FROM users")
+	if err != nil || !ok || n != 42 {
+		t.Errorf("got %v, %v, %v", n, ok, err)
+	}
+
+	name, ok, err := SelectScalar[string](pool, context.Background(), "SELECT name FROM users WHERE false")
+	if err != nil || ok || name != "" {
+		t.Errorf("no rows: got %q, %v, %v", name, ok, err)
+	}
+
+	err = RunInReadTx(pool, context.Background(), func(tx *Tx) error {
+		name, ok, err := SelectTxScalar[*string](tx, "SELECT name FROM users LIMIT 1")
+		if err != nil || !ok || name == nil || *name != "admin" {
+			t.Errorf("tx: got %v, %v, %v", name, ok, err)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// not convertible
+	if _, _, err := SelectScalar[int](pool, context.Background(), "SELECT name FROM users LIMIT 1"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func expectUserNames(srv *sqlqtest.FakePostgres) {
+	srv.Expect("SELECT id, name FROM users", sqlqtest.Result{
+		Columns: []sqlqtest.Column{{Name: "id"}, {Name: "name"}},
+		Rows:    [][]any{{int64(1), "a"}, {int64(2), "b"}, {int64(1), "c"}},
+	})
+}
+
+func TestColumn(t *testing.T) {
+	srv, pool := newTestPool(t)
+	expectUserNames(srv)
+
+	names, err := Column[string](pool, context.Background(), "SELECT id, name FROM users", "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+
+	if _, err := Column[string](pool, context.Background(), "SELECT id, name FROM users", "email"); err == nil {
+		t.Error("unknown field: expected error")
+	}
+}
+
+func TestSelectMapPolicy(t *testing.T) {
+	srv, pool := newTestPool(t)
+	expectUserNames(srv)
+
+	tests := []struct {
+		name    string
+		policy  DuplicatePolicy
+		want    map[int64]string
+		wantErr bool
+	}{
+		{"error", DuplicateError, nil, true},
+		{"keep first", DuplicateKeepFirst, map[int64]string{1: "a", 2: "b"}, false},
+		{"keep last", DuplicateKeepLast, map[int64]string{1: "c", 2: "b"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SelectMapPolicy[int64, string](pool, context.Background(), "SELECT id, name FROM users", "id", "name", tt.policy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package sqlq
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/n-r-w/nerr"
+)
+
+// default number of rows of an INSERT statement of InsertBuilder
+const defaultInsertBatchSize = 1000
+
+// insertDefault - the column is missing in the map row, DEFAULT is inserted
+type insertDefault struct{}
+
+// InsertBuilder - multi-row INSERT INTO table (columns) VALUES (...), (...) statements. The values are substituted
+// as literals (see Literal), the rows are split into statements of BatchSize rows
+type InsertBuilder struct {
+	table     string
+	columns   []string
+	rows      [][]any
+	batchSize int
+	suffix    string
+	mapper    FieldMapper
+	err       error
+}
+
+// Insert - create an INSERT builder for the table. The table can be schema-qualified (schema.table)
+func Insert(table string) *InsertBuilder {
+	return &InsertBuilder{
+		table:     table,
+		batchSize: defaultInsertBatchSize,
+	}
+}
+
+// Columns - columns of the rows. Required for Values, for maps and structs the columns are taken from the first row
+func (b *InsertBuilder) Columns(columns ...string) *InsertBuilder {
+	b.columns = append(b.columns, columns...)
+	return b
+}
+
+// BatchSize - maximum number of rows of one statement, 1000 by default
+func (b *InsertBuilder) BatchSize(n int) *InsertBuilder {
+	if n > 0 {
+		b.batchSize = n
+	}
+	return b
+}
+
+// Suffix - sql added to each statement: ON CONFLICT DO NOTHING etc.
+func (b *InsertBuilder) Suffix(sql string) *InsertBuilder {
+	b.suffix = sql
+	return b
+}
+
+// SetFieldMapper - mapping of the struct fields to columns, the package default if not set
+func (b *InsertBuilder) SetFieldMapper(mapper FieldMapper) *InsertBuilder {
+	b.mapper = mapper
+	return b
+}
+
+// Values - add the row with the values in the order of Columns
+func (b *InsertBuilder) Values(values ...any) *InsertBuilder {
+	if len(values) != len(b.columns) {
+		b.setErr(fmt.Errorf("row %d: %d values for %d columns", len(b.rows), len(values), len(b.columns)))
+		return b
+	}
+
+	b.rows = append(b.rows, values)
+	return b
+}
+
+// Map - add the row with the values by column names. Columns missing in the map get DEFAULT
+func (b *InsertBuilder) Map(row map[string]any) *InsertBuilder {
+	if len(b.columns) == 0 {
+		for c := range row {
+			b.columns = append(b.columns, c)
+		}
+		sort.Strings(b.columns)
+	}
+
+	values := make([]any, len(b.columns))
+	found := 0
+	for i, c := range b.columns {
+		if v, ok := row[c]; ok {
+			values[i] = v
+			found++
+		} else {
+			values[i] = insertDefault{}
+		}
+	}
+	if found != len(row) {
+		b.setErr(fmt.Errorf("row %d: columns are not in the insert columns", len(b.rows)))
+		return b
+	}
+
+	b.rows = append(b.rows, values)
+	return b
+}
+
+// Struct - add the row with the values of the struct fields, mapped to columns with the field mapper
+func (b *InsertBuilder) Struct(src any) *InsertBuilder {
+	values, err := structValues(src, b.fieldMapper())
+	if err != nil {
+		b.setErr(err)
+		return b
+	}
+	return b.Map(values)
+}
+
+// Rows - add the rows of a slice of structs, pointers to structs or map[string]any
+func (b *InsertBuilder) Rows(rows any) *InsertBuilder {
+	v := reflect.ValueOf(rows)
+	if v.Kind() != reflect.Slice {
+		b.setErr(fmt.Errorf("rows must be a slice: %T", rows))
+		return b
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		if m, ok := v.Index(i).Interface().(map[string]any); ok {
+			b.Map(m)
+		} else {
+			b.Struct(v.Index(i).Interface())
+		}
+	}
+	return b
+}
+
+// Len - number of rows
+func (b *InsertBuilder) Len() int {
+	return len(b.rows)
+}
+
+// Sql - the statements, one for each batch of rows
+func (b *InsertBuilder) Sql() ([]string, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if b.table == "" {
+		return nil, nerr.New("empty table name")
+	}
+	if len(b.columns) == 0 {
+		return nil, nerr.New("no columns to insert")
+	}
+
+	prefix := fmt.Sprintf("INSERT INTO %s (%s) VALUES ", quoteName(b.table), quoteColumns(b.columns))
+
+	var res []string
+	for start := 0; start < len(b.rows); start += b.batchSize {
+		end := start + b.batchSize
+		if end > len(b.rows) {
+			end = len(b.rows)
+		}
+
+		var sql strings.Builder
+		sql.WriteString(prefix)
+		for i, row := range b.rows[start:end] {
+			if i > 0 {
+				sql.WriteString(", ")
+			}
+			sql.WriteByte('(')
+			for j, v := range row {
+				if j > 0 {
+					sql.WriteString(", ")
+				}
+
+				if _, ok := v.(insertDefault); ok {
+					sql.WriteString("DEFAULT")
+					continue
+				}
+
+				lit, err := Literal(v)
+				if err != nil {
+					return nil, nerr.New(fmt.Errorf("row %d, column %s: %w", start+i, b.columns[j], err))
+				}
+				sql.WriteString(lit)
+			}
+			sql.WriteByte(')')
+		}
+		if b.suffix != "" {
+			sql.WriteString(" ")
+			sql.WriteString(b.suffix)
+		}
+		res = append(res, sql.String())
+	}
+
+	return res, nil
+}
+
+func (b *InsertBuilder) fieldMapper() FieldMapper {
+	if b.mapper != nil {
+		return b.mapper
+	}
+	return defaultFieldMapper
+}
+
+// setErr - keep the first error, it is returned by Sql
+func (b *InsertBuilder) setErr(err error) {
+	if b.err == nil {
+		b.err = nerr.New(err)
+	}
+}
+
+// ExecInsert - execute the statements of the builder. Returns the number of inserted rows.
+// Outside of a transaction each statement is committed separately
+func (q *Query) ExecInsert(b *InsertBuilder) (int64, error) {
+	sqls, err := b.Sql()
+	if err != nil {
+		return 0, err
+	}
+
+	var affected int64
+	for _, sql := range sqls {
+		if err := q.Exec(sql); err != nil {
+			return affected, err
+		}
+		affected += q.RowsAffected()
+	}
+	return affected, nil
+}
+
+func ExecInsert(pool *pgxpool.Pool, ctx context.Context, b *InsertBuilder) (int64, error) {
+	return NewQuery(pool, ctx).ExecInsert(b)
+}
+
+func ExecTxInsert(tx *Tx, b *InsertBuilder) (int64, error) {
+	return NewQueryTx(tx, tx.ctx).ExecInsert(b)
+}
@@ -0,0 +1,99 @@
+package sqlq
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+func TestInsertBuilderSql(t *testing.T) {
+	type user struct {
+		ID   int    `db:"id"`
+		Name string `db:"name"`
+	}
+
+	tests := []struct {
+		name string
+		b    *InsertBuilder
+		want []string
+	}{
+		{
+			name: "values",
+			b:    Insert("app.users").Columns("id", "name").Values(1, "a'b").Values(2, nil),
+			want: []string{`INSERT INTO "app"."users" ("id", "name") VALUES (1, 'a''b'), (2, NULL)`},
+		},
+		{
+			name: "batches",
+			b:    Insert("users").Columns("id").Values(1).Values(2).Values(3).BatchSize(2).Suffix("ON CONFLICT DO NOTHING"),
+			want: []string{
+				`INSERT INTO "users" ("id") VALUES (1), (2) ON CONFLICT DO NOTHING`,
+				`INSERT INTO "users" ("id") VALUES (3) ON CONFLICT DO NOTHING`,
+			},
+		},
+		{
+			name: "maps with defaults",
+			b:    Insert("users").Map(map[string]any{"name": "a", "id": 1}).Map(map[string]any{"id": 2}),
+			want: []string{`INSERT INTO "users" ("id", "name") VALUES (1, 'a'), (2, DEFAULT)`},
+		},
+		{
+			name: "structs",
+			b:    Insert("users").Rows([]*user{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}}),
+			want: []string{`INSERT INTO "users" ("id", "name") VALUES (1, 'a'), (2, 'b')`},
+		},
+		{
+			name: "no rows",
+			b:    Insert("users").Columns("id"),
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.b.Sql()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInsertBuilderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		b    *InsertBuilder
+	}{
+		{"value count", Insert("users").Columns("id", "name").Values(1)},
+		{"unknown map column", Insert("users").Columns("id").Map(map[string]any{"id": 1, "name": "a"})},
+		{"no columns", Insert("users")},
+		{"no table", Insert("").Columns("id").Values(1)},
+		{"not a slice", Insert("users").Rows(1)},
+		{"unsupported value", Insert("users").Columns("id").Values(make(chan int))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.b.Sql(); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestExecInsert(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect(`INSERT INTO "users" ("id") VALUES (1), (2)`, sqlqtest.Result{Tag: "INSERT 0 2"})
+	srv.Expect(`INSERT INTO "users" ("id") VALUES (3)`, sqlqtest.Result{Tag: "INSERT 0 1"})
+
+	n, err := ExecInsert(pool, context.Background(), Insert("users").Columns("id").Values(1).Values(2).Values(3).BatchSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("inserted %d rows, want 3", n)
+	}
+}
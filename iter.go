@@ -0,0 +1,29 @@
+//go:build go1.23
+
+package sqlq
+
+import "iter"
+
+// Rows - iterate over the select rows with for range. The query is closed when the loop ends, including early exit
+// and a panic in the loop body. The close error, if any, is returned as the last iteration with a nil Query
+func (q *Query) Rows() iter.Seq2[*Query, error] {
+	return func(yield func(*Query, error) bool) {
+		closed := false
+		defer func() {
+			if !closed {
+				_ = q.Close()
+			}
+		}()
+
+		for q.Next() {
+			if !yield(q, nil) {
+				return
+			}
+		}
+
+		closed = true
+		if err := q.Close(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+//go:build go1.23
+
+package sqlq
+
+import (
+	"context"
+	"testing"
+
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+func TestRowsClosesOnPanic(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect("SELECT a FROM t", sqlqtest.Result{Columns: []sqlqtest.Column{{Name: "a"}}, Rows: [][]any{{1}, {2}}})
+
+	tx := NewTx(pool, context.Background())
+	if err := tx.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic")
+			}
+		}()
+
+		q := NewQueryTx(tx, context.Background())
+		if err := q.Select("SELECT a FROM t"); err != nil {
+			t.Fatal(err)
+		}
+		for range q.Rows() {
+			panic("failure")
+		}
+	}()
+
+	// the transaction is released by the iterator
+	q := NewQueryTx(tx, context.Background())
+	if err := q.Select("SELECT a FROM t"); err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, err := range q.Rows() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		n++
+	}
+	if n != 2 {
+		t.Errorf("got %d rows, want 2", n)
+	}
+}
@@ -0,0 +1,152 @@
+package sqlq
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/n-r-w/nerr"
+)
+
+// MarshalJSON - convert the remaining rows of the select into a json array of objects (field name -> value).
+// Numerics are written as numbers, timestamps as RFC3339, NULL, NaN and infinity as null. The query is closed
+func (q *Query) MarshalJSON() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := q.WriteJSON(buf, ExportOptions{}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteJSON - write the remaining rows of the select to w as a json array of objects (field name -> value). The query is closed
+func (q *Query) WriteJSON(w io.Writer, opts ExportOptions) error {
+	buf := bufio.NewWriter(w)
+	buf.WriteByte('[')
+
+	fields := q.Fields()
+	names := make([][]byte, len(fields))
+	for i, f := range fields {
+		name, err := json.Marshal(string(f.Name))
+		if err != nil {
+			_ = q.Close()
+			return nerr.New(err)
+		}
+		names[i] = name
+	}
+
+	first := true
+	for q.Next() {
+		values, err := q.ValuesShared()
+		if err != nil {
+			_ = q.Close()
+			return nerr.New(err)
+		}
+
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+
+		buf.WriteByte('{')
+		for i, v := range values {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.Write(names[i])
+			buf.WriteByte(':')
+
+			j, err := jsonValue(v, fields[i], opts)
+			if err != nil {
+				_ = q.Close()
+				return nerr.New(fmt.Errorf("field %s: %w", fields[i].Name, err))
+			}
+			buf.Write(j)
+		}
+		buf.WriteByte('}')
+	}
+
+	if err := q.Close(); err != nil {
+		return nerr.New(err)
+	}
+
+	buf.WriteByte(']')
+	return nerr.New(buf.Flush())
+}
+
+// SelectJSON - execute the select and convert the result into a json array of objects
+func SelectJSON(pool *pgxpool.Pool, ctx context.Context, sql string) ([]byte, error) {
+	q, err := Select(pool, ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	return q.MarshalJSON()
+}
+
+// SelectTxJSON - execute the select inside the transaction and convert the result into a json array of objects
+func SelectTxJSON(tx *Tx, sql string) ([]byte, error) {
+	q, err := SelectTx(tx, sql)
+	if err != nil {
+		return nil, err
+	}
+	return q.MarshalJSON()
+}
+
+// jsonValue - json representation of the field value
+func jsonValue(v any, fd pgproto3.FieldDescription, opts ExportOptions) ([]byte, error) {
+	// NaN and infinity are not json numbers
+	switch d := v.(type) {
+	case float32:
+		if math.IsNaN(float64(d)) || math.IsInf(float64(d), 0) {
+			return []byte("null"), nil
+		}
+	case float64:
+		if math.IsNaN(d) || math.IsInf(d, 0) {
+			return []byte("null"), nil
+		}
+	}
+
+	if n, ok := numberText(v); ok {
+		if opts.decimalSeparator() != "." {
+			return json.Marshal(opts.formatNumber(n))
+		}
+		return []byte(n), nil
+	}
+
+	switch d := v.(type) {
+	case nil:
+		return []byte("null"), nil
+	case time.Time:
+		return json.Marshal(opts.formatTime(d, fd))
+	case pgtype.Numeric:
+		// NaN, infinity
+		if d.Status != pgtype.Present {
+			return []byte("null"), nil
+		}
+		text, err := d.EncodeText(nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(string(text))
+	case [16]byte:
+		return json.Marshal(formatUUID(d))
+	case pgtype.TextEncoder:
+		text, err := d.EncodeText(nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		if text == nil {
+			return []byte("null"), nil
+		}
+		return json.Marshal(string(text))
+	default:
+		return json.Marshal(d)
+	}
+}
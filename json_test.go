@@ -0,0 +1,55 @@
+package sqlq
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+func TestSelectJSON(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect("SELECT * FROM orders", sqlqtest.Result{
+		Columns: []sqlqtest.Column{
+			{Name: "id", OID: pgtype.Int8OID},
+			{Name: "name", OID: pgtype.TextOID},
+			{Name: "note", OID: pgtype.TextOID},
+			{Name: "price", OID: pgtype.Float8OID},
+			{Name: "paid", OID: pgtype.BoolOID},
+			{Name: "uid", OID: pgtype.UUIDOID},
+			{Name: "created", OID: pgtype.TimestamptzOID},
+		},
+		Rows: [][]any{
+			{int64(1), `say "hi"`, nil, 12.5, true, "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)},
+			{int64(2), "", "n", math.NaN(), false, nil, nil},
+		},
+	})
+
+	got, err := SelectJSON(pool, context.Background(), "SELECT * FROM orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[` +
+		`{"id":1,"name":"say \"hi\"","note":null,"price":12.5,"paid":true,"uid":"a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11","created":"2024-01-02T03:04:05Z"},` +
+		`{"id":2,"name":"","note":"n","price":null,"paid":false,"uid":null,"created":null}` +
+		`]`
+	if string(got) != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestSelectJSONEmpty(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect("SELECT id FROM orders WHERE false", sqlqtest.Result{Columns: []sqlqtest.Column{{Name: "id", OID: pgtype.Int8OID}}})
+
+	got, err := SelectJSON(pool, context.Background(), "SELECT id FROM orders WHERE false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "[]" {
+		t.Errorf("got %s, want []", got)
+	}
+}
@@ -0,0 +1,129 @@
+package sqlq
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParamCount - number of $n parameters of the statement: the highest n outside of string literals, quoted identifiers,
+// dollar quoted strings and comments
+func ParamCount(sql string) (int, error) {
+	n := 0
+	for i := 0; i < len(sql); {
+		end, err := skipSqlToken(sql, i)
+		if err != nil {
+			return 0, err
+		}
+		if end > i {
+			i = end
+			continue
+		}
+
+		if sql[i] != '$' || (i > 0 && isIdentRune(rune(sql[i-1]))) {
+			i++
+			continue
+		}
+
+		end = i + 1
+		for end < len(sql) && sql[end] >= '0' && sql[end] <= '9' {
+			end++
+		}
+		if p, err := strconv.Atoi(sql[i+1 : end]); err == nil && p > n {
+			n = p
+		}
+		i = end
+	}
+	return n, nil
+}
+
+// skipSqlToken - end of the string literal, quoted identifier, dollar quoted string or comment that starts at i,
+// i if there is no such token at i. Placeholders inside these tokens are not placeholders
+func skipSqlToken(sql string, i int) (int, error) {
+	switch c := sql[i]; {
+	case c == '\'':
+		// E'...' escape string: backslash escapes the next character
+		escape := i > 0 && (sql[i-1] == 'E' || sql[i-1] == 'e') && (i == 1 || !isIdentRune(rune(sql[i-2])))
+		for j := i + 1; j < len(sql); j++ {
+			if escape && sql[j] == '\\' {
+				j++
+				continue
+			}
+			if sql[j] == '\'' {
+				// quotes inside the literal are doubled
+				if j+1 < len(sql) && sql[j+1] == '\'' {
+					j++
+					continue
+				}
+				return j + 1, nil
+			}
+		}
+		return 0, fmt.Errorf("unterminated string literal at offset %d", i)
+
+	case c == '"':
+		end := strings.IndexByte(sql[i+1:], '"')
+		if end < 0 {
+			return 0, fmt.Errorf("unterminated quoted identifier at offset %d", i)
+		}
+		return i + end + 2, nil
+
+	case c == '-' && strings.HasPrefix(sql[i:], "--"):
+		end := strings.IndexByte(sql[i:], '\n')
+		if end < 0 {
+			return len(sql), nil
+		}
+		return i + end + 1, nil
+
+	case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+		// block comments may be nested
+		depth := 0
+		for j := i; j+1 < len(sql); j++ {
+			switch sql[j : j+2] {
+			case "/*":
+				depth++
+				j++
+			case "*/":
+				depth--
+				j++
+				if depth == 0 {
+					return j + 1, nil
+				}
+			}
+		}
+		return 0, fmt.Errorf("unterminated comment at offset %d", i)
+
+	case c == '$':
+		tag, ok := dollarQuoteTag(sql, i)
+		if !ok {
+			return i, nil
+		}
+		end := strings.Index(sql[i+len(tag):], tag)
+		if end < 0 {
+			return 0, fmt.Errorf("unterminated dollar quoted string at offset %d", i)
+		}
+		return i + 2*len(tag) + end, nil
+	}
+
+	return i, nil
+}
+
+// dollarQuoteTag - opening tag of the dollar quoted string at i: $$ or $tag$. $1 is a parameter, not a tag
+func dollarQuoteTag(sql string, i int) (string, bool) {
+	// part of the identifier: a$b
+	if i > 0 && isIdentRune(rune(sql[i-1])) {
+		return "", false
+	}
+
+	j := i + 1
+	for j < len(sql) && sql[j] != '$' {
+		c := rune(sql[j])
+		if !isIdentRune(c) || c == '$' || (j == i+1 && c >= '0' && c <= '9') {
+			return "", false
+		}
+		j++
+	}
+	if j >= len(sql) {
+		return "", false
+	}
+	return sql[i : j+1], true
+}
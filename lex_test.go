@@ -0,0 +1,32 @@
+package sqlq
+
+import "testing"
+
+func TestParamCount(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want int
+	}{
+		{"SELECT 1", 0},
+		{"SELECT $1, $3, $2", 3},
+		{"SELECT '$4', $1", 1},
+		{`SELECT E'\'$4', "a$5", $1`, 1},
+		{"SELECT $$ $4 $$, $tag$ $5 $tag$, $2", 2},
+		{"SELECT $2 -- $4\n/* $5 /* $6 */ */", 2},
+		{"SELECT a$1 FROM t", 0},
+	}
+
+	for _, tt := range tests {
+		got, err := ParamCount(tt.sql)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.sql, err)
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %d, want %d", tt.sql, got, tt.want)
+		}
+	}
+
+	if _, err := ParamCount("SELECT '$1"); err == nil {
+		t.Error("unterminated literal: expected error")
+	}
+}
@@ -0,0 +1,147 @@
+package sqlq
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Literal - sql literal of the value, used by the Bind methods for slice values:
+//   - nil, nil pointers, slices and maps: NULL
+//   - string: quoted string, escape string (E'...') if it contains backslashes
+//   - bool, integer and float numbers (NaN and infinities as float8)
+//   - time.Time: timestamptz, time.Duration: interval
+//   - []byte: bytea in the hex format
+//   - json.RawMessage, json.Marshaler, maps and structs: jsonb
+//   - slices and arrays: ARRAY[...], nested slices are multidimensional arrays, empty - '{}'
+//   - driver.Valuer: literal of its value
+//
+// Pointers are dereferenced, named types are converted by their underlying kind
+func Literal(v any) (string, error) {
+	switch d := v.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		return quoteLiteral(d), nil
+	case bool:
+		if d {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", d), nil
+	case float32:
+		return floatLiteral(float64(d), 32), nil
+	case float64:
+		return floatLiteral(d, 64), nil
+	case time.Time:
+		return quoteLiteral(d.Format("2006-01-02 15:04:05.999999Z07:00")) + "::timestamptz", nil
+	case time.Duration:
+		return fmt.Sprintf("'%d microseconds'::interval", d.Microseconds()), nil
+	case json.RawMessage:
+		if d == nil {
+			return "NULL", nil
+		}
+		return quoteLiteral(string(d)) + "::jsonb", nil
+	case []byte:
+		if d == nil {
+			return "NULL", nil
+		}
+		return `'\x` + hex.EncodeToString(d) + `'::bytea`, nil
+	case driver.Valuer:
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return "NULL", nil
+		}
+		value, err := d.Value()
+		if err != nil {
+			return "", err
+		}
+		return Literal(value)
+	case json.Marshaler:
+		return jsonLiteral(d)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return "NULL", nil
+		}
+		return Literal(rv.Elem().Interface())
+	case reflect.String:
+		return Literal(rv.String())
+	case reflect.Bool:
+		return Literal(rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Literal(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Literal(rv.Uint())
+	case reflect.Float32:
+		return floatLiteral(rv.Float(), 32), nil
+	case reflect.Float64:
+		return floatLiteral(rv.Float(), 64), nil
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return Literal(rv.Bytes())
+		}
+		return arrayLiteral(rv)
+	case reflect.Array:
+		return arrayLiteral(rv)
+	case reflect.Map:
+		if rv.IsNil() {
+			return "NULL", nil
+		}
+		return jsonLiteral(v)
+	case reflect.Struct:
+		return jsonLiteral(v)
+	}
+
+	return "", fmt.Errorf("can't convert to sql literal: %T", v)
+}
+
+func floatLiteral(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return "'NaN'::float8"
+	case math.IsInf(f, 1):
+		return "'Infinity'::float8"
+	case math.IsInf(f, -1):
+		return "'-Infinity'::float8"
+	}
+	return strconv.FormatFloat(f, 'g', -1, bitSize)
+}
+
+// arrayLiteral - ARRAY[...] constructor, nested slices are multidimensional arrays. Empty array - '{}',
+// its type is taken from the context
+func arrayLiteral(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Slice && v.IsNil() {
+		return "NULL", nil
+	}
+	if v.Len() == 0 {
+		return "'{}'", nil
+	}
+
+	items := make([]string, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		s, err := Literal(v.Index(i).Interface())
+		if err != nil {
+			return "", err
+		}
+		items[i] = s
+	}
+	return "ARRAY[" + strings.Join(items, ", ") + "]", nil
+}
+
+func jsonLiteral(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return quoteLiteral(string(data)) + "::jsonb", nil
+}
@@ -2,44 +2,64 @@ package sqlq
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"strings"
+	"sync"
 
 	"github.com/jackc/pgx/v4"
 	"github.com/n-r-w/nerr"
 )
 
-// SaveLargeObject - write Large Object to the database. If oid == 0 then creates a new object.
-// Returns the id of the created or updated object
+// LargeObjectSaveMode - how SaveLargeObjectMode writes to an existing object
+type LargeObjectSaveMode int
+
+const (
+	// LargeObjectOverwrite - replace the content of the object
+	LargeObjectOverwrite LargeObjectSaveMode = iota
+	// LargeObjectAppend - add the data to the end of the object
+	LargeObjectAppend
+)
+
+// SaveLargeObject - write Large Object to the database. If oid == 0 then creates a new object,
+// otherwise the content of the object is replaced. Returns the id of the created or updated object
 func SaveLargeObject(tx *Tx, oid uint32, data []byte) (uint32, error) {
-	lobj := tx.tx.LargeObjects()
-	var obj *pgx.LargeObject
-	var err error
-	if oid > 0 {
-		obj, err = lobj.Open(tx.ctx, oid, pgx.LargeObjectModeWrite)
-	} else {
-		oid, err = lobj.Create(tx.ctx, oid)
-		if err != nil {
-			return 0, nerr.New(err)
-		}
-		obj, err = lobj.Open(tx.ctx, oid, pgx.LargeObjectModeWrite)
-	}
+	return SaveLargeObjectMode(tx, oid, data, LargeObjectOverwrite)
+}
+
+// SaveLargeObjectMode - write Large Object to the database. If oid == 0 then creates a new object,
+// otherwise the data is written according to the mode. Returns the id of the created or updated object
+func SaveLargeObjectMode(tx *Tx, oid uint32, data []byte, mode LargeObjectSaveMode) (uint32, error) {
+	existing := oid > 0
+	oid, obj, err := OpenLargeObjectWriter(tx, oid)
 	if err != nil {
-		return 0, nerr.New(err)
+		return 0, err
 	}
 
-	_, err = obj.Write(data)
-	if err != nil {
-		return 0, nerr.New(err)
+	if existing {
+		if err := obj.prepareSave(mode); err != nil {
+			_ = obj.Close()
+			return 0, err
+		}
+	}
+
+	if _, err := obj.Write(data); err != nil {
+		_ = obj.Close()
+		return 0, err
 	}
 
-	return oid, nil
+	return oid, obj.Close()
 }
 
 // LoadLargeObject - read Large Object from the database.
 func LoadLargeObject(tx *Tx, oid uint32) ([]byte, error) {
-	var err error
+	t, release, err := tx.use()
+	if err != nil {
+		return []byte{}, err
+	}
+	defer release()
 
-	lobj := tx.tx.LargeObjects()
+	lobj := t.LargeObjects()
 	obj, err := lobj.Open(tx.ctx, oid, pgx.LargeObjectModeRead)
 	if err != nil {
 		return []byte{}, nerr.New(err)
@@ -54,8 +74,444 @@ func LoadLargeObject(tx *Tx, oid uint32) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// statements of pgx.LargeObjects recorded by the audit
+const (
+	auditLargeObjectCreate   = "select lo_create($1)"
+	auditLargeObjectUnlink   = "select lo_unlink($1)"
+	auditLargeObjectWrite    = "select lowrite($1, $2)"
+	auditLargeObjectTruncate = "select lo_truncate64($1, $2)"
+)
+
 // RemoveLargeObject - remove Large Object from the database.
 func RemoveLargeObject(tx *Tx, oid uint32) error {
-	lobj := tx.tx.LargeObjects()
+	if err := unlinkLargeObject(tx, oid); err != nil {
+		return err
+	}
+	return auditTx(tx, auditLargeObjectUnlink, 1)
+}
+
+func unlinkLargeObject(tx *Tx, oid uint32) error {
+	t, release, err := tx.use()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	lobj := t.LargeObjects()
 	return nerr.New(lobj.Unlink(tx.ctx, oid))
 }
+
+const (
+	// maximum size of one lowrite call of LargeObject.Write
+	largeObjectWriteChunk = 1 << 20
+	// default chunk size of SaveLargeObjectFrom and LoadLargeObjectTo
+	defaultLargeObjectChunk = 256 << 10
+)
+
+// LargeObject - Large Object opened in the transaction, see OpenLargeObjectReader and OpenLargeObjectWriter.
+// The transaction is busy until Close, the object is closed at the end of the transaction anyway.
+// The changes are recorded by the audit on Close
+type LargeObject struct {
+	obj     *pgx.LargeObject
+	tx      *Tx
+	release func()
+	once    sync.Once
+
+	written   bool
+	truncated bool
+}
+
+// OpenLargeObjectReader - open Large Object for reading in the transaction. The object is read in portions,
+// so it can be streamed without loading it into memory
+func OpenLargeObjectReader(tx *Tx, oid uint32) (io.ReadSeekCloser, error) {
+	return openLargeObject(tx, oid, pgx.LargeObjectModeRead)
+}
+
+// OpenLargeObjectWriter - open Large Object for writing in the transaction. If oid == 0 then creates a new object.
+// Returns the id of the created or opened object
+func OpenLargeObjectWriter(tx *Tx, oid uint32) (uint32, *LargeObject, error) {
+	if oid == 0 {
+		t, release, err := tx.use()
+		if err != nil {
+			return 0, nil, err
+		}
+		lobj := t.LargeObjects()
+		oid, err = lobj.Create(tx.ctx, 0)
+		release()
+		if err != nil {
+			return 0, nil, nerr.New(err)
+		}
+		if err := auditTx(tx, auditLargeObjectCreate, 1); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	obj, err := openLargeObject(tx, oid, pgx.LargeObjectModeWrite)
+	if err != nil {
+		return 0, nil, err
+	}
+	return oid, obj, nil
+}
+
+func openLargeObject(tx *Tx, oid uint32, mode pgx.LargeObjectMode) (*LargeObject, error) {
+	t, release, err := tx.use()
+	if err != nil {
+		return nil, err
+	}
+
+	lobj := t.LargeObjects()
+	obj, err := lobj.Open(tx.ctx, oid, mode)
+	if err != nil {
+		release()
+		return nil, nerr.New(err)
+	}
+
+	return &LargeObject{obj: obj, tx: tx, release: release}, nil
+}
+
+// Read - read up to len(p) bytes from the current position
+func (o *LargeObject) Read(p []byte) (int, error) {
+	n, err := o.obj.Read(p)
+	if err != nil && err != io.EOF {
+		return n, nerr.New(err)
+	}
+	return n, err
+}
+
+// Write - write p at the current position
+func (o *LargeObject) Write(p []byte) (int, error) {
+	written := 0
+	for written < len(p) {
+		end := written + largeObjectWriteChunk
+		if end > len(p) {
+			end = len(p)
+		}
+
+		n, err := o.obj.Write(p[written:end])
+		written += n
+		if n > 0 {
+			o.written = true
+		}
+		if err != nil {
+			return written, nerr.New(err)
+		}
+	}
+	return written, nil
+}
+
+// Seek - move the current position, whence is io.SeekStart, io.SeekCurrent or io.SeekEnd
+func (o *LargeObject) Seek(offset int64, whence int) (int64, error) {
+	n, err := o.obj.Seek(offset, whence)
+	return n, nerr.New(err)
+}
+
+// Tell - current position
+func (o *LargeObject) Tell() (int64, error) {
+	n, err := o.obj.Tell()
+	return n, nerr.New(err)
+}
+
+// Size - size of the object. The current position is not changed
+func (o *LargeObject) Size() (int64, error) {
+	pos, err := o.Tell()
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := o.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := o.Seek(pos, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
+// Truncate - truncate or extend with zeros the object to size bytes. Requires the write mode
+func (o *LargeObject) Truncate(size int64) error {
+	if err := o.obj.Truncate(size); err != nil {
+		return nerr.New(err)
+	}
+	o.truncated = true
+	return nil
+}
+
+// prepareSave - position of the existing object for writing in the mode
+func (o *LargeObject) prepareSave(mode LargeObjectSaveMode) error {
+	switch mode {
+	case LargeObjectOverwrite:
+		return o.Truncate(0)
+	case LargeObjectAppend:
+		_, err := o.Seek(0, io.SeekEnd)
+		return err
+	default:
+		return nerr.New(fmt.Errorf("unknown large object save mode: %d", mode))
+	}
+}
+
+// Close - close the object and release the transaction
+func (o *LargeObject) Close() error {
+	var err error
+	o.once.Do(func() {
+		err = nerr.New(o.obj.Close())
+		o.release()
+
+		if o.truncated && err == nil {
+			err = auditTx(o.tx, auditLargeObjectTruncate, 1)
+		}
+		if o.written && err == nil {
+			err = auditTx(o.tx, auditLargeObjectWrite, 1)
+		}
+	})
+	return err
+}
+
+// SaveLargeObjectFrom - write Large Object from the reader in chunks of chunkSize bytes (256KB if chunkSize <= 0).
+// If oid == 0 then creates a new object, otherwise the content of the object is replaced. progress, if not nil,
+// is called after each chunk with the number of bytes written so far. Returns the id of the created or updated object
+func SaveLargeObjectFrom(tx *Tx, oid uint32, r io.Reader, chunkSize int, progress func(written int64)) (uint32, error) {
+	existing := oid > 0
+	oid, obj, err := OpenLargeObjectWriter(tx, oid)
+	if err != nil {
+		return 0, err
+	}
+
+	if existing {
+		if err := obj.prepareSave(LargeObjectOverwrite); err != nil {
+			_ = obj.Close()
+			return 0, err
+		}
+	}
+
+	if _, err := copyChunks(obj, r, chunkSize, progress); err != nil {
+		_ = obj.Close()
+		return 0, err
+	}
+
+	return oid, obj.Close()
+}
+
+// LoadLargeObjectTo - read Large Object to the writer in chunks of chunkSize bytes (256KB if chunkSize <= 0).
+// progress, if not nil, is called after each chunk with the number of bytes read so far.
+// Returns the number of bytes read
+func LoadLargeObjectTo(tx *Tx, oid uint32, w io.Writer, chunkSize int, progress func(read int64)) (int64, error) {
+	obj, err := OpenLargeObjectReader(tx, oid)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := copyChunks(w, obj, chunkSize, progress)
+	if err != nil {
+		_ = obj.Close()
+		return n, err
+	}
+
+	return n, obj.Close()
+}
+
+// copyChunks - io.Copy with a buffer of chunkSize and a progress callback after each chunk
+func copyChunks(dst io.Writer, src io.Reader, chunkSize int, progress func(n int64)) (int64, error) {
+	if chunkSize <= 0 {
+		chunkSize = defaultLargeObjectChunk
+	}
+
+	buf := make([]byte, chunkSize)
+	var total int64
+	for {
+		n, err := io.ReadFull(src, buf)
+		if n > 0 {
+			if _, werr := dst.Write(buf[:n]); werr != nil {
+				return total, nerr.New(werr)
+			}
+			total += int64(n)
+			if progress != nil {
+				progress(total)
+			}
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return total, nil
+		}
+		if err != nil {
+			return total, nerr.New(err)
+		}
+	}
+}
+
+// LargeObjectSize - size of Large Object
+func LargeObjectSize(tx *Tx, oid uint32) (int64, error) {
+	obj, err := openLargeObject(tx, oid, pgx.LargeObjectModeRead)
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := obj.Seek(0, io.SeekEnd)
+	if err != nil {
+		_ = obj.Close()
+		return 0, err
+	}
+	return size, obj.Close()
+}
+
+// TruncateLargeObject - truncate or extend with zeros Large Object to size bytes
+func TruncateLargeObject(tx *Tx, oid uint32, size int64) error {
+	obj, err := openLargeObject(tx, oid, pgx.LargeObjectModeWrite)
+	if err != nil {
+		return err
+	}
+
+	if err := obj.Truncate(size); err != nil {
+		_ = obj.Close()
+		return err
+	}
+	return obj.Close()
+}
+
+// WriteLargeObjectAt - overwrite the part of Large Object starting at offset with the data, the rest of the object
+// is not changed. If offset is beyond the end, the gap is filled with zeros
+func WriteLargeObjectAt(tx *Tx, oid uint32, offset int64, data []byte) error {
+	obj, err := openLargeObject(tx, oid, pgx.LargeObjectModeWrite)
+	if err != nil {
+		return err
+	}
+
+	if _, err := obj.Seek(offset, io.SeekStart); err != nil {
+		_ = obj.Close()
+		return err
+	}
+	if _, err := obj.Write(data); err != nil {
+		_ = obj.Close()
+		return err
+	}
+	return obj.Close()
+}
+
+// CopyLargeObject - create a copy of Large Object. The data is copied in chunks in the transaction,
+// without loading the whole object into memory. Returns the id of the new object
+func CopyLargeObject(tx *Tx, srcOid uint32) (uint32, error) {
+	oid, err := copyLargeObject(tx, srcOid)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := auditTx(tx, auditLargeObjectCreate, 1); err != nil {
+		return 0, err
+	}
+	return oid, auditTx(tx, auditLargeObjectWrite, 1)
+}
+
+func copyLargeObject(tx *Tx, srcOid uint32) (uint32, error) {
+	t, release, err := tx.use()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	lobj := t.LargeObjects()
+	src, err := lobj.Open(tx.ctx, srcOid, pgx.LargeObjectModeRead)
+	if err != nil {
+		return 0, nerr.New(err)
+	}
+	defer func() { _ = src.Close() }()
+
+	oid, err := lobj.Create(tx.ctx, 0)
+	if err != nil {
+		return 0, nerr.New(err)
+	}
+	dst, err := lobj.Open(tx.ctx, oid, pgx.LargeObjectModeWrite)
+	if err != nil {
+		return 0, nerr.New(err)
+	}
+
+	if _, err := copyChunks(dst, src, defaultLargeObjectChunk, nil); err != nil {
+		_ = dst.Close()
+		return 0, err
+	}
+	return oid, nerr.New(dst.Close())
+}
+
+// default number of objects unlinked by one statement of RemoveOrphanedLargeObjects
+const defaultOrphanBatchSize = 1000
+
+// FindOrphanedLargeObjects - ids of Large Objects that are not referenced by the application.
+// refQueries - selects of one column with the ids of the referenced objects: SELECT file_oid FROM documents
+func FindOrphanedLargeObjects(tx *Tx, refQueries ...string) ([]uint32, error) {
+	if len(refQueries) == 0 {
+		return nil, nerr.New("no reference queries")
+	}
+
+	refs := make([]string, len(refQueries))
+	for i, q := range refQueries {
+		refs[i] = fmt.Sprintf("SELECT r.ref::oid FROM (%s) AS r(ref)", q)
+	}
+
+	return ColumnTx[uint32](tx, fmt.Sprintf(
+		"SELECT m.oid FROM pg_largeobject_metadata m WHERE m.oid NOT IN (SELECT ref FROM (%s) AS refs(ref) WHERE ref IS NOT NULL) ORDER BY m.oid",
+		strings.Join(refs, " UNION ")), "oid")
+}
+
+// RemoveOrphanedLargeObjects - unlink Large Objects that are not referenced by the application,
+// batchSize objects per statement (1000 if batchSize <= 0). See FindOrphanedLargeObjects.
+// Returns the number of removed objects
+func RemoveOrphanedLargeObjects(tx *Tx, batchSize int, refQueries ...string) (int, error) {
+	if batchSize <= 0 {
+		batchSize = defaultOrphanBatchSize
+	}
+
+	oids, err := FindOrphanedLargeObjects(tx, refQueries...)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for start := 0; start < len(oids); start += batchSize {
+		end := start + batchSize
+		if end > len(oids) {
+			end = len(oids)
+		}
+
+		// pgx has no oid[] type, the ids are passed as int8[]
+		ids := make([]int64, 0, end-start)
+		for _, oid := range oids[start:end] {
+			ids = append(ids, int64(oid))
+		}
+		if err := NewQueryTx(tx, tx.ctx).ExecArgs("SELECT lo_unlink(id::oid) FROM unnest($1::int8[]) AS id", ids); err != nil {
+			return removed, err
+		}
+		removed = end
+
+		// the select is not audited as a command
+		if err := auditTx(tx, auditLargeObjectUnlink, int64(len(ids))); err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// LoadLargeObjectRange - read length bytes of Large Object starting at offset. The result is shorter
+// if the object ends earlier, empty if offset is beyond the end
+func LoadLargeObjectRange(tx *Tx, oid uint32, offset int64, length int64) ([]byte, error) {
+	if offset < 0 || length < 0 {
+		return nil, nerr.New(fmt.Errorf("invalid large object range: offset %d, length %d", offset, length))
+	}
+
+	obj, err := openLargeObject(tx, oid, pgx.LargeObjectModeRead)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := obj.Seek(offset, io.SeekStart); err != nil {
+		_ = obj.Close()
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	if _, err := copyChunks(buf, io.LimitReader(obj, length), defaultLargeObjectChunk, nil); err != nil {
+		_ = obj.Close()
+		return nil, err
+	}
+
+	return buf.Bytes(), obj.Close()
+}
@@ -0,0 +1,577 @@
+package sqlq
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+	"testing/iotest"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+// newLargeObjectPool - test pool of the fake server with in-memory Large Objects
+func newLargeObjectPool(t *testing.T) (*sqlqtest.FakePostgres, *sqlqtest.LargeObjects, *pgxpool.Pool) {
+	t.Helper()
+
+	srv, pool := newTestPool(t)
+	lo := sqlqtest.NewLargeObjects()
+	srv.Handle(lo.Handle)
+	return srv, lo, pool
+}
+
+// largeObjectData - test content of n bytes
+func largeObjectData(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	return data
+}
+
+// countQueries - number of the executions of the statement
+func countQueries(srv *sqlqtest.FakePostgres, sql string) int {
+	n := 0
+	for _, q := range srv.Queries() {
+		if q == sql {
+			n++
+		}
+	}
+	return n
+}
+
+func TestLargeObjectWriterChunks(t *testing.T) {
+	srv, lo, pool := newLargeObjectPool(t)
+	data := largeObjectData(2*largeObjectWriteChunk + 100)
+
+	var oid uint32
+	err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+		var (
+			obj *LargeObject
+			err error
+		)
+		if oid, obj, err = OpenLargeObjectWriter(tx, 0); err != nil {
+			return err
+		}
+
+		// the transaction is busy until the object is closed
+		if _, err := ExecTx(tx, "SELECT 1"); err == nil {
+			t.Error("expected busy transaction error")
+		}
+
+		n, err := obj.Write(data)
+		if err != nil {
+			_ = obj.Close()
+			return err
+		}
+		if n != len(data) {
+			t.Errorf("written %d, want %d", n, len(data))
+		}
+		return obj.Close()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := countQueries(srv, auditLargeObjectWrite); n != 3 {
+		t.Errorf("got %d lowrite calls, want 3", n)
+	}
+	if got, _ := lo.Get(oid); !bytes.Equal(got, data) {
+		t.Errorf("stored %d bytes, want %d", len(got), len(data))
+	}
+	if n := lo.Open(); n != 0 {
+		t.Errorf("%d descriptors are not closed", n)
+	}
+}
+
+func TestLargeObjectReaderSeek(t *testing.T) {
+	_, lo, pool := newLargeObjectPool(t)
+	data := largeObjectData(1000)
+	lo.Set(100, data)
+
+	err := RunInReadTx(pool, context.Background(), func(tx *Tx) error {
+		r, err := OpenLargeObjectReader(tx, 100)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = r.Close() }()
+
+		if _, err := r.Seek(-10, io.SeekEnd); err != nil {
+			return err
+		}
+		got, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(got, data[990:]) {
+			t.Errorf("got %v, want %v", got, data[990:])
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLargeObjectNotFound(t *testing.T) {
+	_, _, pool := newLargeObjectPool(t)
+
+	err := RunInReadTx(pool, context.Background(), func(tx *Tx) error {
+		_, err := LoadLargeObject(tx, 1)
+		return err
+	})
+	if !IsLargeObjectNotFound(err) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestSaveLoadLargeObjectChunks(t *testing.T) {
+	tests := []struct {
+		name      string
+		size      int
+		chunkSize int
+		progress  []int64
+	}{
+		{"partial last chunk", 250, 100, []int64{100, 200, 250}},
+		{"exact chunks", 200, 100, []int64{100, 200}},
+		{"empty", 0, 100, nil},
+		{"default chunk", 1000, 0, []int64{1000}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, lo, pool := newLargeObjectPool(t)
+			data := largeObjectData(tt.size)
+
+			var (
+				oid           uint32
+				saved, loaded []int64
+				buf           bytes.Buffer
+				loadedBytes   int64
+			)
+			err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+				var err error
+				oid, err = SaveLargeObjectFrom(tx, 0, bytes.NewReader(data), tt.chunkSize, func(n int64) { saved = append(saved, n) })
+				if err != nil {
+					return err
+				}
+				loadedBytes, err = LoadLargeObjectTo(tx, oid, &buf, tt.chunkSize, func(n int64) { loaded = append(loaded, n) })
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(saved, tt.progress) {
+				t.Errorf("save progress %v, want %v", saved, tt.progress)
+			}
+			if !reflect.DeepEqual(loaded, tt.progress) {
+				t.Errorf("load progress %v, want %v", loaded, tt.progress)
+			}
+			if loadedBytes != int64(tt.size) || !bytes.Equal(buf.Bytes(), data) {
+				t.Errorf("loaded %d bytes, want %d", loadedBytes, tt.size)
+			}
+			if got, _ := lo.Get(oid); !bytes.Equal(got, data) {
+				t.Errorf("stored %d bytes, want %d", len(got), tt.size)
+			}
+		})
+	}
+}
+
+func TestSaveLargeObjectFromReplaces(t *testing.T) {
+	_, lo, pool := newLargeObjectPool(t)
+	lo.Set(100, largeObjectData(500))
+
+	err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+		_, err := SaveLargeObjectFrom(tx, 100, bytes.NewReader([]byte("new")), 0, nil)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := lo.Get(100); string(got) != "new" {
+		t.Errorf("got %q, want %q", got, "new")
+	}
+}
+
+func TestSaveLargeObjectFromReaderError(t *testing.T) {
+	_, lo, pool := newLargeObjectPool(t)
+	failure := errors.New("failure")
+
+	err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+		_, err := SaveLargeObjectFrom(tx, 0, io.MultiReader(bytes.NewReader([]byte("abc")), iotest.ErrReader(failure)), 2, nil)
+		return err
+	})
+	if !errors.Is(err, failure) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if n := lo.Open(); n != 0 {
+		t.Errorf("%d descriptors are not closed", n)
+	}
+}
+
+func TestLargeObjectSizeTruncateWriteAt(t *testing.T) {
+	tests := []struct {
+		name string
+		fn   func(tx *Tx) error
+		want []byte
+	}{
+		{"truncate shorter", func(tx *Tx) error { return TruncateLargeObject(tx, 100, 3) }, []byte("abc")},
+		{"truncate longer", func(tx *Tx) error { return TruncateLargeObject(tx, 100, 8) }, []byte("abcdef\x00\x00")},
+		{"truncate empty", func(tx *Tx) error { return TruncateLargeObject(tx, 100, 0) }, []byte{}},
+		{"write inside", func(tx *Tx) error { return WriteLargeObjectAt(tx, 100, 2, []byte("XY")) }, []byte("abXYef")},
+		{"write over the end", func(tx *Tx) error { return WriteLargeObjectAt(tx, 100, 4, []byte("XYZ")) }, []byte("abcdXYZ")},
+		{"write beyond the end", func(tx *Tx) error { return WriteLargeObjectAt(tx, 100, 8, []byte("X")) }, []byte("abcdef\x00\x00X")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, lo, pool := newLargeObjectPool(t)
+			lo.Set(100, []byte("abcdef"))
+
+			var size int64
+			err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+				if err := tt.fn(tx); err != nil {
+					return err
+				}
+				var err error
+				size, err = LargeObjectSize(tx, 100)
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, _ := lo.Get(100); !bytes.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if size != int64(len(tt.want)) {
+				t.Errorf("size %d, want %d", size, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestLargeObjectSizeKeepsPosition(t *testing.T) {
+	_, lo, pool := newLargeObjectPool(t)
+	lo.Set(100, []byte("abcdef"))
+
+	err := RunInReadTx(pool, context.Background(), func(tx *Tx) error {
+		obj, err := openLargeObject(tx, 100, pgx.LargeObjectModeRead)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = obj.Close() }()
+
+		if _, err := obj.Seek(2, io.SeekStart); err != nil {
+			return err
+		}
+		size, err := obj.Size()
+		if err != nil {
+			return err
+		}
+		if size != 6 {
+			t.Errorf("size %d, want 6", size)
+		}
+
+		pos, err := obj.Tell()
+		if err != nil {
+			return err
+		}
+		if pos != 2 {
+			t.Errorf("position %d, want 2", pos)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCopyLargeObject(t *testing.T) {
+	_, lo, pool := newLargeObjectPool(t)
+	data := largeObjectData(2*defaultLargeObjectChunk + 10)
+	lo.Set(100, data)
+
+	var oid uint32
+	err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+		var err error
+		oid, err = CopyLargeObject(tx, 100)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if oid == 100 {
+		t.Fatal("copy has the id of the source")
+	}
+	if got, _ := lo.Get(oid); !bytes.Equal(got, data) {
+		t.Errorf("copied %d bytes, want %d", len(got), len(data))
+	}
+	if got, _ := lo.Get(100); !bytes.Equal(got, data) {
+		t.Error("source is changed")
+	}
+	if n := lo.Open(); n != 0 {
+		t.Errorf("%d descriptors are not closed", n)
+	}
+}
+
+func TestCopyLargeObjectNotFound(t *testing.T) {
+	_, lo, pool := newLargeObjectPool(t)
+
+	err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+		_, err := CopyLargeObject(tx, 100)
+		return err
+	})
+	if !IsLargeObjectNotFound(err) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if oids := lo.Oids(); len(oids) != 0 {
+		t.Errorf("objects created: %v", oids)
+	}
+}
+
+func TestRemoveOrphanedLargeObjects(t *testing.T) {
+	srv, lo, pool := newLargeObjectPool(t)
+	for _, oid := range []uint32{100, 101, 102, 103, 104} {
+		lo.Set(oid, []byte("data"))
+	}
+
+	const find = "SELECT m.oid FROM pg_largeobject_metadata m WHERE m.oid NOT IN (SELECT ref FROM (" +
+		"SELECT r.ref::oid FROM (SELECT file_oid FROM documents) AS r(ref) UNION " +
+		"SELECT r.ref::oid FROM (SELECT avatar FROM users) AS r(ref)" +
+		") AS refs(ref) WHERE ref IS NOT NULL) ORDER BY m.oid"
+	srv.Expect(find, sqlqtest.Result{
+		Columns: []sqlqtest.Column{{Name: "oid", OID: pgtype.OIDOID}},
+		Rows:    [][]any{{uint32(100)}, {uint32(102)}, {uint32(104)}},
+	})
+
+	const unlink = "SELECT lo_unlink(id::oid) FROM unnest($1::int8[]) AS id"
+	var (
+		mu      sync.Mutex
+		batches [][]int64
+	)
+	srv.Handle(func(sql string, args []any) (sqlqtest.Result, bool) {
+		if sql != unlink {
+			return sqlqtest.Result{}, false
+		}
+		res := sqlqtest.Result{Columns: []sqlqtest.Column{{Name: "lo_unlink", OID: pgtype.Int4OID}}, Params: []uint32{pgtype.Int8ArrayOID}}
+		if args == nil {
+			return res, true
+		}
+
+		var ids []int64
+		arr := args[0].(pgtype.Int8Array)
+		if err := arr.AssignTo(&ids); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		batches = append(batches, ids)
+		mu.Unlock()
+		for _, id := range ids {
+			if r, _ := lo.Handle("select lo_unlink($1)", []any{uint32(id)}); r.Err != nil {
+				t.Error(r.Err)
+			}
+			res.Rows = append(res.Rows, []any{int32(1)})
+		}
+		return res, true
+	})
+
+	var (
+		orphans []uint32
+		removed int
+	)
+	err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+		var err error
+		if orphans, err = FindOrphanedLargeObjects(tx, "SELECT file_oid FROM documents", "SELECT avatar FROM users"); err != nil {
+			return err
+		}
+		removed, err = RemoveOrphanedLargeObjects(tx, 2, "SELECT file_oid FROM documents", "SELECT avatar FROM users")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []uint32{100, 102, 104}; !reflect.DeepEqual(orphans, want) {
+		t.Errorf("orphans %v, want %v", orphans, want)
+	}
+	if removed != 3 {
+		t.Errorf("removed %d, want 3", removed)
+	}
+	mu.Lock()
+	if want := [][]int64{{100, 102}, {104}}; !reflect.DeepEqual(batches, want) {
+		t.Errorf("batches %v, want %v", batches, want)
+	}
+	mu.Unlock()
+	if want := []uint32{101, 103}; !reflect.DeepEqual(lo.Oids(), want) {
+		t.Errorf("left %v, want %v", lo.Oids(), want)
+	}
+}
+
+func TestFindOrphanedLargeObjectsNoQueries(t *testing.T) {
+	_, _, pool := newLargeObjectPool(t)
+
+	err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+		_, err := FindOrphanedLargeObjects(tx)
+		return err
+	})
+	if err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestSaveLargeObjectMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing []byte
+		data     []byte
+		mode     LargeObjectSaveMode
+		want     []byte
+		wantErr  bool
+	}{
+		{"overwrite shorter", []byte("abcdef"), []byte("xy"), LargeObjectOverwrite, []byte("xy"), false},
+		{"overwrite longer", []byte("ab"), []byte("xyz"), LargeObjectOverwrite, []byte("xyz"), false},
+		{"overwrite with empty", []byte("abc"), []byte{}, LargeObjectOverwrite, []byte{}, false},
+		{"append", []byte("abc"), []byte("de"), LargeObjectAppend, []byte("abcde"), false},
+		{"append to empty", []byte{}, []byte("de"), LargeObjectAppend, []byte("de"), false},
+		{"unknown mode", []byte("abc"), []byte("de"), LargeObjectSaveMode(10), []byte("abc"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, lo, pool := newLargeObjectPool(t)
+			lo.Set(100, tt.existing)
+
+			err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+				oid, err := SaveLargeObjectMode(tx, 100, tt.data, tt.mode)
+				if err == nil && oid != 100 {
+					t.Errorf("oid %d, want 100", oid)
+				}
+				return err
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got, _ := lo.Get(100); !bytes.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if n := lo.Open(); n != 0 {
+				t.Errorf("%d descriptors are not closed", n)
+			}
+		})
+	}
+}
+
+func TestSaveLargeObjectNew(t *testing.T) {
+	srv, lo, pool := newLargeObjectPool(t)
+
+	var oid uint32
+	err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+		var err error
+		oid, err = SaveLargeObject(tx, 0, []byte("abc"))
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := lo.Get(oid); string(got) != "abc" {
+		t.Errorf("got %q, want %q", got, "abc")
+	}
+	// a new object is not truncated
+	if n := countQueries(srv, auditLargeObjectTruncate); n != 0 {
+		t.Errorf("got %d truncate calls, want 0", n)
+	}
+}
+
+func TestLoadLargeObject(t *testing.T) {
+	_, lo, pool := newLargeObjectPool(t)
+	data := largeObjectData(100)
+	lo.Set(100, data)
+
+	var got []byte
+	err := RunInReadTx(pool, context.Background(), func(tx *Tx) error {
+		var err error
+		got, err = LoadLargeObject(tx, 100)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("loaded %d bytes, want %d", len(got), len(data))
+	}
+}
+
+func TestRemoveLargeObject(t *testing.T) {
+	_, lo, pool := newLargeObjectPool(t)
+	lo.Set(100, []byte("abc"))
+
+	err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+		return RemoveLargeObject(tx, 100)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := lo.Get(100); ok {
+		t.Error("object is not removed")
+	}
+}
+
+func TestLoadLargeObjectRange(t *testing.T) {
+	data := largeObjectData(1000)
+
+	tests := []struct {
+		name           string
+		offset, length int64
+		want           []byte
+		wantErr        bool
+	}{
+		{"middle", 100, 50, data[100:150], false},
+		{"past the end", 990, 50, data[990:], false},
+		{"offset past the end", 2000, 50, []byte{}, false},
+		{"zero length", 10, 0, []byte{}, false},
+		{"negative offset", -1, 10, nil, true},
+		{"negative length", 0, -1, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, lo, pool := newLargeObjectPool(t)
+			lo.Set(100, data)
+
+			var got []byte
+			err := RunInReadTx(pool, context.Background(), func(tx *Tx) error {
+				var err error
+				got, err = LoadLargeObjectRange(tx, 100, tt.offset, tt.length)
+				return err
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("got %d bytes, want %d", len(got), len(tt.want))
+			}
+			// only the range is read
+			if n := lo.BytesRead(); n != int64(len(tt.want)) {
+				t.Errorf("read %d bytes, want %d", n, len(tt.want))
+			}
+			if n := lo.Open(); n != 0 {
+				t.Errorf("%d descriptors are not closed", n)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package sqlq
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/n-r-w/nerr"
+)
+
+// LargeObjectHandler - http.Handler that streams Large Objects. Supports HEAD, Range and, with the registry
+// (see SetRegistry), conditional requests. Each request is served in a read only transaction that lasts until
+// the response is written. If the transaction fails after the response is started, the connection is aborted
+// with http.ErrAbortHandler: the client gets a truncated body instead of a second status
+type LargeObjectHandler struct {
+	pool        *pgxpool.Pool
+	oid         func(r *http.Request) (uint32, error)
+	contentType string
+	registry    *LargeObjectRegistry
+	onError     func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// NewLargeObjectHandler - create the handler. oid - id of the object of the request, for example from the url path
+func NewLargeObjectHandler(pool *pgxpool.Pool, oid func(r *http.Request) (uint32, error)) *LargeObjectHandler {
+	return &LargeObjectHandler{
+		pool: pool,
+		oid:  oid,
+	}
+}
+
+// SetContentType - Content-Type of the response. If not set, the type is detected from the content
+func (h *LargeObjectHandler) SetContentType(contentType string) *LargeObjectHandler {
+	h.contentType = contentType
+	return h
+}
+
+// SetRegistry - serve the objects of the registry: ETag is the checksum and Content-Type is the mime type
+// of the metadata, the objects without metadata are not found
+func (h *LargeObjectHandler) SetRegistry(registry *LargeObjectRegistry) *LargeObjectHandler {
+	h.registry = registry
+	return h
+}
+
+// SetErrorHandler - write the response for the error. By default the error text is not exposed:
+// 404 for a missing object, 400 if oid fails and 500 otherwise
+func (h *LargeObjectHandler) SetErrorHandler(f func(w http.ResponseWriter, r *http.Request, err error)) *LargeObjectHandler {
+	h.onError = f
+	return h
+}
+
+func (h *LargeObjectHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	oid, err := h.oid(r)
+	if err != nil {
+		h.writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	if h.contentType != "" {
+		w.Header().Set("Content-Type", h.contentType)
+	}
+
+	rw := &largeObjectResponse{ResponseWriter: w}
+	err = RunInReadTx(h.pool, r.Context(), func(tx *Tx) error {
+		if h.registry != nil {
+			return h.registry.Serve(rw, r, tx, oid)
+		}
+		return ServeLargeObject(rw, r, tx, oid)
+	})
+	if err != nil {
+		if rw.started {
+			// status and part of the body are already sent
+			panic(http.ErrAbortHandler)
+		}
+
+		status := http.StatusInternalServerError
+		if IsLargeObjectNotFound(err) {
+			status = http.StatusNotFound
+		}
+		h.writeError(w, r, status, err)
+	}
+}
+
+func (h *LargeObjectHandler) writeError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	if h.onError != nil {
+		h.onError(w, r, err)
+		return
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+// largeObjectResponse - tracks whether the response is started
+type largeObjectResponse struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (w *largeObjectResponse) WriteHeader(status int) {
+	w.started = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *largeObjectResponse) Write(p []byte) (int, error) {
+	w.started = true
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap - underlying writer for http.ResponseController
+func (w *largeObjectResponse) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// ServeLargeObject - write Large Object to the response with http.ServeContent: Content-Length, Range and conditional
+// requests if ETag is set in the response header, see LargeObjectRegistry.Serve. The object is not read beyond
+// the requested range. Returns an error if the object can't be opened, the response is not written in this case
+func ServeLargeObject(w http.ResponseWriter, r *http.Request, tx *Tx, oid uint32) error {
+	obj, err := openLargeObject(tx, oid, pgx.LargeObjectModeRead)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = obj.Close() }()
+
+	http.ServeContent(w, r, "", time.Time{}, obj)
+	return nil
+}
+
+// Serve - write the registered Large Object to the response, see ServeLargeObject. ETag is the checksum of
+// the metadata, Content-Type is the mime type unless already set. Returns ErrLargeObjectNotRegistered if
+// the object has no metadata, the response is not written in this case
+func (r *LargeObjectRegistry) Serve(w http.ResponseWriter, req *http.Request, tx *Tx, oid uint32) error {
+	info, ok, err := r.Get(tx, oid)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nerr.New(ErrLargeObjectNotRegistered)
+	}
+
+	w.Header().Set("ETag", fmt.Sprintf(`"%s"`, info.Checksum))
+	if info.MimeType != "" && w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", info.MimeType)
+	}
+	return ServeLargeObject(w, req, tx, oid)
+}
+
+// IsLargeObjectNotFound - the error is caused by a missing Large Object or its metadata
+func IsLargeObjectNotFound(err error) bool {
+	return SqlState(err) == SqlStateUndefinedObject || errors.Is(err, ErrLargeObjectNotRegistered)
+}
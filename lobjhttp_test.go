@@ -0,0 +1,134 @@
+package sqlq
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+const testRegistryGet = `SELECT oid, size, mime_type, checksum, created_at FROM "sqlq_lobj" WHERE oid = 100 ORDER BY oid`
+
+// expectRegistryInfo - script the metadata of the object 100, no rows if checksum is empty
+func expectRegistryInfo(srv *sqlqtest.FakePostgres, size int, checksum string) {
+	res := sqlqtest.Result{Columns: []sqlqtest.Column{
+		{Name: "oid", OID: pgtype.OIDOID},
+		{Name: "size", OID: pgtype.Int8OID},
+		{Name: "mime_type", OID: pgtype.TextOID},
+		{Name: "checksum", OID: pgtype.TextOID},
+		{Name: "created_at", OID: pgtype.TimestamptzOID},
+	}}
+	if checksum != "" {
+		res.Rows = [][]any{{uint32(100), int64(size), "image/png", checksum, time.Unix(0, 0)}}
+	}
+	srv.Expect(testRegistryGet, res)
+}
+
+func TestLargeObjectHandlerRegistry(t *testing.T) {
+	data := largeObjectData(1000)
+
+	tests := []struct {
+		name       string
+		header     map[string]string
+		wantStatus int
+		wantBody   []byte
+	}{
+		{"full", nil, http.StatusOK, data},
+		{"range", map[string]string{"Range": "bytes=100-149"}, http.StatusPartialContent, data[100:150]},
+		{"not modified", map[string]string{"If-None-Match": `"abc"`}, http.StatusNotModified, nil},
+		{"modified", map[string]string{"If-None-Match": `"def"`}, http.StatusOK, data},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, lo, pool := newLargeObjectPool(t)
+			lo.Set(100, data)
+			expectRegistryInfo(srv, len(data), "abc")
+
+			h := NewLargeObjectHandler(pool, func(*http.Request) (uint32, error) { return 100, nil }).
+				SetRegistry(NewLargeObjectRegistry(""))
+
+			req := httptest.NewRequest(http.MethodGet, "/lobj/100", nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if etag := w.Header().Get("ETag"); etag != `"abc"` {
+				t.Errorf("etag %q", etag)
+			}
+			if ct := w.Header().Get("Content-Type"); tt.wantBody != nil && ct != "image/png" {
+				t.Errorf("content type %q", ct)
+			}
+			if !bytes.Equal(w.Body.Bytes(), tt.wantBody) {
+				t.Errorf("got %d bytes, want %d", w.Body.Len(), len(tt.wantBody))
+			}
+			// the object is read only for the response body
+			if n := lo.BytesRead(); n != int64(len(tt.wantBody)) {
+				t.Errorf("read %d bytes, want %d", n, len(tt.wantBody))
+			}
+		})
+	}
+}
+
+func TestLargeObjectHandlerNotFound(t *testing.T) {
+	tests := []struct {
+		name     string
+		registry bool
+	}{
+		{"missing object", false},
+		{"not registered", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, lo, pool := newLargeObjectPool(t)
+			h := NewLargeObjectHandler(pool, func(*http.Request) (uint32, error) { return 100, nil })
+			if tt.registry {
+				lo.Set(100, []byte("abc"))
+				expectRegistryInfo(srv, 0, "")
+				h.SetRegistry(NewLargeObjectRegistry(""))
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/lobj/100", nil))
+			if w.Code != http.StatusNotFound {
+				t.Errorf("status %d, want %d", w.Code, http.StatusNotFound)
+			}
+			if n := lo.BytesRead(); n != 0 {
+				t.Errorf("read %d bytes", n)
+			}
+		})
+	}
+}
+
+func TestLargeObjectHandlerAbortsStartedResponse(t *testing.T) {
+	srv, lo, pool := newLargeObjectPool(t)
+	lo.Set(100, []byte("abc"))
+	srv.ExpectError("commit", "40001", "could not serialize access")
+
+	h := NewLargeObjectHandler(pool, func(*http.Request) (uint32, error) { return 100, nil })
+	w := httptest.NewRecorder()
+
+	func() {
+		defer func() {
+			if r := recover(); r != http.ErrAbortHandler {
+				t.Errorf("recovered %v, want http.ErrAbortHandler", r)
+			}
+		}()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/lobj/100", nil))
+	}()
+
+	// no second status in the body
+	if w.Code != http.StatusOK || w.Body.String() != "abc" {
+		t.Errorf("status %d, body %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,117 @@
+package sqlq
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// default table of LargeObjectRegistry
+const defaultLargeObjectTable = "sqlq_lobj"
+
+// ErrLargeObjectNotRegistered - Large Object has no metadata in the registry
+var ErrLargeObjectNotRegistered = errors.New("large object is not registered")
+
+// LargeObjectInfo - metadata of Large Object
+type LargeObjectInfo struct {
+	Oid      uint32 `db:"oid"`
+	Size     int64  `db:"size"`
+	MimeType string `db:"mime_type"`
+	// Checksum - md5 of the content, hex encoded
+	Checksum  string    `db:"checksum"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// LargeObjectRegistry - metadata of Large Objects in a table (sqlq_lobj by default). The objects are saved and removed
+// through the registry, the metadata is changed in the same transaction, so it is consistent with the objects
+type LargeObjectRegistry struct {
+	table  string
+	mapper FieldMapper
+}
+
+// NewLargeObjectRegistry - create the registry. table - metadata table, may be schema-qualified. Empty - sqlq_lobj
+func NewLargeObjectRegistry(table string) *LargeObjectRegistry {
+	if table == "" {
+		table = defaultLargeObjectTable
+	}
+	return &LargeObjectRegistry{
+		table:  table,
+		mapper: &DefaultFieldMapper{},
+	}
+}
+
+// CreateTable - create the metadata table if it doesn't exist
+func (r *LargeObjectRegistry) CreateTable(tx *Tx) error {
+	return NewQueryTx(tx, tx.ctx).Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	oid oid PRIMARY KEY,
+	size bigint NOT NULL,
+	mime_type text NOT NULL DEFAULT '',
+	checksum text NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT now()
+)`, quoteName(r.table)))
+}
+
+// Save - write Large Object (see SaveLargeObject) and its metadata. If oid == 0 then creates a new object.
+// Returns the id of the created or updated object
+func (r *LargeObjectRegistry) Save(tx *Tx, oid uint32, data []byte, mimeType string) (uint32, error) {
+	oid, err := SaveLargeObject(tx, oid, data)
+	if err != nil {
+		return 0, err
+	}
+
+	sum := md5.Sum(data)
+	return oid, r.saveInfo(tx, oid, int64(len(data)), mimeType, hex.EncodeToString(sum[:]))
+}
+
+// SaveFrom - write Large Object from the reader (see SaveLargeObjectFrom) and its metadata.
+// If oid == 0 then creates a new object. Returns the id of the created or updated object
+func (r *LargeObjectRegistry) SaveFrom(tx *Tx, oid uint32, src io.Reader, chunkSize int, mimeType string) (uint32, error) {
+	h := md5.New()
+	var size int64
+	oid, err := SaveLargeObjectFrom(tx, oid, io.TeeReader(src, h), chunkSize, func(written int64) { size = written })
+	if err != nil {
+		return 0, err
+	}
+
+	return oid, r.saveInfo(tx, oid, size, mimeType, hex.EncodeToString(h.Sum(nil)))
+}
+
+func (r *LargeObjectRegistry) saveInfo(tx *Tx, oid uint32, size int64, mimeType string, checksum string) error {
+	return NewQueryTx(tx, tx.ctx).ExecArgs(fmt.Sprintf(`INSERT INTO %s (oid, size, mime_type, checksum) VALUES ($1, $2, $3, $4)
+ON CONFLICT (oid) DO UPDATE SET size = EXCLUDED.size, mime_type = EXCLUDED.mime_type, checksum = EXCLUDED.checksum`,
+		quoteName(r.table)), oid, size, mimeType, checksum)
+}
+
+// Remove - remove Large Object and its metadata
+func (r *LargeObjectRegistry) Remove(tx *Tx, oid uint32) error {
+	if err := RemoveLargeObject(tx, oid); err != nil {
+		return err
+	}
+	return NewQueryTx(tx, tx.ctx).ExecArgs(fmt.Sprintf("DELETE FROM %s WHERE oid = $1", quoteName(r.table)), oid)
+}
+
+// Get - metadata of Large Object. false if the object is not registered
+func (r *LargeObjectRegistry) Get(tx *Tx, oid uint32) (LargeObjectInfo, bool, error) {
+	infos, err := r.List(tx, fmt.Sprintf("oid = %d", oid))
+	if err != nil || len(infos) == 0 {
+		return LargeObjectInfo{}, false, err
+	}
+	return infos[0], true, nil
+}
+
+// List - metadata of the registered objects ordered by oid. where - optional condition on the columns of the table:
+// oid, size, mime_type, checksum, created_at
+func (r *LargeObjectRegistry) List(tx *Tx, where string) ([]LargeObjectInfo, error) {
+	sql := fmt.Sprintf("SELECT oid, size, mime_type, checksum, created_at FROM %s", quoteName(r.table))
+	if where != "" {
+		sql += " WHERE " + where
+	}
+	sql += " ORDER BY oid"
+
+	q := NewQueryTx(tx, tx.ctx)
+	q.SetFieldMapper(r.mapper)
+	return selectStructsHelper[LargeObjectInfo](q, sql)
+}
@@ -0,0 +1,175 @@
+package sqlq
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+// registryStatements - records the arguments of the metadata statements of the default registry
+type registryStatements struct {
+	mu      sync.Mutex
+	inserts [][]any
+	deletes []uint32
+}
+
+func (s *registryStatements) handle(sql string, args []any) (sqlqtest.Result, bool) {
+	sql = strings.Join(strings.Fields(sql), " ")
+	switch {
+	case strings.HasPrefix(sql, `INSERT INTO "sqlq_lobj" (oid, size, mime_type, checksum) VALUES ($1, $2, $3, $4) ON CONFLICT (oid) DO UPDATE`):
+		res := sqlqtest.Result{Tag: "INSERT 0 1", Params: []uint32{pgtype.OIDOID, pgtype.Int8OID, pgtype.TextOID, pgtype.TextOID}}
+		if args != nil {
+			s.mu.Lock()
+			s.inserts = append(s.inserts, args)
+			s.mu.Unlock()
+		}
+		return res, true
+
+	case sql == `DELETE FROM "sqlq_lobj" WHERE oid = $1`:
+		res := sqlqtest.Result{Tag: "DELETE 1", Params: []uint32{pgtype.OIDOID}}
+		if args != nil {
+			s.mu.Lock()
+			s.deletes = append(s.deletes, args[0].(uint32))
+			s.mu.Unlock()
+		}
+		return res, true
+	}
+	return sqlqtest.Result{}, false
+}
+
+func TestLargeObjectRegistrySave(t *testing.T) {
+	data := largeObjectData(1000)
+	sum := md5.Sum(data)
+	checksum := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name string
+		save func(r *LargeObjectRegistry, tx *Tx) (uint32, error)
+	}{
+		{"bytes", func(r *LargeObjectRegistry, tx *Tx) (uint32, error) {
+			return r.Save(tx, 0, data, "image/png")
+		}},
+		{"reader", func(r *LargeObjectRegistry, tx *Tx) (uint32, error) {
+			return r.SaveFrom(tx, 0, iotest.HalfReader(bytes.NewReader(data)), 100, "image/png")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, lo, pool := newLargeObjectPool(t)
+			stmts := &registryStatements{}
+			srv.Handle(stmts.handle)
+
+			var oid uint32
+			err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+				var err error
+				oid, err = tt.save(NewLargeObjectRegistry(""), tx)
+				return err
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got, _ := lo.Get(oid); !bytes.Equal(got, data) {
+				t.Errorf("saved %d bytes, want %d", len(got), len(data))
+			}
+			want := [][]any{{oid, int64(len(data)), "image/png", checksum}}
+			if !reflect.DeepEqual(stmts.inserts, want) {
+				t.Errorf("metadata %v, want %v", stmts.inserts, want)
+			}
+		})
+	}
+}
+
+func TestLargeObjectRegistryRemove(t *testing.T) {
+	srv, lo, pool := newLargeObjectPool(t)
+	stmts := &registryStatements{}
+	srv.Handle(stmts.handle)
+	lo.Set(100, []byte("abc"))
+
+	err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+		return NewLargeObjectRegistry("").Remove(tx, 100)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := lo.Get(100); ok {
+		t.Error("object is not removed")
+	}
+	if want := []uint32{100}; !reflect.DeepEqual(stmts.deletes, want) {
+		t.Errorf("deleted %v, want %v", stmts.deletes, want)
+	}
+}
+
+func TestLargeObjectRegistryRemoveNotFound(t *testing.T) {
+	srv, _, pool := newLargeObjectPool(t)
+	stmts := &registryStatements{}
+	srv.Handle(stmts.handle)
+
+	err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+		return NewLargeObjectRegistry("").Remove(tx, 100)
+	})
+	if !IsLargeObjectNotFound(err) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the metadata is kept if the object can't be removed
+	if len(stmts.deletes) != 0 {
+		t.Errorf("deleted %v", stmts.deletes)
+	}
+}
+
+func TestLargeObjectRegistryList(t *testing.T) {
+	srv, pool := newTestPool(t)
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	srv.Expect(`SELECT oid, size, mime_type, checksum, created_at FROM "files"."lobj" WHERE size > 10 ORDER BY oid`, sqlqtest.Result{
+		Columns: []sqlqtest.Column{
+			{Name: "oid", OID: pgtype.OIDOID},
+			{Name: "size", OID: pgtype.Int8OID},
+			{Name: "mime_type", OID: pgtype.TextOID},
+			{Name: "checksum", OID: pgtype.TextOID},
+			{Name: "created_at", OID: pgtype.TimestamptzOID},
+		},
+		Rows: [][]any{
+			{uint32(100), int64(20), "image/png", "abc", created},
+			{uint32(101), int64(30), "", "def", created},
+		},
+	})
+
+	var got []LargeObjectInfo
+	err := RunInReadTx(pool, context.Background(), func(tx *Tx) error {
+		var err error
+		got, err = NewLargeObjectRegistry("files.lobj").List(tx, "size > 10")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []LargeObjectInfo{
+		{Oid: 100, Size: 20, MimeType: "image/png", Checksum: "abc", CreatedAt: created},
+		{Oid: 101, Size: 30, Checksum: "def", CreatedAt: created},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d objects, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !got[i].CreatedAt.Equal(want[i].CreatedAt) {
+			t.Errorf("created at %v, want %v", got[i].CreatedAt, want[i].CreatedAt)
+		}
+		got[i].CreatedAt = want[i].CreatedAt
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
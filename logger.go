@@ -0,0 +1,177 @@
+package sqlq
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// LogEntry - executed statement
+type LogEntry struct {
+	Sql string
+	// Duration - execution time, for Select until the rows are closed
+	Duration time.Duration
+	// Rows - number of rows affected by the command or read from the select
+	Rows int64
+	Err  error
+	// Fields - metadata of the transaction and the query (request id, user id etc.)
+	Fields map[string]any
+}
+
+// Logger - receiver of the executed statements
+type Logger interface {
+	LogStatement(ctx context.Context, entry LogEntry)
+}
+
+// LoggerFunc - function implementation of Logger
+type LoggerFunc func(ctx context.Context, entry LogEntry)
+
+func (f LoggerFunc) LogStatement(ctx context.Context, entry LogEntry) {
+	f(ctx, entry)
+}
+
+// poolLoggers - loggers of the pools: *pgxpool.Pool -> Logger
+var poolLoggers sync.Map
+
+// SetPoolLogger - logger of the statements executed by all queries of the pool and its transactions.
+// nil - remove the logger
+func SetPoolLogger(pool *pgxpool.Pool, logger Logger) {
+	if logger == nil {
+		poolLoggers.Delete(pool)
+		return
+	}
+	poolLoggers.Store(pool, logger)
+}
+
+// SetLogger - logger of the statements executed by all queries of the transaction.
+// Takes precedence over the logger of the pool
+func (t *Tx) SetLogger(logger Logger) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.logger = logger
+}
+
+// SetMetadata - add the field that is passed to the logger with every statement of the transaction
+func (t *Tx) SetMetadata(key string, value any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.metadata == nil {
+		t.metadata = map[string]any{}
+	}
+	t.metadata[key] = value
+}
+
+// Metadata - fields of the transaction, see SetMetadata
+func (t *Tx) Metadata() map[string]any {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	res := make(map[string]any, len(t.metadata))
+	for k, v := range t.metadata {
+		res[k] = v
+	}
+	return res
+}
+
+// SetLogger - logger of the statements of the query.
+// Takes precedence over the logger of the transaction and the pool
+func (q *Query) SetLogger(logger Logger) {
+	q.queryLogger = logger
+}
+
+// SetMetadata - add the field that is passed to the logger with every statement of the query.
+// Takes precedence over the field of the transaction with the same key
+func (q *Query) SetMetadata(key string, value any) {
+	if q.metadata == nil {
+		q.metadata = map[string]any{}
+	}
+	q.metadata[key] = value
+}
+
+// Metadata - fields of the transaction and the query
+func (q *Query) Metadata() map[string]any {
+	var res map[string]any
+	if q.tx != nil {
+		res = q.tx.Metadata()
+	} else {
+		res = make(map[string]any, len(q.metadata))
+	}
+	for k, v := range q.metadata {
+		res[k] = v
+	}
+	return res
+}
+
+// logger - logger of the query, nil if not set
+func (q *Query) logger() Logger {
+	if q.queryLogger != nil {
+		return q.queryLogger
+	}
+
+	if q.tx != nil {
+		q.tx.mu.Lock()
+		logger := q.tx.logger
+		q.tx.mu.Unlock()
+		if logger != nil {
+			return logger
+		}
+	}
+
+	if q.pool != nil {
+		if logger, ok := poolLoggers.Load(q.pool); ok {
+			return logger.(Logger)
+		}
+	}
+	return nil
+}
+
+// logExec - execute the command and log it
+func (q *Query) logExec(ctx context.Context, logger Logger, f ExecFunc, sql string, args []any) (pgconn.CommandTag, error) {
+	start := time.Now()
+	tag, err := f(ctx, sql, args...)
+	q.log(logger, sql, start, tag.RowsAffected(), err)
+	return tag, err
+}
+
+// logQuery - execute the select and log it after the rows are closed
+func (q *Query) logQuery(ctx context.Context, logger Logger, f QueryFunc, sql string, args []any) (pgx.Rows, error) {
+	start := time.Now()
+	rows, err := f(ctx, sql, args...)
+	if err != nil {
+		q.log(logger, sql, start, 0, err)
+		return nil, err
+	}
+
+	counted := &countRows{Rows: rows}
+	return &closeHookRows{Rows: counted, onClose: func(rows pgx.Rows) { q.log(logger, sql, start, counted.n, rows.Err()) }}, nil
+}
+
+func (q *Query) log(logger Logger, sql string, start time.Time, rows int64, err error) {
+	logger.LogStatement(q.ctx, LogEntry{
+		Sql:      sql,
+		Duration: time.Since(start),
+		Rows:     rows,
+		Err:      err,
+		Fields:   q.Metadata(),
+	})
+}
+
+// countRows - counts the rows read by Next
+type countRows struct {
+	pgx.Rows
+	n int64
+}
+
+func (r *countRows) Next() bool {
+	if !r.Rows.Next() {
+		return false
+	}
+	r.n++
+	return true
+}
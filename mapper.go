@@ -0,0 +1,101 @@
+package sqlq
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// FieldMapper - resolution of column names for struct fields, used by ScanStruct. The result for each struct type
+// is cached, if the mapper is comparable, so the mapping must not change over time
+type FieldMapper interface {
+	// ColumnName - column name for the struct field. Empty string - the field is not mapped
+	ColumnName(field reflect.StructField) string
+	// Prefix - column name prefix for the fields of the nested struct field. false - the nested struct is not mapped
+	Prefix(field reflect.StructField) (string, bool)
+}
+
+// DefaultFieldMapper - column name is taken from the tag (db by default) or the field name converted to snake_case.
+// The tag value "-" skips the field. Nested structs are mapped with the prefix "<name>_" (db:"a" -> a_id, a_name).
+// Use a pointer (&DefaultFieldMapper{...}), so that struct field resolution can be cached
+type DefaultFieldMapper struct {
+	// Tag - struct tag with the column name. Empty - "db"
+	Tag string
+	// NameFunc - conversion of the field name when there is no tag. nil - snake_case
+	NameFunc func(name string) string
+}
+
+var defaultFieldMapper FieldMapper = &DefaultFieldMapper{}
+
+// SetDefaultFieldMapper - column name resolution used by queries without their own mapper.
+// Must be called at initialization, before queries are executed
+func SetDefaultFieldMapper(mapper FieldMapper) {
+	if mapper == nil {
+		mapper = &DefaultFieldMapper{}
+	}
+	defaultFieldMapper = mapper
+}
+
+// ColumnName - implementation of FieldMapper
+func (m DefaultFieldMapper) ColumnName(field reflect.StructField) string {
+	name, ok := m.name(field)
+	if !ok {
+		return ""
+	}
+	return name
+}
+
+// Prefix - implementation of FieldMapper
+func (m DefaultFieldMapper) Prefix(field reflect.StructField) (string, bool) {
+	name, ok := m.name(field)
+	if !ok {
+		return "", false
+	}
+	return name + "_", true
+}
+
+func (m DefaultFieldMapper) name(field reflect.StructField) (string, bool) {
+	tagName := m.Tag
+	if tagName == "" {
+		tagName = "db"
+	}
+
+	tag := field.Tag.Get(tagName)
+	if tag == "-" {
+		return "", false
+	}
+	if tag != "" {
+		return tag, true
+	}
+
+	if m.NameFunc != nil {
+		return m.NameFunc(field.Name), true
+	}
+	return snakeCase(field.Name), true
+}
+
+// structValues - values of the struct fields by column name, resolved by the mapper. Fields of nil nested pointers are nil
+func structValues(src any, mapper FieldMapper) (map[string]any, error) {
+	v := reflect.ValueOf(src)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil, fmt.Errorf("source must be a non-nil struct: %T", src)
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("source must be a struct: %T", src)
+	}
+
+	fields := cachedStructFields(v.Type(), mapper)
+	res := make(map[string]any, len(fields))
+	for _, f := range fields {
+		fv := fieldByIndex(v, f.index, false)
+		if !fv.IsValid() {
+			res[f.column] = nil
+			continue
+		}
+		res[f.column] = fv.Interface()
+	}
+
+	return res, nil
+}
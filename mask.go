@@ -0,0 +1,150 @@
+package sqlq
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/n-r-w/nerr"
+)
+
+const maskedViewCommentPrefix = "sqlq:masked_view:version="
+
+// sqlStateInvalidTableDefinition - CREATE OR REPLACE VIEW can't drop, rename or change the type of the view columns
+const sqlStateInvalidTableDefinition = "42P16"
+
+// MaskedColumn - column of the masked view
+type MaskedColumn struct {
+	// Name - source column name
+	Name string
+	// Mask - sql expression over the source columns, for example MaskHashExpr("email"). Empty - the column is copied as is
+	Mask string
+}
+
+// MaskedView - view over a sensitive table with masked columns, granted to reporting roles
+type MaskedView struct {
+	// Name - view name, may be schema-qualified
+	Name string
+	// Table - source table, may be schema-qualified
+	Table string
+	// Columns - view columns
+	Columns []MaskedColumn
+	// Roles - roles that are granted SELECT on the view
+	Roles []string
+	// Version - version of the view definition, must be greater than 0. Apply does nothing if the database already has this version
+	Version int
+}
+
+// MaskHashExpr - mask expression that replaces the value with its md5 hash
+func MaskHashExpr(column string) string {
+	return fmt.Sprintf("md5(%s::text)", quoteIdent(column))
+}
+
+// MaskPartialExpr - mask expression that keeps the first visible characters and replaces the rest with '*'
+func MaskPartialExpr(column string, visible int) string {
+	c := quoteIdent(column)
+	return fmt.Sprintf("left(%s::text, %d) || repeat('*', greatest(length(%s::text) - %d, 0))", c, visible, c, visible)
+}
+
+// Sql - statements that create or replace the view and grant it to the roles
+func (v *MaskedView) Sql() ([]string, error) {
+	if v.Name == "" || v.Table == "" {
+		return nil, nerr.New("view name and table must be set")
+	}
+	if len(v.Columns) == 0 {
+		return nil, nerr.New("no columns in the masked view")
+	}
+	if v.Version <= 0 {
+		return nil, nerr.New("masked view version must be greater than 0")
+	}
+
+	cols := make([]string, 0, len(v.Columns))
+	for _, c := range v.Columns {
+		if c.Mask == "" {
+			cols = append(cols, quoteIdent(c.Name))
+		} else {
+			cols = append(cols, fmt.Sprintf("%s AS %s", c.Mask, quoteIdent(c.Name)))
+		}
+	}
+
+	view := quoteName(v.Name)
+	res := []string{
+		fmt.Sprintf("CREATE OR REPLACE VIEW %s AS SELECT %s FROM %s", view, strings.Join(cols, ", "), quoteName(v.Table)),
+		fmt.Sprintf("COMMENT ON VIEW %s IS %s", view, quoteLiteral(maskedViewCommentPrefix+strconv.Itoa(v.Version))),
+	}
+	for _, r := range v.Roles {
+		res = append(res, fmt.Sprintf("GRANT SELECT ON %s TO %s", view, quoteIdent(r)))
+	}
+
+	return res, nil
+}
+
+// DatabaseVersion - version of the view in the database. 0 - the view does not exist or was not created by MaskedView
+func (v *MaskedView) DatabaseVersion(tx *Tx) (int, error) {
+	q, err := SelectTxRow(tx, fmt.Sprintf("SELECT obj_description(to_regclass(%s), 'pg_class') AS comment", quoteLiteral(quoteName(v.Name))))
+	if err != nil {
+		return 0, err
+	}
+	if q == nil || q.IsNull("comment") {
+		return 0, nil
+	}
+
+	comment := q.String("comment")
+	if !strings.HasPrefix(comment, maskedViewCommentPrefix) {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(strings.TrimPrefix(comment, maskedViewCommentPrefix))
+	if err != nil {
+		return 0, nil
+	}
+
+	return version, nil
+}
+
+// Apply - create or replace the view if its version in the database differs. The view is dropped and created again
+// only if its columns can't be replaced: removed, renamed or changed type. Returns true if the view has been changed
+func (v *MaskedView) Apply(tx *Tx) (bool, error) {
+	statements, err := v.Sql()
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Begin(); err != nil {
+		return false, err
+	}
+
+	version, err := v.DatabaseVersion(tx)
+	if err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+	if version == v.Version {
+		return false, tx.Commit()
+	}
+
+	err = execMaskedView(tx, statements)
+	if SqlState(err) == sqlStateInvalidTableDefinition {
+		err = execMaskedView(tx, append([]string{fmt.Sprintf("DROP VIEW %s", quoteName(v.Name))}, statements...))
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// execMaskedView - execute the statements in a nested transaction, rolled back on error
+func execMaskedView(tx *Tx, statements []string) error {
+	if err := tx.Begin(); err != nil {
+		return err
+	}
+	for _, sql := range statements {
+		if _, err := ExecTx(tx, sql); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,73 @@
+package sqlq
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+func TestMaskedViewApply(t *testing.T) {
+	view := &MaskedView{
+		Name:    "Reports.users_masked",
+		Table:   "users",
+		Columns: []MaskedColumn{{Name: "id"}, {Name: "email", Mask: MaskHashExpr("email")}},
+		Version: 2,
+	}
+	const create = `CREATE OR REPLACE VIEW "Reports"."users_masked" AS SELECT "id", md5("email"::text) AS "email" FROM "users"`
+	const comment = `COMMENT ON VIEW "Reports"."users_masked" IS 'sqlq:masked_view:version=2'`
+
+	tests := []struct {
+		name     string
+		replace  bool
+		wantDrop bool
+	}{
+		{"replace", true, false},
+		{"columns changed", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, pool := newTestPool(t)
+			srv.Expect(`SELECT obj_description(to_regclass('"Reports"."users_masked"'), 'pg_class') AS comment`,
+				sqlqtest.Result{Columns: []sqlqtest.Column{{Name: "comment"}}, Rows: [][]any{{"sqlq:masked_view:version=1"}}})
+			if tt.replace {
+				srv.Expect(create, sqlqtest.Result{Tag: "CREATE VIEW"})
+			} else {
+				srv.ExpectError(create, sqlStateInvalidTableDefinition, "cannot drop columns from view")
+			}
+			srv.Expect(`DROP VIEW "Reports"."users_masked"`, sqlqtest.Result{Tag: "DROP VIEW"})
+			srv.Expect(comment, sqlqtest.Result{Tag: "COMMENT"})
+
+			// the fake server fails the replace again after the drop, the attempt is enough
+			tx := NewTx(pool, context.Background())
+			changed, err := view.Apply(tx)
+			if tt.replace {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !changed {
+					t.Error("view is not changed")
+				}
+			} else if SqlState(err) != sqlStateInvalidTableDefinition {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var got []string
+			for _, sql := range srv.Queries() {
+				if strings.HasPrefix(sql, "DROP") || strings.HasPrefix(sql, "CREATE") {
+					got = append(got, sql)
+				}
+			}
+			want := []string{create}
+			if tt.wantDrop {
+				want = append(want, `DROP VIEW "Reports"."users_masked"`, create)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
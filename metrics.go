@@ -0,0 +1,117 @@
+package sqlq
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// StatementMetrics - receiver of the statement metrics, see PrometheusMetrics for the Prometheus collectors:
+// latency histogram, error counter by SqlState, in-flight gauge and pool acquire wait histogram.
+// The methods are called synchronously from the goroutines that execute the statements, they must be fast
+type StatementMetrics interface {
+	// StatementStarted - the statement started, the number of in-flight statements is increased
+	StatementStarted()
+	// StatementFinished - the statement completed, for Select when the rows are closed. err - error of the statement
+	StatementFinished(duration time.Duration, err error)
+	// ConnAcquired - time of waiting for a pool connection. Reported for the statements executed on the pool
+	// outside of a transaction
+	ConnAcquired(wait time.Duration)
+}
+
+var (
+	globalStatementMetricsMu sync.RWMutex
+	globalStatementMetrics   StatementMetrics
+)
+
+// SetStatementMetrics - metrics receiver for all statements. nil - disable
+func SetStatementMetrics(m StatementMetrics) {
+	globalStatementMetricsMu.Lock()
+	defer globalStatementMetricsMu.Unlock()
+	globalStatementMetrics = m
+}
+
+type statementMetricsKey struct{}
+
+// WithStatementMetrics - metrics receiver of the queries and transactions created with the context,
+// e.g. NewQuery(pool, WithStatementMetrics(ctx, m)). Takes precedence over the receiver set with SetStatementMetrics
+func WithStatementMetrics(ctx context.Context, m StatementMetrics) context.Context {
+	return context.WithValue(ctx, statementMetricsKey{}, m)
+}
+
+func statementMetricsFromContext(ctx context.Context) StatementMetrics {
+	if ctx == nil {
+		return nil
+	}
+	m, _ := ctx.Value(statementMetricsKey{}).(StatementMetrics)
+	return m
+}
+
+// SetStatementMetrics - metrics receiver of the statements of the transaction instead of the one set
+// with the package SetStatementMetrics
+func (t *Tx) SetStatementMetrics(m StatementMetrics) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stmtMetrics = m
+}
+
+// SetStatementMetrics - metrics receiver of the statements of the query.
+// Takes precedence over the receiver of the context, the transaction and the package one
+func (q *Query) SetStatementMetrics(m StatementMetrics) {
+	q.stmtMetrics = m
+}
+
+// statementMetrics - metrics receiver of the query, nil if not set
+func (q *Query) statementMetrics() StatementMetrics {
+	if q.stmtMetrics != nil {
+		return q.stmtMetrics
+	}
+	if m := statementMetricsFromContext(q.ctx); m != nil {
+		return m
+	}
+
+	if q.tx != nil {
+		q.tx.mu.Lock()
+		m := q.tx.stmtMetrics
+		q.tx.mu.Unlock()
+		if m != nil {
+			return m
+		}
+		if m := statementMetricsFromContext(q.tx.ctx); m != nil {
+			return m
+		}
+	}
+
+	globalStatementMetricsMu.RLock()
+	defer globalStatementMetricsMu.RUnlock()
+	return globalStatementMetrics
+}
+
+// metricsExec - report the metrics of the command
+func metricsExec(m StatementMetrics, f ExecFunc) ExecFunc {
+	return func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+		m.StatementStarted()
+		start := time.Now()
+		tag, err := f(ctx, sql, args...)
+		m.StatementFinished(time.Since(start), err)
+		return tag, err
+	}
+}
+
+// metricsQuery - report the metrics of the select after the rows are closed
+func metricsQuery(m StatementMetrics, f QueryFunc) QueryFunc {
+	return func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+		m.StatementStarted()
+		start := time.Now()
+		rows, err := f(ctx, sql, args...)
+		if err != nil {
+			m.StatementFinished(time.Since(start), err)
+			return nil, err
+		}
+
+		return &closeHookRows{Rows: rows, onClose: func(rows pgx.Rows) { m.StatementFinished(time.Since(start), rows.Err()) }}, nil
+	}
+}
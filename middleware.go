@@ -0,0 +1,97 @@
+package sqlq
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// ExecFunc - execution of the insert, update, delete command. args - values of $1, $2... placeholders
+type ExecFunc func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+
+// QueryFunc - execution of the select command. args - values of $1, $2... placeholders. The returned rows are read by Query, resources held
+// for the statement should be released when the rows are closed
+type QueryFunc func(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+
+// Middleware - wraps the execution of statements. Middlewares are applied in the order: package (Use),
+// transaction (Tx.Use), query (Query.Use); the first one is the outermost
+type Middleware interface {
+	Exec(next ExecFunc) ExecFunc
+	Query(next QueryFunc) QueryFunc
+}
+
+var (
+	globalMiddlewaresMu sync.RWMutex
+	globalMiddlewares   []Middleware
+)
+
+// Use - add middlewares for all queries
+func Use(mw ...Middleware) {
+	globalMiddlewaresMu.Lock()
+	defer globalMiddlewaresMu.Unlock()
+	globalMiddlewares = append(globalMiddlewares, mw...)
+}
+
+// Use - add middlewares for the statements of the query
+func (q *Query) Use(mw ...Middleware) {
+	q.mws = append(q.mws, mw...)
+}
+
+// Use - add middlewares for the statements of all queries executed in the transaction
+func (t *Tx) Use(mw ...Middleware) {
+	t.mws = append(t.mws, mw...)
+}
+
+// middlewares - the middleware chain of the query
+func (q *Query) middlewares() []Middleware {
+	globalMiddlewaresMu.RLock()
+	res := append([]Middleware{}, globalMiddlewares...)
+	globalMiddlewaresMu.RUnlock()
+
+	if q.tx != nil {
+		res = append(res, q.tx.mws...)
+	}
+	return append(res, q.mws...)
+}
+
+type txContextKey struct{}
+
+// TxFromContext - transaction of the statement for the middlewares: the context passed to ExecFunc and QueryFunc
+// contains the transaction the statement is executed in. nil outside of a transaction
+func TxFromContext(ctx context.Context) *Tx {
+	tx, _ := ctx.Value(txContextKey{}).(*Tx)
+	return tx
+}
+
+// middlewareContext - context of the statement passed to the middleware chain
+func (q *Query) middlewareContext(ctx context.Context) context.Context {
+	if q.tx == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, txContextKey{}, q.tx)
+}
+
+// closeHookRows - rows that call onClose once after closing
+type closeHookRows struct {
+	pgx.Rows
+	onClose func(rows pgx.Rows)
+}
+
+func (r *closeHookRows) Next() bool {
+	if !r.Rows.Next() {
+		r.Close()
+		return false
+	}
+	return true
+}
+
+func (r *closeHookRows) Close() {
+	r.Rows.Close()
+	if r.onClose != nil {
+		f := r.onClose
+		r.onClose = nil
+		f(r.Rows)
+	}
+}
@@ -0,0 +1,72 @@
+package sqlq
+
+import (
+	"sync"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// NoticeHandler - receiver of the notices (RAISE NOTICE, WARNING etc.) sent by the server during the statement
+type NoticeHandler func(notice *pgconn.Notice)
+
+// noticeHandlers - handlers of the connections executing statements: *pgconn.PgConn -> NoticeHandler
+var noticeHandlers sync.Map
+
+// DispatchNotice - pgconn.Config.OnNotice that passes the notices to the handlers of Query.SetNoticeHandler and
+// Tx.SetNoticeHandler. Set by WithNotices, or manually if the pool is created without Connect:
+// cfg.ConnConfig.OnNotice = sqlq.DispatchNotice
+func DispatchNotice(c *pgconn.PgConn, notice *pgconn.Notice) {
+	if h, ok := noticeHandlers.Load(c); ok {
+		h.(NoticeHandler)(notice)
+	}
+}
+
+// WithNotices - Connect option that enables the notice handlers of the queries and transactions of the pool
+func WithNotices() ConnectOption {
+	return func(cfg *pgxpool.Config) {
+		cfg.ConnConfig.OnNotice = DispatchNotice
+	}
+}
+
+// SetNoticeHandler - receiver of the notices of the statements of the transaction.
+// Requires DispatchNotice in the pool configuration, see WithNotices
+func (t *Tx) SetNoticeHandler(h NoticeHandler) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.noticeHandler = h
+}
+
+// SetNoticeHandler - receiver of the notices of the statements of the query.
+// Takes precedence over the handler of the transaction. Requires DispatchNotice in the pool configuration, see WithNotices
+func (q *Query) SetNoticeHandler(h NoticeHandler) {
+	q.onNotice = h
+}
+
+// noticeHandler - notice receiver of the query, nil if not set
+func (q *Query) noticeHandler() NoticeHandler {
+	if q.onNotice != nil {
+		return q.onNotice
+	}
+
+	if q.tx != nil {
+		q.tx.mu.Lock()
+		defer q.tx.mu.Unlock()
+		return q.tx.noticeHandler
+	}
+	return nil
+}
+
+// watchNotices - pass the notices of the connection to the handler of the query until release is called
+func (q *Query) watchNotices(c *pgconn.PgConn, release func()) func() {
+	h := q.noticeHandler()
+	if h == nil {
+		return release
+	}
+
+	noticeHandlers.Store(c, h)
+	return func() {
+		noticeHandlers.Delete(c)
+		release()
+	}
+}
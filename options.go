@@ -0,0 +1,61 @@
+package sqlq
+
+import (
+	"context"
+	"time"
+)
+
+type callOptions struct {
+	timeout time.Duration
+}
+
+// CallOption - option of a single Exec or Select call. Passed after the sql, for ExecArgs and SelectArgs
+// it can be mixed with the parameters: q.SelectArgs(sql, id, sqlq.WithTimeout(time.Second))
+type CallOption func(o *callOptions)
+
+// WithTimeout - the statement is executed with a child context of the query context with the timeout.
+// For a select the timeout also covers reading the rows, the context is canceled when the rows are closed
+func WithTimeout(timeout time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.timeout = timeout
+	}
+}
+
+// splitCallOptions - the statement parameters without the options and the context of the call.
+// cancel is nil if the call uses the query context
+func (q *Query) splitCallOptions(args []any) ([]any, context.Context, context.CancelFunc) {
+	var o callOptions
+	found := false
+	for _, a := range args {
+		if opt, ok := a.(CallOption); ok {
+			opt(&o)
+			found = true
+		}
+	}
+	if !found {
+		return args, q.ctx, nil
+	}
+
+	params := make([]any, 0, len(args))
+	for _, a := range args {
+		if _, ok := a.(CallOption); !ok {
+			params = append(params, a)
+		}
+	}
+
+	if o.timeout <= 0 {
+		return params, q.ctx, nil
+	}
+
+	ctx, cancel := context.WithTimeout(q.ctx, o.timeout)
+	return params, ctx, cancel
+}
+
+// callOptionArgs - options as the statement parameters
+func callOptionArgs(opts []CallOption) []any {
+	args := make([]any, len(opts))
+	for i, opt := range opts {
+		args[i] = opt
+	}
+	return args
+}
@@ -0,0 +1,444 @@
+package sqlq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/n-r-w/nerr"
+)
+
+// Parquet format enums, see parquet.thrift
+const (
+	parquetBoolean   = 0
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetFloat     = 4
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetConvertedUTF8            = 0
+	parquetConvertedDate            = 6
+	parquetConvertedTimestampMicros = 10
+	parquetConvertedInt16           = 16
+
+	parquetOptional      = 1
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+	parquetDataPage      = 0
+	parquetUncompressed  = 0
+)
+
+var parquetMagic = []byte("PAR1")
+
+// ErrParquetClosed - the batch is written into the closed ParquetWriter
+var ErrParquetClosed = errors.New("parquet writer is closed")
+
+// ParquetWriter - ColumnBatchWriter that writes the batches into a Parquet file, a row group per batch.
+// All columns are optional, the values are PLAIN encoded without compression. Close writes the footer of the file,
+// the file is not valid until then
+type ParquetWriter struct {
+	w         io.Writer
+	schema    []ColumnSchema
+	offset    int64
+	rows      int64
+	rowGroups []parquetRowGroup
+	closed    bool
+}
+
+type parquetRowGroup struct {
+	columns []parquetColumnChunk
+	size    int64
+	rows    int64
+}
+
+type parquetColumnChunk struct {
+	// offset of the data page in the file
+	offset int64
+	// size of the page with the header
+	size int64
+}
+
+// NewParquetWriter - create a Parquet writer of the columns, see ColumnSchemaOf
+func NewParquetWriter(w io.Writer, schema []ColumnSchema) *ParquetWriter {
+	return &ParquetWriter{w: w, schema: schema}
+}
+
+// WriteParquet - read the remaining rows of the select and write them into w as a Parquet file with row groups of
+// rowGroupSize rows. The query is closed
+func (q *Query) WriteParquet(w io.Writer, rowGroupSize int) error {
+	pw := NewParquetWriter(w, ColumnSchemaOf(q.Fields()))
+	if err := q.WriteColumnBatches(pw, rowGroupSize); err != nil {
+		return err
+	}
+	return pw.Close()
+}
+
+// WriteBatch - ColumnBatchWriter. Writes the batch as a row group
+func (p *ParquetWriter) WriteBatch(batch *ColumnBatch) error {
+	if p.closed {
+		return nerr.New(ErrParquetClosed)
+	}
+	if len(batch.Schema) != len(p.schema) {
+		return nerr.New(fmt.Errorf("batch has %d columns, schema has %d", len(batch.Schema), len(p.schema)))
+	}
+	if err := p.writeMagic(); err != nil {
+		return err
+	}
+
+	group := parquetRowGroup{rows: int64(batch.Rows)}
+	for i := range p.schema {
+		page, err := parquetPage(batch.Values[i], batch.Valid[i])
+		if err != nil {
+			return nerr.New(fmt.Errorf("column %s: %w", p.schema[i].Name, err))
+		}
+		header := parquetPageHeader(batch.Rows, len(page))
+
+		chunk := parquetColumnChunk{offset: p.offset, size: int64(len(header) + len(page))}
+		if err := p.write(header); err != nil {
+			return err
+		}
+		if err := p.write(page); err != nil {
+			return err
+		}
+
+		group.columns = append(group.columns, chunk)
+		group.size += chunk.size
+	}
+
+	p.rowGroups = append(p.rowGroups, group)
+	p.rows += group.rows
+	return nil
+}
+
+// Close - write the footer of the file. Doesn't close the underlying writer
+func (p *ParquetWriter) Close() error {
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+
+	if err := p.writeMagic(); err != nil {
+		return err
+	}
+
+	footer := p.fileMetaData()
+	if err := p.write(footer); err != nil {
+		return err
+	}
+	if err := p.write(appendUint32(nil, uint32(len(footer)))); err != nil {
+		return err
+	}
+	return p.write(parquetMagic)
+}
+
+func (p *ParquetWriter) writeMagic() error {
+	if p.offset > 0 {
+		return nil
+	}
+	return p.write(parquetMagic)
+}
+
+func (p *ParquetWriter) write(data []byte) error {
+	n, err := p.w.Write(data)
+	p.offset += int64(n)
+	return nerr.New(err)
+}
+
+// fileMetaData - FileMetaData of the file
+func (p *ParquetWriter) fileMetaData() []byte {
+	var w thriftWriter
+	w.structBegin()
+	w.i32(1, 1)
+
+	w.listBegin(2, thriftStruct, len(p.schema)+1)
+	w.structBegin()
+	w.binary(4, "schema")
+	w.i32(5, int32(len(p.schema)))
+	w.structEnd()
+	for _, c := range p.schema {
+		physical, converted := parquetType(c.Type)
+		w.structBegin()
+		w.i32(1, physical)
+		w.i32(3, parquetOptional)
+		w.binary(4, c.Name)
+		if converted >= 0 {
+			w.i32(6, converted)
+		}
+		w.structEnd()
+	}
+
+	w.i64(3, p.rows)
+
+	w.listBegin(4, thriftStruct, len(p.rowGroups))
+	for _, g := range p.rowGroups {
+		w.structBegin()
+		w.listBegin(1, thriftStruct, len(g.columns))
+		for i, chunk := range g.columns {
+			physical, _ := parquetType(p.schema[i].Type)
+
+			w.structBegin()
+			w.i64(2, chunk.offset)
+			w.structField(3)
+			w.i32(1, physical)
+			w.listBegin(2, thriftI32, 2)
+			w.listI32(parquetEncodingPlain)
+			w.listI32(parquetEncodingRLE)
+			w.listBegin(3, thriftBinary, 1)
+			w.listBinary(p.schema[i].Name)
+			w.i32(4, parquetUncompressed)
+			w.i64(5, g.rows)
+			w.i64(6, chunk.size)
+			w.i64(7, chunk.size)
+			w.i64(9, chunk.offset)
+			w.structEnd()
+			w.structEnd()
+		}
+		w.i64(2, g.size)
+		w.i64(3, g.rows)
+		w.structEnd()
+	}
+
+	w.binary(6, "sqlq")
+	w.structEnd()
+	return w.buf.Bytes()
+}
+
+// parquetType - physical and converted (-1 if none) Parquet types of the column type
+func parquetType(t ArrowType) (int32, int32) {
+	switch t {
+	case ArrowBool:
+		return parquetBoolean, -1
+	case ArrowInt16:
+		return parquetInt32, parquetConvertedInt16
+	case ArrowInt32:
+		return parquetInt32, -1
+	case ArrowInt64:
+		return parquetInt64, -1
+	case ArrowFloat32:
+		return parquetFloat, -1
+	case ArrowFloat64:
+		return parquetDouble, -1
+	case ArrowBinary:
+		return parquetByteArray, -1
+	case ArrowDate32:
+		return parquetInt32, parquetConvertedDate
+	case ArrowTimestamp:
+		return parquetInt64, parquetConvertedTimestampMicros
+	default:
+		return parquetByteArray, parquetConvertedUTF8
+	}
+}
+
+// parquetPageHeader - PageHeader of the data page
+func parquetPageHeader(rows int, size int) []byte {
+	var w thriftWriter
+	w.structBegin()
+	w.i32(1, parquetDataPage)
+	w.i32(2, int32(size))
+	w.i32(3, int32(size))
+	w.structField(5)
+	w.i32(1, int32(rows))
+	w.i32(2, parquetEncodingPlain)
+	w.i32(3, parquetEncodingRLE)
+	w.i32(4, parquetEncodingRLE)
+	w.structEnd()
+	w.structEnd()
+	return w.buf.Bytes()
+}
+
+// parquetPage - data page of the column: definition levels and PLAIN encoded not NULL values
+func parquetPage(values any, valid []bool) ([]byte, error) {
+	levels := parquetLevels(valid)
+	page := appendUint32(nil, uint32(len(levels)))
+	page = append(page, levels...)
+
+	switch col := values.(type) {
+	case []bool:
+		var bits []byte
+		n := 0
+		for i, v := range col {
+			if !valid[i] {
+				continue
+			}
+			if n%8 == 0 {
+				bits = append(bits, 0)
+			}
+			if v {
+				bits[n/8] |= 1 << (n % 8)
+			}
+			n++
+		}
+		page = append(page, bits...)
+	case []int16:
+		for i, v := range col {
+			if valid[i] {
+				page = appendUint32(page, uint32(int32(v)))
+			}
+		}
+	case []int32:
+		for i, v := range col {
+			if valid[i] {
+				page = appendUint32(page, uint32(v))
+			}
+		}
+	case []int64:
+		for i, v := range col {
+			if valid[i] {
+				page = appendUint64(page, uint64(v))
+			}
+		}
+	case []float32:
+		for i, v := range col {
+			if valid[i] {
+				page = appendUint32(page, math.Float32bits(v))
+			}
+		}
+	case []float64:
+		for i, v := range col {
+			if valid[i] {
+				page = appendUint64(page, math.Float64bits(v))
+			}
+		}
+	case [][]byte:
+		for i, v := range col {
+			if valid[i] {
+				page = appendUint32(page, uint32(len(v)))
+				page = append(page, v...)
+			}
+		}
+	case []string:
+		for i, v := range col {
+			if valid[i] {
+				page = appendUint32(page, uint32(len(v)))
+				page = append(page, v...)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported values %T", values)
+	}
+
+	return page, nil
+}
+
+// parquetLevels - definition levels of the optional column, RLE runs of bit width 1
+func parquetLevels(valid []bool) []byte {
+	var res []byte
+	for i := 0; i < len(valid); {
+		j := i + 1
+		for j < len(valid) && valid[j] == valid[i] {
+			j++
+		}
+
+		res = appendUvarint(res, uint64(j-i)<<1)
+		if valid[i] {
+			res = append(res, 1)
+		} else {
+			res = append(res, 0)
+		}
+		i = j
+	}
+	return res
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+// thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter - writer of the thrift compact protocol, enough for the Parquet metadata
+type thriftWriter struct {
+	buf bytes.Buffer
+	// id of the last field of each open struct
+	last []int16
+}
+
+func (w *thriftWriter) structBegin() {
+	w.last = append(w.last, 0)
+}
+
+func (w *thriftWriter) structEnd() {
+	w.buf.WriteByte(0)
+	w.last = w.last[:len(w.last)-1]
+}
+
+func (w *thriftWriter) field(id int16, typ byte) {
+	last := &w.last[len(w.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.varint(int64(id))
+	}
+	*last = id
+}
+
+func (w *thriftWriter) varint(v int64) {
+	var b [binary.MaxVarintLen64]byte
+	w.buf.Write(b[:binary.PutVarint(b[:], v)])
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.varint(int64(v))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.varint(v)
+}
+
+func (w *thriftWriter) binary(id int16, v string) {
+	w.field(id, thriftBinary)
+	w.listBinary(v)
+}
+
+// structField - begin the struct field, closed by structEnd
+func (w *thriftWriter) structField(id int16) {
+	w.field(id, thriftStruct)
+	w.structBegin()
+}
+
+// listBegin - begin the list field of n elements written by listI32, listBinary or structBegin/structEnd
+func (w *thriftWriter) listBegin(id int16, elem byte, n int) {
+	w.field(id, thriftList)
+	if n < 15 {
+		w.buf.WriteByte(byte(n)<<4 | elem)
+		return
+	}
+	w.buf.WriteByte(0xf0 | elem)
+	w.buf.Write(appendUvarint(nil, uint64(n)))
+}
+
+func (w *thriftWriter) listI32(v int32) {
+	w.varint(int64(v))
+}
+
+func (w *thriftWriter) listBinary(v string) {
+	w.buf.Write(appendUvarint(nil, uint64(len(v))))
+	w.buf.WriteString(v)
+}
@@ -0,0 +1,167 @@
+package sqlq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgtype"
+)
+
+// thriftReader - reader of the thrift compact protocol into field id -> value maps
+type thriftReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *thriftReader) byte() byte {
+	c := r.b[r.pos]
+	r.pos++
+	return c
+}
+
+func (r *thriftReader) varint() int64 {
+	v, n := binary.Varint(r.b[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) readStruct() map[int16]any {
+	res := map[int16]any{}
+	var last int16
+	for {
+		h := r.byte()
+		if h == 0 {
+			return res
+		}
+		id := last + int16(h>>4)
+		if h>>4 == 0 {
+			id = int16(r.varint())
+		}
+		last = id
+		res[id] = r.readValue(h & 0x0f)
+	}
+}
+
+func (r *thriftReader) readValue(typ byte) any {
+	switch typ {
+	case thriftI32, thriftI64:
+		return r.varint()
+	case thriftBinary:
+		n := int(r.uvarint())
+		s := string(r.b[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case thriftList:
+		h := r.byte()
+		n := int(h >> 4)
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		res := make([]any, n)
+		for i := range res {
+			res[i] = r.readValue(h & 0x0f)
+		}
+		return res
+	case thriftStruct:
+		return r.readStruct()
+	}
+	panic("unexpected thrift type")
+}
+
+func TestWriteParquet(t *testing.T) {
+	fields := []pgproto3.FieldDescription{
+		{Name: []byte("id"), DataTypeOID: pgtype.Int8OID},
+		{Name: []byte("name"), DataTypeOID: pgtype.TextOID},
+		{Name: []byte("flag"), DataTypeOID: pgtype.BoolOID},
+	}
+	schema := ColumnSchemaOf(fields)
+	batch := newColumnBatch(schema, 3)
+	for _, row := range [][]any{{int64(1), "a", true}, {nil, "b", nil}, {int64(3), nil, true}} {
+		for i, v := range row {
+			if err := batch.append(i, v, fields[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+		batch.Rows++
+	}
+
+	var buf bytes.Buffer
+	pw := NewParquetWriter(&buf, schema)
+	if err := pw.WriteBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.WriteBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	if !bytes.HasPrefix(data, parquetMagic) || !bytes.HasSuffix(data, parquetMagic) {
+		t.Fatal("no magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := (&thriftReader{b: data[len(data)-8-footerLen : len(data)-8]}).readStruct()
+
+	if rows := footer[3].(int64); rows != 6 {
+		t.Errorf("rows: got %d, want 6", rows)
+	}
+	var names []string
+	for _, e := range footer[2].([]any)[1:] {
+		names = append(names, e.(map[int16]any)[4].(string))
+	}
+	if want := []string{"id", "name", "flag"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("schema: got %v, want %v", names, want)
+	}
+
+	groups := footer[4].([]any)
+	if len(groups) != 2 {
+		t.Fatalf("row groups: got %d, want 2", len(groups))
+	}
+
+	// id column of the second row group: definition levels 1,0,1 and the values 1, 3
+	meta := groups[1].(map[int16]any)[1].([]any)[0].(map[int16]any)[3].(map[int16]any)
+	r := &thriftReader{b: data, pos: int(meta[9].(int64))}
+	header := r.readStruct()
+	if n := header[5].(map[int16]any)[1].(int64); n != 3 {
+		t.Errorf("page values: got %d, want 3", n)
+	}
+	page := data[r.pos : r.pos+int(header[3].(int64))]
+	levelsLen := int(binary.LittleEndian.Uint32(page))
+	if levels := page[4 : 4+levelsLen]; !bytes.Equal(levels, []byte{2, 1, 2, 0, 2, 1}) {
+		t.Errorf("levels: got %v", levels)
+	}
+	values := page[4+levelsLen:]
+	if len(values) != 16 || binary.LittleEndian.Uint64(values) != 1 || binary.LittleEndian.Uint64(values[8:]) != 3 {
+		t.Errorf("values: got %v", values)
+	}
+}
+
+func TestQueryWriteParquet(t *testing.T) {
+	q := FromPgxRows(newCachedRows(scanUserRows(5)))
+
+	var buf bytes.Buffer
+	if err := q.WriteParquet(&buf, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := (&thriftReader{b: data[len(data)-8-footerLen : len(data)-8]}).readStruct()
+	if rows := footer[3].(int64); rows != 5 {
+		t.Errorf("rows: got %d, want 5", rows)
+	}
+	if groups := footer[4].([]any); len(groups) != 3 {
+		t.Errorf("row groups: got %d, want 3", len(groups))
+	}
+}
@@ -0,0 +1,97 @@
+package sqlq
+
+import (
+	"errors"
+
+	"github.com/jackc/pgconn"
+)
+
+// SQLSTATE codes of the errors classified by the package
+const (
+	SqlStateUniqueViolation      = "23505"
+	SqlStateForeignKeyViolation  = "23503"
+	SqlStateCheckViolation       = "23514"
+	SqlStateNotNullViolation     = "23502"
+	SqlStateSerializationFailure = "40001"
+	SqlStateDeadlockDetected     = "40P01"
+	SqlStateUndefinedObject      = "42704"
+	SqlStateQueryCanceled        = "57014"
+)
+
+// AsPgError - postgres error from the error chain. The fields ConstraintName, TableName, ColumnName, SchemaName
+// identify the object of the error
+func AsPgError(err error) (*pgconn.PgError, bool) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr, true
+	}
+	return nil, false
+}
+
+// SqlState - SQLSTATE code of the postgres error, empty for nil and other errors
+func SqlState(err error) string {
+	if pgErr, ok := AsPgError(err); ok {
+		return pgErr.Code
+	}
+	return ""
+}
+
+// IsUniqueViolation - duplicate key of a unique index or constraint
+func IsUniqueViolation(err error) bool {
+	return SqlState(err) == SqlStateUniqueViolation
+}
+
+// IsForeignKeyViolation - the referenced row doesn't exist or the row is still referenced
+func IsForeignKeyViolation(err error) bool {
+	return SqlState(err) == SqlStateForeignKeyViolation
+}
+
+// IsCheckViolation - violation of a check constraint
+func IsCheckViolation(err error) bool {
+	return SqlState(err) == SqlStateCheckViolation
+}
+
+// IsNotNullViolation - null value in a not null column
+func IsNotNullViolation(err error) bool {
+	return SqlState(err) == SqlStateNotNullViolation
+}
+
+// IsSerializationFailure - concurrent update in the repeatable read or serializable transaction,
+// the transaction can be executed again
+func IsSerializationFailure(err error) bool {
+	return SqlState(err) == SqlStateSerializationFailure
+}
+
+// IsDeadlock - the transaction was aborted to resolve a deadlock, it can be executed again
+func IsDeadlock(err error) bool {
+	return SqlState(err) == SqlStateDeadlockDetected
+}
+
+// IsQueryCanceled - the statement was canceled by statement_timeout or by the cancel request
+func IsQueryCanceled(err error) bool {
+	return SqlState(err) == SqlStateQueryCanceled
+}
+
+// ConstraintName - name of the violated constraint, empty if the error is not a constraint violation
+func ConstraintName(err error) string {
+	if pgErr, ok := AsPgError(err); ok {
+		return pgErr.ConstraintName
+	}
+	return ""
+}
+
+// TableName - table of the postgres error, empty if unknown
+func TableName(err error) string {
+	if pgErr, ok := AsPgError(err); ok {
+		return pgErr.TableName
+	}
+	return ""
+}
+
+// ColumnName - column of the postgres error, empty if unknown
+func ColumnName(err error) string {
+	if pgErr, ok := AsPgError(err); ok {
+		return pgErr.ColumnName
+	}
+	return ""
+}
@@ -0,0 +1,111 @@
+package sqlq
+
+import (
+	"fmt"
+
+	"github.com/n-r-w/nerr"
+)
+
+// maximum number of flushed batches waiting to be sent, Flush blocks when it is reached
+const pipelineDepth = 16
+
+// Pipeline - statements queued and sent in batches in the background: the caller keeps queuing the next statements
+// while the server executes the flushed ones, the results are collected at the end.
+// The batches are sent one after another in the order of Flush calls, see Query.SendBatch for the semantics of each batch.
+// pgx v4 has no protocol level pipeline mode, so each batch is one round trip
+type Pipeline struct {
+	q *Query
+
+	queued *Batch
+	// index of the first statement of the queued batch
+	offset int
+
+	batches chan *Batch
+	done    chan struct{}
+	results []BatchResult
+	err     error
+}
+
+// Pipeline - create a pipeline that executes the statements with the query.
+// The query must not be used until Pipeline.Results returns
+func (q *Query) Pipeline() *Pipeline {
+	return &Pipeline{
+		q:      q,
+		queued: NewBatch(),
+	}
+}
+
+// Exec - queue the command with $1, $2... parameters. Returns the index of the result
+func (p *Pipeline) Exec(sql string, args ...any) int {
+	return p.offset + p.queued.Exec(sql, args...)
+}
+
+// ExecBind - queue the command with the substitution of values in the template. Returns the index of the result
+func (p *Pipeline) ExecBind(sqlTemplate string, values map[string]any, key string) int {
+	return p.offset + p.queued.ExecBind(sqlTemplate, values, key)
+}
+
+// Select - queue the select with $1, $2... parameters. Returns the index of the result
+func (p *Pipeline) Select(sql string, args ...any) int {
+	return p.offset + p.queued.Select(sql, args...)
+}
+
+// SelectBind - queue the select with the substitution of values in the template. Returns the index of the result
+func (p *Pipeline) SelectBind(sqlTemplate string, values map[string]any, key string) int {
+	return p.offset + p.queued.SelectBind(sqlTemplate, values, key)
+}
+
+// Flush - send the queued statements in the background without waiting for the result
+func (p *Pipeline) Flush() {
+	if p.queued.Len() == 0 {
+		return
+	}
+
+	if p.batches == nil {
+		p.batches = make(chan *Batch, pipelineDepth)
+		p.done = make(chan struct{})
+		go p.send()
+	}
+
+	p.batches <- p.queued
+	p.offset += p.queued.Len()
+	p.queued = NewBatch()
+}
+
+// send - execute the flushed batches in order
+func (p *Pipeline) send() {
+	defer close(p.done)
+
+	for b := range p.batches {
+		offset := len(p.results)
+		res, err := p.q.SendBatch(b)
+		p.results = append(p.results, res...)
+		if err != nil && p.err == nil {
+			p.err = pipelineError(err, res, offset)
+		}
+	}
+}
+
+// pipelineError - error of the batch with the index of the failed statement in the pipeline
+func pipelineError(err error, res []BatchResult, offset int) error {
+	for i, r := range res {
+		if r.Err != nil {
+			return nerr.New(fmt.Errorf("statement %d: %w", offset+i, r.Err))
+		}
+	}
+	return err
+}
+
+// Results - flush the queued statements and wait for all results. Returns the result of each statement
+// in the order of queuing and the first error. The pipeline can't be used after that
+func (p *Pipeline) Results() ([]BatchResult, error) {
+	p.Flush()
+
+	if p.batches != nil {
+		close(p.batches)
+		<-p.done
+		p.batches = nil
+	}
+
+	return p.results, p.err
+}
@@ -0,0 +1,71 @@
+package sqlq
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+func TestPipeline(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect("UPDATE t SET a = 1", sqlqtest.Result{Tag: "UPDATE 1"})
+	srv.Expect("UPDATE t SET b = 1", sqlqtest.Result{Tag: "UPDATE 2"})
+	srv.ExpectError("UPDATE t SET c = 1", "23505", "duplicate key value")
+	srv.Expect("SELECT 1 AS a", sqlqtest.Result{Columns: []sqlqtest.Column{{Name: "a"}}, Rows: [][]any{{1}}})
+
+	p := NewQuery(pool, context.Background()).Pipeline()
+	a := p.Exec("UPDATE t SET a = 1")
+	p.Flush()
+	b := p.Exec("UPDATE t SET b = 1")
+	c := p.Exec("UPDATE t SET c = 1")
+	p.Flush()
+	// empty flush is ignored
+	p.Flush()
+	sel := p.Select("SELECT 1 AS a")
+
+	results, err := p.Results()
+	if SqlState(err) != "23505" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// the first failed statement of the pipeline, not of its batch: rolled back with the implicit transaction
+	if !strings.HasPrefix(err.Error(), "statement 1:") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if len(results) != 4 {
+		t.Fatalf("%d results, want 4", len(results))
+	}
+	if results[a].Err != nil || results[a].RowsAffected != 1 {
+		t.Errorf("statement %d: %+v", a, results[a])
+	}
+	// the batch of the failed statement is rolled back, the next batch is executed
+	if results[b].Err == nil || SqlState(results[c].Err) != "23505" {
+		t.Errorf("statements %d, %d: %v, %v", b, c, results[b].Err, results[c].Err)
+	}
+	if results[sel].Err != nil || !results[sel].Rows.Next() {
+		t.Errorf("statement %d: %+v", sel, results[sel])
+	}
+
+	var got []string
+	for _, sql := range srv.Queries() {
+		if !strings.HasPrefix(sql, "UPDATE") && !strings.HasPrefix(sql, "SELECT") {
+			continue
+		}
+		got = append(got, sql)
+	}
+	want := []string{"UPDATE t SET a = 1", "UPDATE t SET b = 1", "UPDATE t SET c = 1", "SELECT 1 AS a"}
+	if strings.Join(got, "; ") != strings.Join(want, "; ") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestPipelineEmpty(t *testing.T) {
+	_, pool := newTestPool(t)
+
+	results, err := NewQuery(pool, context.Background()).Pipeline().Results()
+	if err != nil || len(results) != 0 {
+		t.Errorf("results %v, error %v", results, err)
+	}
+}
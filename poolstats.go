@@ -0,0 +1,252 @@
+package sqlq
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const (
+	// number of the latest acquire durations used for the percentiles
+	poolStatsWindow = 1024
+	// default sampling interval of PoolMonitor
+	defaultPoolStatsInterval = time.Second
+)
+
+// PoolStats - connection pool statistics snapshot
+type PoolStats struct {
+	// Time - snapshot time
+	Time time.Time
+
+	AcquiredConns int32
+	IdleConns     int32
+	TotalConns    int32
+	MaxConns      int32
+
+	AcquireCount         int64
+	EmptyAcquireCount    int64
+	CanceledAcquireCount int64
+
+	// AcquireRate - acquires per second during the last sampling interval
+	AcquireRate float64
+	// AcquireDurationP50, P95, P99 - percentiles of the latest durations of the connection acquires made by sqlq
+	// for the pool: the statements outside of transactions and the starts of transactions
+	AcquireDurationP50 time.Duration
+	AcquireDurationP95 time.Duration
+	AcquireDurationP99 time.Duration
+}
+
+// PoolMonitor - periodically samples pgxpool statistics and computes rates. While the monitor is active,
+// sqlq acquires the connections of the pool explicitly and records the duration of each acquire for the percentiles
+type PoolMonitor struct {
+	pool     *pgxpool.Pool
+	interval time.Duration
+
+	mu    sync.RWMutex
+	stats PoolStats
+	// ring buffer of the latest acquire durations
+	durations []time.Duration
+	next      int
+
+	prevCount int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+var (
+	poolMonitorsMu sync.RWMutex
+	poolMonitors   = map[*pgxpool.Pool]*PoolMonitor{}
+)
+
+// NewPoolMonitor - create a pool monitor and start sampling with the specified interval (1s if interval <= 0).
+// The statistics are available with PoolMonitor.Stats or PoolStatsOf. Call Close to stop
+func NewPoolMonitor(pool *pgxpool.Pool, interval time.Duration) *PoolMonitor {
+	if interval <= 0 {
+		interval = defaultPoolStatsInterval
+	}
+
+	m := &PoolMonitor{
+		pool:     pool,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	m.sample(0)
+
+	poolMonitorsMu.Lock()
+	poolMonitors[pool] = m
+	poolMonitorsMu.Unlock()
+
+	go m.run()
+
+	return m
+}
+
+// PoolStatsOf - the latest statistics of the pool, false if the pool has no active PoolMonitor
+func PoolStatsOf(pool *pgxpool.Pool) (PoolStats, bool) {
+	m := poolMonitor(pool)
+	if m == nil {
+		return PoolStats{}, false
+	}
+	return m.Stats(), true
+}
+
+// poolMonitor - active monitor of the pool, nil if none
+func poolMonitor(pool *pgxpool.Pool) *PoolMonitor {
+	poolMonitorsMu.RLock()
+	defer poolMonitorsMu.RUnlock()
+	return poolMonitors[pool]
+}
+
+// recordAcquire - add the duration of the connection acquire
+func (m *PoolMonitor) recordAcquire(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.durations) < poolStatsWindow {
+		m.durations = append(m.durations, d)
+	} else {
+		m.durations[m.next] = d
+		m.next = (m.next + 1) % poolStatsWindow
+	}
+}
+
+// Stats - the latest statistics snapshot
+func (m *PoolMonitor) Stats() PoolStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.stats
+}
+
+// Close - stop sampling
+func (m *PoolMonitor) Close() {
+	poolMonitorsMu.Lock()
+	if poolMonitors[m.pool] == m {
+		delete(poolMonitors, m.pool)
+	}
+	poolMonitorsMu.Unlock()
+
+	close(m.stop)
+	<-m.done
+}
+
+func (m *PoolMonitor) run() {
+	defer close(m.done)
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.sample(m.interval)
+		}
+	}
+}
+
+func (m *PoolMonitor) sample(elapsed time.Duration) {
+	st := m.pool.Stat()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := st.AcquireCount() - m.prevCount
+	m.prevCount = st.AcquireCount()
+
+	m.stats = PoolStats{
+		Time:                 time.Now(),
+		AcquiredConns:        st.AcquiredConns(),
+		IdleConns:            st.IdleConns(),
+		TotalConns:           st.TotalConns(),
+		MaxConns:             st.MaxConns(),
+		AcquireCount:         st.AcquireCount(),
+		EmptyAcquireCount:    st.EmptyAcquireCount(),
+		CanceledAcquireCount: st.CanceledAcquireCount(),
+	}
+	if elapsed > 0 {
+		m.stats.AcquireRate = float64(count) / elapsed.Seconds()
+	}
+
+	sorted := append([]time.Duration{}, m.durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	m.stats.AcquireDurationP50 = percentile(sorted, 0.5)
+	m.stats.AcquireDurationP95 = percentile(sorted, 0.95)
+	m.stats.AcquireDurationP99 = percentile(sorted, 0.99)
+}
+
+// percentile - value of the percentile p (0-1) in the sorted slice
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	i := int(float64(len(sorted)-1) * p)
+	return sorted[i]
+}
+
+// acquirePoolConn - acquire the connection of the pool, the duration is recorded by the monitor of the pool
+func acquirePoolConn(pool *pgxpool.Pool, ctx context.Context) (*pgxpool.Conn, error) {
+	m := poolMonitor(pool)
+	if m == nil {
+		return pool.Acquire(ctx)
+	}
+
+	start := time.Now()
+	c, err := pool.Acquire(ctx)
+	if err == nil {
+		m.recordAcquire(time.Since(start))
+	}
+	return c, err
+}
+
+// beginPoolTx - pgxpool.Pool.BeginTx with the acquire recorded by the monitor of the pool
+func beginPoolTx(pool *pgxpool.Pool, ctx context.Context, opts pgx.TxOptions) (pgx.Tx, error) {
+	if poolMonitor(pool) == nil {
+		return pool.BeginTx(ctx, opts)
+	}
+
+	c, err := acquirePoolConn(pool, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := c.BeginTx(ctx, opts)
+	if err != nil {
+		c.Release()
+		return nil, err
+	}
+	return &pooledTx{Tx: tx, c: c}, nil
+}
+
+// pooledTx - transaction that releases the connection to the pool when it completes, as pgxpool.Tx
+type pooledTx struct {
+	pgx.Tx
+	c *pgxpool.Conn
+}
+
+func (tx *pooledTx) Commit(ctx context.Context) error {
+	err := tx.Tx.Commit(ctx)
+	tx.release()
+	return err
+}
+
+func (tx *pooledTx) Rollback(ctx context.Context) error {
+	err := tx.Tx.Rollback(ctx)
+	tx.release()
+	return err
+}
+
+func (tx *pooledTx) release() {
+	if tx.c != nil {
+		tx.c.Release()
+		tx.c = nil
+	}
+}
@@ -0,0 +1,56 @@
+package sqlq
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+func TestPoolMonitorRecordsAcquires(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect("INSERT INTO t (a) VALUES (1)", sqlqtest.Result{Tag: "INSERT 0 1"})
+
+	// default interval instead of a panic
+	NewPoolMonitor(pool, 0).Close()
+
+	m := NewPoolMonitor(pool, 10*time.Millisecond)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if _, err := Exec(pool, ctx, "INSERT INTO t (a) VALUES (1)"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := RunInTx(pool, ctx, func(tx *Tx) error {
+		_, err := ExecTx(tx, "INSERT INTO t (a) VALUES (1)")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pool.Stat().AcquiredConns() != 0 {
+		t.Fatal("connection of the transaction is not released")
+	}
+
+	m.mu.RLock()
+	recorded := len(m.durations)
+	m.mu.RUnlock()
+	if recorded != 4 {
+		t.Errorf("expected 4 recorded acquires, got %d", recorded)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	st, ok := PoolStatsOf(pool)
+	if !ok {
+		t.Fatal("no statistics of the monitored pool")
+	}
+	if st.AcquireDurationP99 <= 0 || st.AcquireDurationP50 > st.AcquireDurationP99 {
+		t.Errorf("unexpected percentiles: %+v", st)
+	}
+
+	m.Close()
+	if _, ok := PoolStatsOf(pool); ok {
+		t.Error("statistics of the closed monitor")
+	}
+}
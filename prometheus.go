@@ -0,0 +1,98 @@
+package sqlq
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PrometheusMetrics - StatementMetrics exposed as a prometheus.Collector:
+//   - <namespace>_statement_duration_seconds - histogram of the statement latency
+//   - <namespace>_statement_errors_total - counter of the failed statements by SQLSTATE,
+//     the code is empty for the errors that didn't come from the server (network, context)
+//   - <namespace>_statements_in_flight - gauge of the executing statements
+//   - <namespace>_pool_acquire_wait_seconds - histogram of waiting for a pool connection
+//
+// Register it and attach with SetStatementMetrics, WithStatementMetrics, Tx.SetStatementMetrics or Query.SetStatementMetrics
+type PrometheusMetrics struct {
+	duration    prometheus.Histogram
+	errors      *prometheus.CounterVec
+	inFlight    prometheus.Gauge
+	acquireWait prometheus.Histogram
+}
+
+// NewPrometheusMetrics - create the collector with the metric name prefix, e.g. "sqlq". buckets - buckets of
+// the latency histograms, prometheus.DefBuckets if empty
+func NewPrometheusMetrics(namespace string, buckets ...float64) *PrometheusMetrics {
+	if len(buckets) == 0 {
+		buckets = prometheus.DefBuckets
+	}
+
+	return &PrometheusMetrics{
+		duration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "statement_duration_seconds",
+			Help:      "Duration of the statements, for selects until the rows are closed.",
+			Buckets:   buckets,
+		}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "statement_errors_total",
+			Help:      "Number of the failed statements by SQLSTATE.",
+		}, []string{"sqlstate"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "statements_in_flight",
+			Help:      "Number of the executing statements.",
+		}),
+		acquireWait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "pool_acquire_wait_seconds",
+			Help:      "Time of waiting for a pool connection.",
+			Buckets:   buckets,
+		}),
+	}
+}
+
+// Describe - prometheus.Collector
+func (m *PrometheusMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.duration.Describe(ch)
+	m.errors.Describe(ch)
+	m.inFlight.Describe(ch)
+	m.acquireWait.Describe(ch)
+}
+
+// Collect - prometheus.Collector
+func (m *PrometheusMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.duration.Collect(ch)
+	m.errors.Collect(ch)
+	m.inFlight.Collect(ch)
+	m.acquireWait.Collect(ch)
+}
+
+// StatementStarted - StatementMetrics
+func (m *PrometheusMetrics) StatementStarted() {
+	m.inFlight.Inc()
+}
+
+// StatementFinished - StatementMetrics
+func (m *PrometheusMetrics) StatementFinished(duration time.Duration, err error) {
+	m.inFlight.Dec()
+	m.duration.Observe(duration.Seconds())
+
+	if err != nil {
+		code := ""
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			code = pgErr.Code
+		}
+		m.errors.WithLabelValues(code).Inc()
+	}
+}
+
+// ConnAcquired - StatementMetrics
+func (m *PrometheusMetrics) ConnAcquired(wait time.Duration) {
+	m.acquireWait.Observe(wait.Seconds())
+}
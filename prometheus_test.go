@@ -0,0 +1,88 @@
+package sqlq
+
+import (
+	"context"
+	"testing"
+
+	"github.com/n-r-w/sqlq/sqlqtest"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func gatherMetrics(t *testing.T, m *PrometheusMetrics) map[string]*dto.MetricFamily {
+	t.Helper()
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(m)
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := map[string]*dto.MetricFamily{}
+	for _, f := range families {
+		res[f.GetName()] = f
+	}
+	return res
+}
+
+func TestPrometheusMetrics(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect("SELECT 1 AS v", sqlqtest.Result{Columns: []sqlqtest.Column{{Name: "v"}}, Rows: [][]any{{int32(1)}}})
+	srv.ExpectError("SELECT * FROM missing", "42P01", `relation "missing" does not exist`)
+
+	m := NewPrometheusMetrics("test")
+	// attached to the query by the context it is created with
+	q := NewQuery(pool, WithStatementMetrics(context.Background(), m))
+	defer q.Release()
+
+	if err := q.Select("SELECT 1 AS v"); err != nil {
+		t.Fatal(err)
+	}
+	if got := gatherMetrics(t, m)["test_statements_in_flight"].Metric[0].GetGauge().GetValue(); got != 1 {
+		t.Errorf("in flight while the rows are open: got %v, want 1", got)
+	}
+	for q.Next() {
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Select("SELECT * FROM missing"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	families := gatherMetrics(t, m)
+	if got := families["test_statements_in_flight"].Metric[0].GetGauge().GetValue(); got != 0 {
+		t.Errorf("in flight: got %v, want 0", got)
+	}
+	if got := families["test_statement_duration_seconds"].Metric[0].GetHistogram().GetSampleCount(); got != 2 {
+		t.Errorf("duration samples: got %d, want 2", got)
+	}
+	if got := families["test_pool_acquire_wait_seconds"].Metric[0].GetHistogram().GetSampleCount(); got != 2 {
+		t.Errorf("acquire samples: got %d, want 2", got)
+	}
+
+	errs := families["test_statement_errors_total"].Metric
+	if len(errs) != 1 || errs[0].GetLabel()[0].GetValue() != "42P01" || errs[0].GetCounter().GetValue() != 1 {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
+
+func TestStatementMetricsPrecedence(t *testing.T) {
+	_, pool := newTestPool(t)
+	ctxMetrics := NewPrometheusMetrics("ctx")
+	txMetrics := NewPrometheusMetrics("tx")
+
+	tx := NewTx(pool, WithStatementMetrics(context.Background(), ctxMetrics))
+	if got := NewQueryTx(tx, context.Background()).statementMetrics(); got != ctxMetrics {
+		t.Error("context of the transaction is not used")
+	}
+
+	tx.SetStatementMetrics(txMetrics)
+	if got := NewQueryTx(tx, context.Background()).statementMetrics(); got != txMetrics {
+		t.Error("receiver of the transaction is not used")
+	}
+	if got := NewQueryTx(tx, WithStatementMetrics(context.Background(), ctxMetrics)).statementMetrics(); got != ctxMetrics {
+		t.Error("context of the query is not used")
+	}
+}
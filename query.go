@@ -2,9 +2,10 @@ package sqlq
 
 import (
 	"context"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
@@ -15,7 +16,6 @@ import (
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/n-r-w/nerr"
-	"github.com/n-r-w/sqlb"
 )
 
 // Query - wrapper for pgx Query/Exec
@@ -30,6 +30,37 @@ type Query struct {
 
 	lastValues       []any
 	lastDescriptions []pgproto3.FieldDescription
+
+	// decoded values of the current row of rowValuesOf, reset by Next
+	rowValues   []any
+	rowValuesOf pgx.Rows
+	// Next is positioned on a row
+	onRow bool
+	// pooled buffer of rowValues, see decodeValues
+	valuesBuf *[]any
+	// number of rows read by Next
+	rowsRead int64
+	// limit of rowsRead, see SetMaxRows
+	maxRows int64
+	// error of Next returned by Close
+	rowsErr error
+
+	deadlineTimeout bool
+	mapper          FieldMapper
+	strictMapping   bool
+	mws             []Middleware
+	localSettings   map[string]string
+	stmtCache       *StatementCache
+	binder          Binder
+	metadata        map[string]any
+	queryLogger     Logger
+	stmtMetrics     StatementMetrics
+	protocol        Protocol
+	// type information of the fields captured from the connection of Select, see connInfo
+	typeInfo *pgtype.ConnInfo
+	// statements of the audit sink are not audited
+	noAudit  bool
+	onNotice NoticeHandler
 }
 
 // NewQuery - create a Query based on *sqlq.Tx
@@ -39,7 +70,7 @@ func NewQuery(pool *pgxpool.Pool, context context.Context) *Query {
 		ctx:    context,
 		rows:   nil,
 		tag:    []byte{},
-		fields: map[string]int{},
+		fields: nil,
 	}
 }
 
@@ -51,8 +82,25 @@ func NewQueryTx(tx *Tx, context context.Context) *Query {
 		ctx:    context,
 		rows:   nil,
 		tag:    []byte{},
-		fields: map[string]int{},
+		fields: nil,
+	}
+}
+
+// FromPgxRows - create a Query for reading the rows of a query executed directly with pgx.
+// Only the row reading methods can be used, the Query has no pool to execute statements
+func FromPgxRows(rows pgx.Rows) *Query {
+	q := &Query{
+		ctx: context.Background(),
+		tag: []byte{},
 	}
+	q.setRows(rows)
+	return q
+}
+
+// PgxRows - the underlying pgx rows of the active selection, nil if there is none. Reading the rows
+// directly advances the Query as well, but the row values are not refreshed until the Query Next is called
+func (q *Query) PgxRows() pgx.Rows {
+	return q.rows
 }
 
 // Context - active context
@@ -70,26 +118,99 @@ func (t *Query) Pool() *pgxpool.Pool {
 	return t.pool
 }
 
+// SetDeadlineTimeout - if the context has a deadline, set a matching statement_timeout for each statement,
+// so the server stops the work when the client is no longer waiting. Inside a transaction SET LOCAL is used,
+// so the timeout stays in effect until the end of the transaction
+func (q *Query) SetDeadlineTimeout(enabled bool) {
+	q.deadlineTimeout = enabled
+}
+
+// SetFieldMapper - column name resolution for ScanStruct. nil - the package default is used, see SetDefaultFieldMapper
+func (q *Query) SetFieldMapper(mapper FieldMapper) {
+	q.mapper = mapper
+}
+
+// SetStrictMapping - ScanStruct returns an error if a selected column has no field or a field has no column.
+// Useful in tests to catch mismatches between sql and models
+func (q *Query) SetStrictMapping(strict bool) {
+	q.strictMapping = strict
+}
+
+// ErrMaxRowsExceeded - the select returned more rows than the limit of SetMaxRows
+var ErrMaxRowsExceeded = errors.New("max rows exceeded")
+
+// SetMaxRows - Next stops and Close returns ErrMaxRowsExceeded if the select returns more than n rows.
+// Protects from unbounded selects. 0 - no limit
+func (q *Query) SetMaxRows(n int64) {
+	q.maxRows = n
+}
+
+// FieldMapper - column name resolution for ScanStruct
+func (q *Query) FieldMapper() FieldMapper {
+	if q.mapper != nil {
+		return q.mapper
+	}
+	return defaultFieldMapper
+}
+
+// SetBinder - substitution of values into templates for the Bind methods. nil - the package default is used, see SetDefaultBinder
+func (q *Query) SetBinder(binder Binder) {
+	q.binder = binder
+}
+
+// Binder - substitution of values into templates for the Bind methods
+func (q *Query) Binder() Binder {
+	if q.binder != nil {
+		return q.binder
+	}
+	return defaultBinder
+}
+
+// WithLocalSettings - settings (work_mem, planner options etc.) applied with SET LOCAL before each statement of the query.
+// The query must be executed inside a transaction, so that the settings don't affect the session
+func (q *Query) WithLocalSettings(settings map[string]string) *Query {
+	q.localSettings = settings
+	return q
+}
+
+// SetStatementCache - execute parameterized statements as prepared statements from the cache.
+// nil - the transaction cache is used, if any
+func (q *Query) SetStatementCache(cache *StatementCache) {
+	q.stmtCache = cache
+}
+
 // Close - close the selection. Use for Select in case we don't get to the end of Next
 func (q *Query) Close() error {
 	if q.rows != nil {
-		q.lastValues, _ = q.rows.Values()
+		// pgx rows have no current row before Next
+		if q.rowsErr == nil && q.rowsRead > 0 {
+			q.lastValues, _ = q.Values()
+		}
 		q.lastDescriptions = q.rows.FieldDescriptions()
 
 		// ошибка в rows появляется только после закрытия (это не баг, а фича)
 		q.rows.Close()
+		q.onRow = false
 		err := q.rows.Err()
+		q.tag = q.rows.CommandTag()
 		q.rows = nil
+		if q.rowsErr != nil {
+			return q.rowsErr
+		}
 		return err
 	}
 	return nil
 }
 
-// RowsAffected -  the number of processed rows, for Select closes the query (Next will not work)
+// RowsAffected - the number of processed rows. For the active Select - the number of rows read by Next so far,
+// it doesn't close the selection. After Close - the number of rows of the whole Select
 func (q *Query) RowsAffected() int64 {
 	if q.rows != nil {
-		q.rows.Close()
-		return q.rows.CommandTag().RowsAffected()
+		if tag := q.rows.CommandTag(); len(tag) > 0 {
+			// read to the end
+			return tag.RowsAffected()
+		}
+		return q.rowsRead
 	}
 	if len(q.tag) > 0 {
 		return q.tag.RowsAffected()
@@ -108,65 +229,137 @@ func (q *Query) IsCommand() bool {
 }
 
 // Exec - executing the insert, update, delete command
-func (q *Query) Exec(sql string) error {
+func (q *Query) Exec(sql string, opts ...CallOption) error {
+	return q.ExecArgs(sql, callOptionArgs(opts)...)
+}
+
+// ExecArgs - executing the insert, update, delete command with $1, $2... parameters.
+// Parameters are passed separately from the sql text using the extended protocol. CallOption values among the
+// parameters are options of the call, Expr values are expressions with their own parameters
+func (q *Query) ExecArgs(sql string, args ...any) error {
 	q.rows = nil
 	q.lastValues = nil
 	q.lastDescriptions = nil
-	q.fields = make(map[string]int)
+	q.resetFields()
 
-	var err error
-	if q.tx != nil {
-		q.tag, err = q.tx.tx.Exec(q.ctx, sql, pgx.QuerySimpleProtocol(true))
-	} else {
-		q.tag, err = q.pool.Exec(q.ctx, sql, pgx.QuerySimpleProtocol(true))
+	if cache := requestCacheFromContext(q.ctx); cache != nil {
+		cache.clear()
+	}
+
+	args, ctx, cancel := q.splitCallOptions(args)
+	if cancel != nil {
+		defer cancel()
+	}
+
+	sql, args, err := exprArgs(sql, args)
+	if err != nil {
+		return nerr.New(newQueryError("exec", sql, len(args), err))
 	}
 
-	return nerr.New(err)
+	q.tag, err = q.execSql(ctx, sql, args...)
+	if err != nil {
+		return nerr.New(newQueryError("exec", sql, len(args), err))
+	}
+
+	return q.audit(ctx, sql, q.tag)
 }
 
 // ExecBind - execution of the insert, update, delete command with the substitution of values in the template
 func (q *Query) ExecBind(sqlTemplate string, values map[string]any, key string) error {
-	binder := sqlb.NewBinder(sqlTemplate, key)
-	if err := binder.BindValues(values); err != nil {
+	if sql, args, err := bindSql(q.Binder(), sqlTemplate, values, key); err != nil {
 		return err
+	} else {
+		return withBindParams(q.ExecArgs(sql, args...), key, values)
 	}
+}
 
-	if sql, err := binder.Sql(); err != nil {
+// ExecBindStruct - execution of the insert, update, delete command with the substitution of the struct field values in the template.
+// Placeholder names are resolved by the FieldMapper of the query, as in ScanStruct
+func (q *Query) ExecBindStruct(sqlTemplate string, src any, key string) error {
+	values, err := structValues(src, q.FieldMapper())
+	if err != nil {
+		return nerr.New(err)
+	}
+
+	return q.ExecBind(sqlTemplate, values, key)
+}
+
+// ExecBindKeys - execution of the insert, update, delete command with the substitution of several placeholder groups,
+// each with its own key: map[string]map[string]any{":f:": filters, ":p:": paging}
+func (q *Query) ExecBindKeys(sqlTemplate string, values map[string]map[string]any) error {
+	if sql, args, err := bindSqlKeys(q.Binder(), sqlTemplate, values); err != nil {
 		return err
 	} else {
-		return q.Exec(sql)
+		return withBindKeysParams(q.ExecArgs(sql, args...), values)
 	}
 }
 
 // Select - executing the select command
-func (q *Query) Select(sql string) error {
+func (q *Query) Select(sql string, opts ...CallOption) error {
+	return q.SelectArgs(sql, callOptionArgs(opts)...)
+}
+
+// SelectArgs - executing the select command with $1, $2... parameters.
+// Parameters are passed separately from the sql text using the extended protocol. CallOption values among the
+// parameters are options of the call, Expr values are expressions with their own parameters
+func (q *Query) SelectArgs(sql string, args ...any) error {
 	q.tag = []byte{}
-	q.fields = map[string]int{}
+	q.resetFields()
 	q.lastValues = nil
 	q.lastDescriptions = nil
+	q.typeInfo = nil
 
-	var err error
-	if q.tx != nil {
-		q.rows, err = q.tx.tx.Query(q.ctx, sql, pgx.QuerySimpleProtocol(true))
-	} else {
-		q.rows, err = q.pool.Query(q.ctx, sql, pgx.QuerySimpleProtocol(true))
+	args, ctx, cancel := q.splitCallOptions(args)
+
+	sql, args, err := exprArgs(sql, args)
+	if err == nil {
+		if cache := requestCacheFromContext(q.ctx); cache != nil && q.tx == nil {
+			q.rows, err = cache.query(q, ctx, sql, args...)
+		} else {
+			q.rows, err = q.querySql(ctx, sql, args...)
+		}
 	}
 
 	if err != nil {
+		if cancel != nil {
+			cancel()
+		}
 		q.rows = nil
-		return nerr.New(err)
+		return nerr.New(newQueryError("select", sql, len(args), err))
 	}
+	if cancel != nil {
+		// the rows are read with the context of the call
+		q.rows = &closeHookRows{Rows: q.rows, onClose: func(pgx.Rows) { cancel() }}
+	}
+
+	q.setRows(q.rows)
+	return nil
+}
+
+// setRows - set the active selection and index its fields
+func (q *Query) setRows(rows pgx.Rows) {
+	q.tag = []byte{}
+	q.rowsRead = 0
+	q.onRow = false
+	q.rowsErr = nil
+	q.lastValues = nil
+	q.lastDescriptions = nil
+	q.rows = rows
 
+	q.resetFields()
 	for i, d := range q.Fields() {
 		q.fields[strings.ToLower(string(d.Name))] = i
 	}
-
-	return nil
 }
 
 // SelectRow - executing the select command for 1 row select
 func (q *Query) SelectRow(sql string) (bool, error) {
-	err := q.Select(sql)
+	return q.SelectRowArgs(sql)
+}
+
+// SelectRowArgs - executing the select command with $1, $2... parameters for 1 row select
+func (q *Query) SelectRowArgs(sql string, args ...any) (bool, error) {
+	err := q.SelectArgs(sql, args...)
 	if err != nil {
 		return false, err
 	}
@@ -179,15 +372,30 @@ func (q *Query) SelectRow(sql string) (bool, error) {
 
 // SelectBind - executing the select command with the substitution of values in the template
 func (q *Query) SelectBind(sqlTemplate string, values map[string]any, key string) error {
-	binder := sqlb.NewBinder(sqlTemplate, key)
-	if err := binder.BindValues(values); err != nil {
+	if sql, args, err := bindSql(q.Binder(), sqlTemplate, values, key); err != nil {
 		return err
+	} else {
+		return withBindParams(q.SelectArgs(sql, args...), key, values)
 	}
+}
+
+// SelectBindStruct - executing the select command with the substitution of the struct field values in the template
+func (q *Query) SelectBindStruct(sqlTemplate string, src any, key string) error {
+	values, err := structValues(src, q.FieldMapper())
+	if err != nil {
+		return nerr.New(err)
+	}
+
+	return q.SelectBind(sqlTemplate, values, key)
+}
 
-	if sql, err := binder.Sql(); err != nil {
+// SelectBindKeys - executing the select command with the substitution of several placeholder groups,
+// each with its own key: map[string]map[string]any{":f:": filters, ":p:": paging}
+func (q *Query) SelectBindKeys(sqlTemplate string, values map[string]map[string]any) error {
+	if sql, args, err := bindSqlKeys(q.Binder(), sqlTemplate, values); err != nil {
 		return err
 	} else {
-		return q.Select(sql)
+		return withBindKeysParams(q.SelectArgs(sql, args...), values)
 	}
 }
 
@@ -211,7 +419,21 @@ func (q *Query) Next() bool {
 		return false
 	}
 
-	return q.rows.Next()
+	q.rowValues = nil
+	q.onRow = false
+	if !q.rows.Next() {
+		return false
+	}
+
+	if q.maxRows > 0 && q.rowsRead >= q.maxRows {
+		q.rowsErr = nerr.New(fmt.Errorf("%w: %d", ErrMaxRowsExceeded, q.maxRows))
+		q.rows.Close()
+		return false
+	}
+
+	q.rowsRead++
+	q.onRow = true
+	return true
 }
 
 // Fields - list of fields (Select only)
@@ -238,16 +460,7 @@ func (q *Query) FieldName(index int) string {
 
 // FieldTypeIndex -  field type by index. Result: pgtype.BoolOID, ... etc
 func (q *Query) FieldTypeIndex(index int) uint32 {
-	if q.rows == nil || index < 0 || index >= len(q.Fields()) {
-		return 0
-	}
-
-	c, err := q.pool.Acquire(q.Context())
-	if err != nil {
-		return 0
-	}
-
-	if dt, ok := c.Conn().ConnInfo().DataTypeForOID(q.Fields()[index].DataTypeOID); !ok {
+	if dt, ok := q.fieldDataType(index); !ok {
 		return 0
 	} else {
 		return dt.OID
@@ -256,16 +469,7 @@ func (q *Query) FieldTypeIndex(index int) uint32 {
 
 // FieldTypeIndex -  field type by index. Result: type name
 func (q *Query) FieldTypeNameIndex(index int) string {
-	if q.rows == nil || index < 0 || index >= len(q.Fields()) {
-		return ""
-	}
-
-	c, err := q.pool.Acquire(q.Context())
-	if err != nil {
-		return ""
-	}
-
-	if dt, ok := c.Conn().ConnInfo().DataTypeForOID(q.Fields()[index].DataTypeOID); !ok {
+	if dt, ok := q.fieldDataType(index); !ok {
 		return ""
 	} else {
 		return dt.Name
@@ -296,7 +500,19 @@ func (q *Query) Contains(field string) bool {
 	return ok
 }
 
+// Values - values of the current row. The slice belongs to the caller, a new one is returned for every call
 func (q *Query) Values() ([]any, error) {
+	values, err := q.ValuesShared()
+	if err != nil {
+		return nil, err
+	}
+	return append(make([]any, 0, len(values)), values...), nil
+}
+
+// ValuesShared - values of the current row without allocation of the slice. The row is decoded once, the slice is shared
+// by the accessors until the next Next call and must not be modified. The slice is reused by the next row,
+// use Values to keep the values
+func (q *Query) ValuesShared() ([]any, error) {
 	if q.rows == nil {
 		if len(q.lastDescriptions) == 0 {
 			return []any{}, nil
@@ -305,7 +521,125 @@ func (q *Query) Values() ([]any, error) {
 		}
 	}
 
-	return q.rows.Values()
+	if q.rowValues != nil && q.rowValuesOf == q.rows {
+		return q.rowValues, nil
+	}
+
+	var values []any
+	var err error
+	if q.typeInfo != nil && q.onRow {
+		// rows of the connection are decoded into the reused slice
+		values, err = q.decodeValues(q.typeInfo)
+	} else {
+		values, err = q.rows.Values()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	q.rowValues = values
+	q.rowValuesOf = q.rows
+	return values, nil
+}
+
+// Scan - read the values of the current row into dest, same as pgx.Rows.Scan (only for Select and after a successful Next call)
+func (q *Query) Scan(dest ...any) error {
+	if q.rows == nil {
+		return nerr.New("no active select to scan")
+	}
+
+	return nerr.New(q.rows.Scan(dest...))
+}
+
+// ScanNamed - read the values of the current row into pointers by field name, with type conversion and NULL handling (only for Select and after a successful Next call)
+func (q *Query) ScanNamed(dest map[string]any) error {
+	values, err := q.ValuesShared()
+	if err != nil {
+		return nerr.New(err)
+	}
+
+	for field, d := range dest {
+		if err := q.scanNamedField(d, field, values); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scanNamedField - read the value of the field of the current row into the pointer
+func (q *Query) scanNamedField(dest any, field string, values []any) error {
+	pos, ok := q.fields[strings.ToLower(field)]
+	if !ok || pos >= len(values) {
+		return nerr.New(fmt.Errorf("can't find field %s", field))
+	}
+
+	if err := q.scanField(dest, pos, values); err != nil {
+		return nerr.New(fmt.Errorf("field %s: %w", field, err))
+	}
+	return nil
+}
+
+// ScanStruct - read the values of the current row into the struct fields (only for Select and after a successful Next call).
+// Column names are resolved by the FieldMapper, see DefaultFieldMapper. Fields of embedded structs are mapped
+// without a prefix. Nested pointers are allocated only if some
+// of their columns are not NULL. Columns without fields and fields without columns are ignored
+func (q *Query) ScanStruct(dest any) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nerr.New(fmt.Errorf("destination must be a non-nil pointer to struct: %T", dest))
+	}
+	v = v.Elem()
+
+	values, err := q.ValuesShared()
+	if err != nil {
+		return nerr.New(err)
+	}
+
+	fields := cachedStructFields(v.Type(), q.FieldMapper())
+	if q.strictMapping {
+		if err := q.checkStrictMapping(v.Type(), fields); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range fields {
+		pos, ok := q.fields[strings.ToLower(f.column)]
+		if !ok || pos >= len(values) {
+			continue
+		}
+
+		fv := fieldByIndex(v, f.index, values[pos] != nil)
+		if !fv.IsValid() {
+			continue
+		}
+
+		if err := q.scanField(fv.Addr().Interface(), pos, values); err != nil {
+			return nerr.New(fmt.Errorf("field %s: %w", f.column, err))
+		}
+	}
+
+	return nil
+}
+
+// checkStrictMapping - every column must have a field and every field must have a column
+func (q *Query) checkStrictMapping(t reflect.Type, fields []structField) error {
+	mapped := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		column := strings.ToLower(f.column)
+		if _, ok := q.fields[column]; !ok {
+			return nerr.New(fmt.Errorf("%s: no column for field %s", t, f.column))
+		}
+		mapped[column] = true
+	}
+
+	for _, d := range q.Fields() {
+		if !mapped[strings.ToLower(string(d.Name))] {
+			return nerr.New(fmt.Errorf("%s: no field for column %s", t, d.Name))
+		}
+	}
+
+	return nil
 }
 
 func (q *Query) IsNull(field string) bool {
@@ -323,7 +657,7 @@ func (q *Query) Value(field string) any {
 		return nil
 	}
 
-	values, err := q.Values()
+	values, err := q.ValuesShared()
 	if err != nil || len(values) <= pos {
 		return nil
 	}
@@ -337,7 +671,7 @@ func (q *Query) ValueIndex(fieldIndex int) any {
 		return nil
 	}
 
-	vals, err := q.Values()
+	vals, err := q.ValuesShared()
 	if err != nil || len(vals) <= fieldIndex {
 		return nil
 	}
@@ -356,23 +690,7 @@ func (q *Query) String(field string) string {
 		return ""
 	}
 
-	switch d := v.(type) {
-	case string:
-		return d
-	case pgtype.Text:
-		return d.String
-	case pgtype.Varchar:
-		return d.String
-	case []byte:
-		if b, err := hex.DecodeString(string(d)); err != nil {
-			return string(d)
-		} else {
-			return string(b)
-		}
-
-	default:
-		return fmt.Sprintf("%v", d)
-	}
+	return stringConvertHelper(v)
 }
 
 func (q *Query) Json(field string) json.RawMessage {
@@ -528,50 +846,191 @@ func intArrayHelper[T int | int8 | int16 | int32 | int64 | uint | uint8 | uint16
 }
 
 func intConvertHelper[T int | int8 | int16 | int32 | int64 | uint | uint8 | uint16 | uint32 | uint64](v any) T {
+	if i, ok := intConvert[T](v); ok {
+		return i
+	}
+
+	panic("can't convert")
+}
+
+func intConvert[T int | int8 | int16 | int32 | int64 | uint | uint8 | uint16 | uint32 | uint64](v any) (T, bool) {
 	if v == nil {
-		return T(0)
+		return T(0), true
 	}
 
 	switch d := v.(type) {
 	case bool:
 		if d {
-			return 1
+			return 1, true
 		} else {
-			return 0
+			return 0, true
 		}
 	case int:
-		return T(d)
+		return T(d), true
 	case int8:
-		return T(d)
+		return T(d), true
 	case int16:
-		return T(d)
+		return T(d), true
 	case int32:
-		return T(d)
+		return T(d), true
 	case int64:
-		return T(d)
+		return T(d), true
 	case uint:
-		return T(d)
+		return T(d), true
 	case uint8:
-		return T(d)
+		return T(d), true
 	case uint16:
-		return T(d)
+		return T(d), true
 	case uint32:
-		return T(d)
+		return T(d), true
 	case uint64:
-		return T(d)
+		return T(d), true
 	case float32:
-		return T(d)
+		return T(d), true
 	case float64:
-		return T(d)
+		return T(d), true
 	case string:
 		if r, err := strconv.ParseInt(d, 10, 64); err == nil {
-			return T(r)
+			return T(r), true
 		}
 
 	default:
 	}
 
-	panic("can't convert")
+	return T(0), false
+}
+
+func stringConvertHelper(v any) string {
+	switch d := v.(type) {
+	case string:
+		return d
+	case pgtype.Text:
+		return d.String
+	case pgtype.Varchar:
+		return d.String
+	case []byte:
+		return string(d)
+
+	default:
+		return fmt.Sprintf("%v", d)
+	}
+}
+
+func boolConvertHelper(v any) (bool, bool) {
+	switch d := v.(type) {
+	case bool:
+		return d, true
+	case int:
+		return d > 0, true
+	case int8:
+		return d > 0, true
+	case int16:
+		return d > 0, true
+	case int32:
+		return d > 0, true
+	case int64:
+		return d > 0, true
+	case uint:
+		return d > 0, true
+	case uint8:
+		return d > 0, true
+	case uint16:
+		return d > 0, true
+	case uint32:
+		return d > 0, true
+	case uint64:
+		return d > 0, true
+	case float32:
+		return d > 0, true
+	case float64:
+		return d > 0, true
+	case string:
+		s := strings.ToLower(d)
+		if s == "true" || s == "yes" {
+			return true, true
+		} else if s == "false" || s == "no" {
+			return false, true
+		}
+
+	default:
+	}
+
+	return false, false
+}
+
+func floatConvertHelper(v any) (float64, bool) {
+	switch d := v.(type) {
+	case bool:
+		if d {
+			return 1, true
+		} else {
+			return 0, true
+		}
+	case int:
+		return float64(d), true
+	case int8:
+		return float64(d), true
+	case int16:
+		return float64(d), true
+	case int32:
+		return float64(d), true
+	case int64:
+		return float64(d), true
+	case uint:
+		return float64(d), true
+	case uint8:
+		return float64(d), true
+	case uint16:
+		return float64(d), true
+	case uint32:
+		return float64(d), true
+	case uint64:
+		return float64(d), true
+	case float32:
+		return float64(d), true
+	case float64:
+		return d, true
+	case string:
+		if r, err := strconv.ParseFloat(d, 64); err == nil {
+			return r, true
+		}
+
+	default:
+	}
+
+	return 0, false
+}
+
+func timeConvertHelper(v any) (time.Time, bool) {
+	switch d := v.(type) {
+
+	case time.Time:
+		return d, true
+	case string:
+		format := "2006-01-02 15:04:05"
+		if len(d) > len(format) {
+			format = "2006-01-02 15:04:05.000"
+		}
+		if len(d) > len(format) {
+			format = "2006-01-02 15:04:05.000 -0700"
+		}
+
+		t, err := time.Parse(format, d)
+		if err == nil {
+			return t, true
+		}
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		dur, err := time.ParseDuration(fmt.Sprintf("%dµs", d)) // postrgesql хранит time как микросекунды с начала суток
+		if err == nil {
+			t := time.Time{}
+			t = t.Add(dur)
+			return t, true
+		}
+
+	default:
+	}
+
+	return time.Time{}, false
 }
 
 // Int64 - field value by name, converted to int64 (only for Select and after a successful Next call)
@@ -613,42 +1072,8 @@ func (q *Query) Bool(field string) bool {
 		return false
 	}
 
-	switch d := v.(type) {
-	case bool:
-		return d
-	case int:
-		return d > 0
-	case int8:
-		return d > 0
-	case int16:
-		return d > 0
-	case int32:
-		return d > 0
-	case int64:
-		return d > 0
-	case uint:
-		return d > 0
-	case uint8:
-		return d > 0
-	case uint16:
-		return d > 0
-	case uint32:
-		return d > 0
-	case uint64:
-		return d > 0
-	case float32:
-		return d > 0
-	case float64:
-		return d > 0
-	case string:
-		s := strings.ToLower(d)
-		if s == "true" || s == "yes" {
-			return true
-		} else if s == "false" || s == "no" {
-			return false
-		}
-
-	default:
+	if b, ok := boolConvertHelper(v); ok {
+		return b
 	}
 
 	panic(fmt.Errorf("can't convert to bool: %s", field))
@@ -679,43 +1104,8 @@ func (q *Query) Float64(field string) float64 {
 		return 0
 	}
 
-	switch d := v.(type) {
-	case bool:
-		if d {
-			return 1
-		} else {
-			return 0
-		}
-	case int:
-		return float64(d)
-	case int8:
-		return float64(d)
-	case int16:
-		return float64(d)
-	case int32:
-		return float64(d)
-	case int64:
-		return float64(d)
-	case uint:
-		return float64(d)
-	case uint8:
-		return float64(d)
-	case uint16:
-		return float64(d)
-	case uint32:
-		return float64(d)
-	case uint64:
-		return float64(d)
-	case float32:
-		return float64(d)
-	case float64:
-		return d
-	case string:
-		if r, err := strconv.ParseFloat(d, 64); err == nil {
-			return r
-		}
-
-	default:
+	if f, ok := floatConvertHelper(v); ok {
+		return f
 	}
 
 	panic(fmt.Errorf("can't convert to float: %s", field))
@@ -737,32 +1127,8 @@ func (q *Query) Time(field string) time.Time {
 		return time.Time{}
 	}
 
-	switch d := v.(type) {
-
-	case time.Time:
-		return d
-	case string:
-		format := "2006-01-02 15:04:05"
-		if len(d) > len(format) {
-			format = "2006-01-02 15:04:05.000"
-		}
-		if len(d) > len(format) {
-			format = "2006-01-02 15:04:05.000 -0700"
-		}
-
-		t, err := time.Parse(format, d)
-		if err == nil {
-			return t
-		}
-	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
-		dur, err := time.ParseDuration(fmt.Sprintf("%dµs", d)) // postrgesql хранит time как микросекунды с начала суток
-		if err == nil {
-			t := time.Time{}
-			t = t.Add(dur)
-			return t
-		}
-
-	default:
+	if t, ok := timeConvertHelper(v); ok {
+		return t
 	}
 
 	panic(fmt.Errorf("can't convert to time.Time: %s", field))
@@ -802,6 +1168,11 @@ func (q *Query) Bytes(field string) []byte {
 	case []byte:
 		return d
 	case string:
+		if q.isTextBytea(field) {
+			if b, err := decodeBytea(d); err == nil {
+				return b
+			}
+		}
 		return []byte(d)
 	default:
 	}
@@ -809,33 +1180,72 @@ func (q *Query) Bytes(field string) []byte {
 	panic(fmt.Errorf("can't convert to []byte: %s", field))
 }
 
-func Select(pool *pgxpool.Pool, ctx context.Context, sql string) (*Query, error) {
+// ByteaString - bytea field value by name, converted to string (only for Select and after a successful Next call)
+func (q *Query) ByteaString(field string) string {
+	return string(q.Bytes(field))
+}
+
+// isTextBytea - the field is bytea received in the text format, i.e. in the \x... representation
+func (q *Query) isTextBytea(field string) bool {
+	pos, ok := q.fields[strings.ToLower(field)]
+	fields := q.Fields()
+	if !ok || pos >= len(fields) {
+		return false
+	}
+
+	return fields[pos].DataTypeOID == pgtype.ByteaOID && fields[pos].Format == pgx.TextFormatCode
+}
+
+func Select(pool *pgxpool.Pool, ctx context.Context, sql string, opts ...CallOption) (*Query, error) {
 	q := NewQuery(pool, ctx)
-	if err := q.Select(sql); err != nil {
+	if err := q.Select(sql, opts...); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func SelectArgs(pool *pgxpool.Pool, ctx context.Context, sql string, args ...any) (*Query, error) {
+	q := NewQuery(pool, ctx)
+	if err := q.SelectArgs(sql, args...); err != nil {
 		return nil, err
 	}
 	return q, nil
 }
 
 func SelectBind(pool *pgxpool.Pool, ctx context.Context, template string, values map[string]any, key string) (*Query, error) {
-	if sql, err := sqlb.Bind(template, values, key); err != nil {
+	if sql, args, err := bindSql(defaultBinder, template, values, key); err != nil {
 		return nil, err
 	} else {
-		return Select(pool, ctx, sql)
+		q, err := SelectArgs(pool, ctx, sql, args...)
+		return q, withBindParams(err, key, values)
+	}
+}
+
+func SelectBindKeys(pool *pgxpool.Pool, ctx context.Context, template string, values map[string]map[string]any) (*Query, error) {
+	if sql, args, err := bindSqlKeys(defaultBinder, template, values); err != nil {
+		return nil, err
+	} else {
+		q, err := SelectArgs(pool, ctx, sql, args...)
+		return q, withBindKeysParams(err, values)
 	}
 }
 
 func SelectBindOne(pool *pgxpool.Pool, ctx context.Context, template string, variable string, value any, key string) (*Query, error) {
-	if sql, err := sqlb.BindOne(template, variable, value, key); err != nil {
+	if sql, args, err := bindSql(defaultBinder, template, map[string]any{variable: value}, key); err != nil {
 		return nil, err
 	} else {
-		return Select(pool, ctx, sql)
+		q, err := SelectArgs(pool, ctx, sql, args...)
+		return q, withBindParams(err, key, map[string]any{variable: value})
 	}
 }
 
 func SelectRow(pool *pgxpool.Pool, context context.Context, sql string) (*Query, error) {
+	return SelectRowArgs(pool, context, sql)
+}
+
+func SelectRowArgs(pool *pgxpool.Pool, context context.Context, sql string, args ...any) (*Query, error) {
 	q := NewQuery(pool, context)
-	if ok, err := q.SelectRow(sql); err != nil {
+	if ok, err := q.SelectRowArgs(sql, args...); err != nil {
 		return nil, err
 	} else if !ok {
 		return nil, nil
@@ -845,48 +1255,73 @@ func SelectRow(pool *pgxpool.Pool, context context.Context, sql string) (*Query,
 }
 
 func SelectRowBindOne(pool *pgxpool.Pool, context context.Context, template string, variable string, value any, key string) (*Query, error) {
-	if sql, err := sqlb.BindOne(template, variable, value, key); err != nil {
+	if sql, args, err := bindSql(defaultBinder, template, map[string]any{variable: value}, key); err != nil {
 		return nil, err
 	} else {
-		return SelectRow(pool, context, sql)
+		q, err := SelectRowArgs(pool, context, sql, args...)
+		return q, withBindParams(err, key, map[string]any{variable: value})
 	}
 }
 
 func SelectRowBind(pool *pgxpool.Pool, context context.Context, template string, values map[string]any, key string) (*Query, error) {
-	if sql, err := sqlb.Bind(template, values, key); err != nil {
+	if sql, args, err := bindSql(defaultBinder, template, values, key); err != nil {
 		return nil, err
 	} else {
-		return SelectRow(pool, context, sql)
+		q, err := SelectRowArgs(pool, context, sql, args...)
+		return q, withBindParams(err, key, values)
 	}
 }
 
-func SelectTx(tx *Tx, sql string) (*Query, error) {
+func SelectTx(tx *Tx, sql string, opts ...CallOption) (*Query, error) {
 	q := NewQueryTx(tx, tx.ctx)
-	if err := q.Select(sql); err != nil {
+	if err := q.Select(sql, opts...); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func SelectTxArgs(tx *Tx, sql string, args ...any) (*Query, error) {
+	q := NewQueryTx(tx, tx.ctx)
+	if err := q.SelectArgs(sql, args...); err != nil {
 		return nil, err
 	}
 	return q, nil
 }
 
 func SelectTxBindOne(tx *Tx, template string, variable string, value any, key string) (*Query, error) {
-	if sql, err := sqlb.BindOne(template, variable, value, key); err != nil {
+	if sql, args, err := bindSql(defaultBinder, template, map[string]any{variable: value}, key); err != nil {
 		return nil, err
 	} else {
-		return SelectTx(tx, sql)
+		q, err := SelectTxArgs(tx, sql, args...)
+		return q, withBindParams(err, key, map[string]any{variable: value})
 	}
 }
 
 func SelectTxBind(tx *Tx, template string, values map[string]any, key string) (*Query, error) {
-	if sql, err := sqlb.Bind(template, values, key); err != nil {
+	if sql, args, err := bindSql(defaultBinder, template, values, key); err != nil {
+		return nil, err
+	} else {
+		q, err := SelectTxArgs(tx, sql, args...)
+		return q, withBindParams(err, key, values)
+	}
+}
+
+func SelectTxBindKeys(tx *Tx, template string, values map[string]map[string]any) (*Query, error) {
+	if sql, args, err := bindSqlKeys(defaultBinder, template, values); err != nil {
 		return nil, err
 	} else {
-		return SelectTx(tx, sql)
+		q, err := SelectTxArgs(tx, sql, args...)
+		return q, withBindKeysParams(err, values)
 	}
 }
 
 func SelectTxRow(tx *Tx, sql string) (*Query, error) {
+	return SelectTxRowArgs(tx, sql)
+}
+
+func SelectTxRowArgs(tx *Tx, sql string, args ...any) (*Query, error) {
 	q := NewQueryTx(tx, tx.ctx)
-	if ok, err := q.SelectRow(sql); err != nil {
+	if ok, err := q.SelectRowArgs(sql, args...); err != nil {
 		return nil, err
 	} else if !ok {
 		return nil, nil
@@ -896,65 +1331,130 @@ func SelectTxRow(tx *Tx, sql string) (*Query, error) {
 }
 
 func SelectTxRowBindOne(tx *Tx, template string, variable string, value any, key string) (*Query, error) {
-	if sql, err := sqlb.BindOne(template, variable, value, key); err != nil {
+	if sql, args, err := bindSql(defaultBinder, template, map[string]any{variable: value}, key); err != nil {
 		return nil, err
 	} else {
-		return SelectTxRow(tx, sql)
+		q, err := SelectTxRowArgs(tx, sql, args...)
+		return q, withBindParams(err, key, map[string]any{variable: value})
 	}
 }
 
 func SelectTxRowBind(tx *Tx, template string, values map[string]any, key string) (*Query, error) {
-	if sql, err := sqlb.Bind(template, values, key); err != nil {
+	if sql, args, err := bindSql(defaultBinder, template, values, key); err != nil {
 		return nil, err
 	} else {
-		return SelectTxRow(tx, sql)
+		q, err := SelectTxRowArgs(tx, sql, args...)
+		return q, withBindParams(err, key, values)
+	}
+}
+
+// ForEach - execute the select and call fn for each row. The query is always closed, the first error is returned
+func ForEach(pool *pgxpool.Pool, ctx context.Context, sql string, fn func(q *Query) error) error {
+	return forEachHelper(NewQuery(pool, ctx), sql, fn)
+}
+
+// ForEachTx - execute the select inside the transaction and call fn for each row. The query is always closed, the first error is returned
+func ForEachTx(tx *Tx, sql string, fn func(q *Query) error) error {
+	return forEachHelper(NewQueryTx(tx, tx.ctx), sql, fn)
+}
+
+func forEachHelper(q *Query, sql string, fn func(q *Query) error) error {
+	if err := q.Select(sql); err != nil {
+		return err
 	}
+
+	for q.Next() {
+		if err := fn(q); err != nil {
+			_ = q.Close()
+			return err
+		}
+	}
+
+	return nerr.New(q.Close())
 }
 
-func Exec(pool *pgxpool.Pool, context context.Context, sql string) (*Query, error) {
+func Exec(pool *pgxpool.Pool, context context.Context, sql string, opts ...CallOption) (*Query, error) {
 	q := NewQuery(pool, context)
-	if err := q.Exec(sql); err != nil {
+	if err := q.Exec(sql, opts...); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func ExecArgs(pool *pgxpool.Pool, context context.Context, sql string, args ...any) (*Query, error) {
+	q := NewQuery(pool, context)
+	if err := q.ExecArgs(sql, args...); err != nil {
 		return nil, err
 	}
 	return q, nil
 }
 
 func ExecBindOne(pool *pgxpool.Pool, context context.Context, template string, variable string, value any, key string) (*Query, error) {
-	if sql, err := sqlb.BindOne(template, variable, value, key); err != nil {
+	if sql, args, err := bindSql(defaultBinder, template, map[string]any{variable: value}, key); err != nil {
 		return nil, err
 	} else {
-		return Exec(pool, context, sql)
+		q, err := ExecArgs(pool, context, sql, args...)
+		return q, withBindParams(err, key, map[string]any{variable: value})
 	}
 }
 
 func ExecBind(pool *pgxpool.Pool, context context.Context, template string, values map[string]any, key string) (*Query, error) {
-	if sql, err := sqlb.Bind(template, values, key); err != nil {
+	if sql, args, err := bindSql(defaultBinder, template, values, key); err != nil {
+		return nil, err
+	} else {
+		q, err := ExecArgs(pool, context, sql, args...)
+		return q, withBindParams(err, key, values)
+	}
+}
+
+func ExecBindKeys(pool *pgxpool.Pool, context context.Context, template string, values map[string]map[string]any) (*Query, error) {
+	if sql, args, err := bindSqlKeys(defaultBinder, template, values); err != nil {
 		return nil, err
 	} else {
-		return Exec(pool, context, sql)
+		q, err := ExecArgs(pool, context, sql, args...)
+		return q, withBindKeysParams(err, values)
 	}
 }
 
-func ExecTx(tx *Tx, sql string) (*Query, error) {
+func ExecTx(tx *Tx, sql string, opts ...CallOption) (*Query, error) {
 	q := NewQueryTx(tx, tx.ctx)
-	if err := q.Exec(sql); err != nil {
+	if err := q.Exec(sql, opts...); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func ExecTxArgs(tx *Tx, sql string, args ...any) (*Query, error) {
+	q := NewQueryTx(tx, tx.ctx)
+	if err := q.ExecArgs(sql, args...); err != nil {
 		return nil, err
 	}
 	return q, nil
 }
 
 func ExecTxBindOne(tx *Tx, template string, variable string, value any, key string) (*Query, error) {
-	if sql, err := sqlb.BindOne(template, variable, value, key); err != nil {
+	if sql, args, err := bindSql(defaultBinder, template, map[string]any{variable: value}, key); err != nil {
 		return nil, err
 	} else {
-		return ExecTx(tx, sql)
+		q, err := ExecTxArgs(tx, sql, args...)
+		return q, withBindParams(err, key, map[string]any{variable: value})
 	}
 }
 
 func ExecTxBind(tx *Tx, template string, values map[string]any, key string) (*Query, error) {
-	if sql, err := sqlb.Bind(template, values, key); err != nil {
+	if sql, args, err := bindSql(defaultBinder, template, values, key); err != nil {
+		return nil, err
+	} else {
+		q, err := ExecTxArgs(tx, sql, args...)
+		return q, withBindParams(err, key, values)
+	}
+}
+
+func ExecTxBindKeys(tx *Tx, template string, values map[string]map[string]any) (*Query, error) {
+	if sql, args, err := bindSqlKeys(defaultBinder, template, values); err != nil {
 		return nil, err
 	} else {
-		return ExecTx(tx, sql)
+		q, err := ExecTxArgs(tx, sql, args...)
+		return q, withBindKeysParams(err, values)
 	}
 }
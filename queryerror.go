@@ -0,0 +1,76 @@
+package sqlq
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maximum length of the sql text in QueryError
+const maxQueryErrorSql = 500
+
+// QueryError - error of Exec or Select with the statement context. Unwrap returns the error of the statement,
+// so errors.As(err, &pgErr) works as before
+type QueryError struct {
+	// Op - "exec" or "select"
+	Op string
+	// Sql - the statement text, truncated
+	Sql string
+	// Params - names of the parameters: $1, $2... or the placeholders of the bound template (:id, :name)
+	Params []string
+	Err    error
+}
+
+func (e *QueryError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s failed: %v; sql: %s", e.Op, e.Err, e.Sql)
+	if len(e.Params) > 0 {
+		fmt.Fprintf(&b, "; params: %s", strings.Join(e.Params, ", "))
+	}
+	return b.String()
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// newQueryError - QueryError of the statement with positional parameters
+func newQueryError(op string, sql string, argCount int, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if r := []rune(sql); len(r) > maxQueryErrorSql {
+		sql = string(r[:maxQueryErrorSql]) + "..."
+	}
+
+	params := make([]string, argCount)
+	for i := range params {
+		params[i] = fmt.Sprintf("$%d", i+1)
+	}
+
+	return &QueryError{Op: op, Sql: sql, Params: params, Err: err}
+}
+
+// withBindParams - set the placeholder names of the bound template to QueryError
+func withBindParams(err error, key string, values map[string]any) error {
+	return withBindKeysParams(err, map[string]map[string]any{key: values})
+}
+
+// withBindKeysParams - set the placeholder names of the template bound with several keys to QueryError
+func withBindKeysParams(err error, values map[string]map[string]any) error {
+	var qe *QueryError
+	if !errors.As(err, &qe) {
+		return err
+	}
+
+	qe.Params = qe.Params[:0]
+	for key, vals := range values {
+		for name := range vals {
+			qe.Params = append(qe.Params, key+name)
+		}
+	}
+	sort.Strings(qe.Params)
+	return err
+}
@@ -0,0 +1,83 @@
+package sqlq
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Ident - identifier value for template binding, substituted quoted instead of as a literal:
+// SelectBind("SELECT * FROM :table WHERE id = :id", map[string]any{"table": sqlq.Ident("tenant_1.users"), "id": 1}, ":").
+// Schema-qualified names are split by the dot
+type Ident string
+
+// QuoteIdent - quoted identifier that can be safely injected into sql: table or column name chosen at runtime
+func QuoteIdent(name string) string {
+	return quoteIdent(strings.ReplaceAll(name, "\x00", ""))
+}
+
+// QuoteQualified - quoted schema-qualified table name. Empty schema - table only
+func QuoteQualified(schema string, table string) string {
+	if schema == "" {
+		return QuoteIdent(table)
+	}
+	return QuoteIdent(schema) + "." + QuoteIdent(table)
+}
+
+// quoteLiteral - convert the string to an sql string literal
+func quoteLiteral(s string) string {
+	s = strings.ReplaceAll(s, "'", "''")
+	if strings.Contains(s, `\`) {
+		return `E'` + strings.ReplaceAll(s, `\`, `\\`) + `'`
+	}
+
+	return "'" + s + "'"
+}
+
+// quoteIdent - convert the string to an sql identifier
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteName - quote each part of a possibly schema-qualified name (schema.table), see QuoteIdent
+func quoteName(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = QuoteIdent(p)
+	}
+	return strings.Join(parts, ".")
+}
+
+// decodeBytea - decode the text representation of bytea: hex (\x...) or escape format
+func decodeBytea(s string) ([]byte, error) {
+	if strings.HasPrefix(s, `\x`) {
+		return hex.DecodeString(s[2:])
+	}
+
+	res := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			res = append(res, s[i])
+			continue
+		}
+
+		if i+1 < len(s) && s[i+1] == '\\' {
+			res = append(res, '\\')
+			i++
+			continue
+		}
+
+		if i+3 >= len(s) {
+			return nil, fmt.Errorf("invalid bytea escape sequence")
+		}
+		b, err := strconv.ParseUint(s[i+1:i+4], 8, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bytea escape sequence: %w", err)
+		}
+		res = append(res, byte(b))
+		i += 3
+	}
+
+	return res, nil
+}
@@ -0,0 +1,196 @@
+package sqlq
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/n-r-w/nerr"
+)
+
+// Day - retention period unit
+const Day = 24 * time.Hour
+
+const (
+	defaultRetentionBatch    = 1000
+	defaultRetentionPause    = 100 * time.Millisecond
+	defaultRetentionInterval = time.Hour
+)
+
+// Retention - rows of the table older than Keep are purged: Retention{Table: "log", TimeColumn: "created_at", Keep: 90 * Day}
+type Retention struct {
+	// Table - table name, may be schema-qualified
+	Table string
+	// TimeColumn - timestamp column compared with now() - Keep
+	TimeColumn string
+	// Keep - retention period
+	Keep time.Duration
+	// Where - optional additional condition for the deleted rows
+	Where string
+	// BatchSize - rows deleted by one statement. 0 - 1000
+	BatchSize int
+	// Pause - pause between batches, so that autovacuum and other sessions keep up. 0 - 100ms
+	Pause time.Duration
+}
+
+// RetentionStats - purge statistics of the table
+type RetentionStats struct {
+	Table string
+	// Purged - total rows deleted
+	Purged int64
+	// LastPurged - rows deleted during the last run
+	LastPurged int64
+	// LastRun - time of the last run
+	LastRun time.Time
+	// LastDuration - duration of the last run
+	LastDuration time.Duration
+	// LastError - error of the last run
+	LastError error
+}
+
+func (r Retention) batchSql() string {
+	batch := r.BatchSize
+	if batch <= 0 {
+		batch = defaultRetentionBatch
+	}
+
+	table := quoteName(r.Table)
+	where := fmt.Sprintf("%s < now() - make_interval(secs => %s)", quoteIdent(r.TimeColumn), strconv.FormatFloat(r.Keep.Seconds(), 'f', -1, 64))
+	if r.Where != "" {
+		where += " AND (" + r.Where + ")"
+	}
+
+	return fmt.Sprintf("DELETE FROM %s WHERE ctid IN (SELECT ctid FROM %s WHERE %s LIMIT %d)", table, table, where, batch)
+}
+
+// Purge - delete the expired rows in batches. Returns the number of deleted rows
+func (r Retention) Purge(pool *pgxpool.Pool, ctx context.Context) (int64, error) {
+	if r.Table == "" || r.TimeColumn == "" || r.Keep <= 0 {
+		return 0, nerr.New("retention table, time column and period must be set")
+	}
+
+	pause := r.Pause
+	if pause <= 0 {
+		pause = defaultRetentionPause
+	}
+
+	sql := r.batchSql()
+	var total int64
+	for {
+		q, err := Exec(pool, ctx, sql)
+		if err != nil {
+			return total, err
+		}
+
+		n := q.RowsAffected()
+		total += n
+		if n == 0 {
+			return total, nil
+		}
+
+		select {
+		case <-time.After(pause):
+		case <-ctx.Done():
+			return total, nerr.New(ctx.Err())
+		}
+	}
+}
+
+// RetentionScheduler - periodically purges the expired rows of the tables
+type RetentionScheduler struct {
+	pool     *pgxpool.Pool
+	interval time.Duration
+	specs    []Retention
+
+	mu sync.Mutex
+	// statistics of the specs by index, the same table may have several specs
+	stats []RetentionStats
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewRetentionScheduler - create a scheduler that purges the tables with the specified interval (1h if interval <= 0)
+func NewRetentionScheduler(pool *pgxpool.Pool, interval time.Duration, specs ...Retention) *RetentionScheduler {
+	if interval <= 0 {
+		interval = defaultRetentionInterval
+	}
+
+	s := &RetentionScheduler{
+		pool:     pool,
+		interval: interval,
+		specs:    specs,
+		stats:    make([]RetentionStats, len(specs)),
+	}
+	for i, r := range specs {
+		s.stats[i].Table = r.Table
+	}
+	return s
+}
+
+// Start - start purging in the background, the first run is immediate
+func (s *RetentionScheduler) Start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			_ = s.RunOnce(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop - stop purging and wait for the current run to complete
+func (s *RetentionScheduler) Stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+}
+
+// RunOnce - purge all tables once. Returns the first error, the remaining tables are purged anyway
+func (s *RetentionScheduler) RunOnce(ctx context.Context) error {
+	var firstErr error
+
+	for i, r := range s.specs {
+		start := time.Now()
+		n, err := r.Purge(s.pool, ctx)
+
+		s.mu.Lock()
+		st := &s.stats[i]
+		st.Purged += n
+		st.LastPurged = n
+		st.LastRun = start
+		st.LastDuration = time.Since(start)
+		st.LastError = err
+		s.mu.Unlock()
+
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// Stats - purge statistics in the order of the specs
+func (s *RetentionScheduler) Stats() []RetentionStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]RetentionStats{}, s.stats...)
+}
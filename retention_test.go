@@ -0,0 +1,45 @@
+package sqlq
+
+import (
+	"context"
+	"testing"
+
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+func TestRetentionStatsPerSpec(t *testing.T) {
+	srv, pool := newTestPool(t)
+
+	specs := []Retention{
+		{Table: "log", TimeColumn: "created_at", Keep: Day, Where: "level = 'debug'"},
+		{Table: "log", TimeColumn: "created_at", Keep: 30 * Day},
+	}
+	srv.Expect(specs[0].batchSql(), sqlqtest.Result{Tag: "DELETE 0"})
+	srv.ExpectError(specs[1].batchSql(), "42501", "permission denied")
+
+	s := NewRetentionScheduler(pool, 0, specs...)
+	if err := s.RunOnce(context.Background()); err == nil {
+		t.Fatal("expected error")
+	}
+
+	stats := s.Stats()
+	if len(stats) != 2 {
+		t.Fatalf("got %d stats, want 2", len(stats))
+	}
+	if stats[0].LastError != nil {
+		t.Errorf("first spec: unexpected error %v", stats[0].LastError)
+	}
+	if stats[1].LastError == nil {
+		t.Error("second spec: expected error")
+	}
+}
+
+func TestRetentionSchedulerDefaultInterval(t *testing.T) {
+	srv, pool := newTestPool(t)
+	spec := Retention{Table: "log", TimeColumn: "created_at", Keep: Day}
+	srv.Expect(spec.batchSql(), sqlqtest.Result{Tag: "DELETE 0"})
+
+	s := NewRetentionScheduler(pool, 0, spec)
+	s.Start(context.Background())
+	s.Stop()
+}
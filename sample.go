@@ -0,0 +1,74 @@
+package sqlq
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/n-r-w/nerr"
+)
+
+// SampleMethod - TABLESAMPLE method
+type SampleMethod string
+
+const (
+	// SampleSystem - block level sampling, fast but less random
+	SampleSystem SampleMethod = "SYSTEM"
+	// SampleBernoulli - row level sampling, scans the whole table
+	SampleBernoulli SampleMethod = "BERNOULLI"
+)
+
+type sampleOptions struct {
+	method SampleMethod
+	seed   *float64
+}
+
+// SampleOption - Sample option
+type SampleOption func(o *sampleOptions)
+
+// SampleWithMethod - sampling method. SampleSystem by default
+func SampleWithMethod(method SampleMethod) SampleOption {
+	return func(o *sampleOptions) {
+		o.method = method
+	}
+}
+
+// SampleWithSeed - seed for REPEATABLE sampling: the same seed returns the same rows if the table has not changed
+func SampleWithSeed(seed float64) SampleOption {
+	return func(o *sampleOptions) {
+		o.seed = &seed
+	}
+}
+
+// SampleSql - build a TABLESAMPLE query: select approximately percent (0-100) of the table rows. where - optional condition
+func SampleSql(table string, percent float64, where string, opts ...SampleOption) (string, error) {
+	if percent < 0 || percent > 100 {
+		return "", nerr.New(fmt.Errorf("invalid sample percent: %v", percent))
+	}
+
+	o := sampleOptions{method: SampleSystem}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	sql := fmt.Sprintf("SELECT * FROM %s TABLESAMPLE %s (%s)", quoteName(table), o.method, strconv.FormatFloat(percent, 'f', -1, 64))
+	if o.seed != nil {
+		sql += fmt.Sprintf(" REPEATABLE (%s)", strconv.FormatFloat(*o.seed, 'f', -1, 64))
+	}
+	if where != "" {
+		sql += " WHERE " + where
+	}
+
+	return sql, nil
+}
+
+// Sample - select approximately percent (0-100) of the table rows. where - optional condition
+func Sample(pool *pgxpool.Pool, ctx context.Context, table string, percent float64, where string, opts ...SampleOption) (*Query, error) {
+	sql, err := SampleSql(table, percent, where, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return Select(pool, ctx, sql)
+}
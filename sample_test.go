@@ -0,0 +1,62 @@
+package sqlq
+
+import (
+	"context"
+	"testing"
+
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+func TestSampleSql(t *testing.T) {
+	tests := []struct {
+		name    string
+		table   string
+		percent float64
+		where   string
+		opts    []SampleOption
+		want    string
+	}{
+		{"default", "events", 10, "", nil, `SELECT * FROM "events" TABLESAMPLE SYSTEM (10)`},
+		{
+			"bernoulli with seed", "app.events", 0.5, "kind = 1",
+			[]SampleOption{SampleWithMethod(SampleBernoulli), SampleWithSeed(42)},
+			`SELECT * FROM "app"."events" TABLESAMPLE BERNOULLI (0.5) REPEATABLE (42) WHERE kind = 1`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SampleSql(tt.table, tt.percent, tt.where, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	for _, percent := range []float64{-1, 100.5} {
+		if _, err := SampleSql("events", percent, ""); err == nil {
+			t.Errorf("percent %v: expected error", percent)
+		}
+	}
+}
+
+func TestSample(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect(`SELECT * FROM "events" TABLESAMPLE SYSTEM (1)`, sqlqtest.Result{
+		Columns: []sqlqtest.Column{{Name: "id"}},
+		Rows:    [][]any{{int64(7)}},
+	})
+
+	q, err := Sample(pool, context.Background(), "events", 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Release()
+
+	if !q.Next() || q.Int64("id") != 7 {
+		t.Error("unexpected rows")
+	}
+}
@@ -0,0 +1,29 @@
+package sqlq
+
+import "github.com/n-r-w/nerr"
+
+// Savepoint - create the named savepoint in the active transaction. Unlike the nested transactions started with Begin,
+// named savepoints don't change the nesting level. A savepoint with the same name hides the previous one
+// until it is released.
+// Named savepoints must be rolled back to and released within the nesting level where they were created:
+// rolling back to a savepoint of the outer level also discards the savepoints of the nested transactions
+func (t *Tx) Savepoint(name string) error {
+	return t.savepointExec("SAVEPOINT ", name)
+}
+
+// RollbackTo - roll back to the named savepoint. The savepoint stays active and can be used again
+func (t *Tx) RollbackTo(name string) error {
+	return t.savepointExec("ROLLBACK TO SAVEPOINT ", name)
+}
+
+// ReleaseSavepoint - release the named savepoint and the savepoints created after it, keeping the changes
+func (t *Tx) ReleaseSavepoint(name string) error {
+	return t.savepointExec("RELEASE SAVEPOINT ", name)
+}
+
+func (t *Tx) savepointExec(command string, name string) error {
+	if name == "" {
+		return nerr.New("empty savepoint name")
+	}
+	return NewQueryTx(t, t.ctx).Exec(command + QuoteIdent(name))
+}
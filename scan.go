@@ -0,0 +1,369 @@
+package sqlq
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+)
+
+// valueAssigner - pgtype values that can convert themselves into the destination
+type valueAssigner interface {
+	AssignTo(dst any) error
+}
+
+// valueSetter - pgtype values that can be set from a Go value
+type valueSetter interface {
+	Set(src any) error
+}
+
+// scanConnInfo - type information for decoding raw values into pgtype destinations
+var scanConnInfo = pgtype.NewConnInfo()
+
+var (
+	scannerType       = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	textDecoderType   = reflect.TypeOf((*pgtype.TextDecoder)(nil)).Elem()
+	binaryDecoderType = reflect.TypeOf((*pgtype.BinaryDecoder)(nil)).Elem()
+)
+
+// scanField - write the field value into the destination pointer (only for Select and after a successful Next call)
+func (q *Query) scanField(dest any, pos int, values []any) error {
+	var raw []byte
+	if q.rows != nil {
+		if rawValues := q.rows.RawValues(); pos < len(rawValues) {
+			raw = rawValues[pos]
+		}
+	}
+
+	var fd *pgproto3.FieldDescription
+	if fields := q.Fields(); pos < len(fields) {
+		fd = &fields[pos]
+	}
+
+	return scanValue(dest, values[pos], raw, fd)
+}
+
+// scanValue - write the field value into the destination pointer. Destinations implementing sql.Scanner,
+// pgtype.TextDecoder or pgtype.BinaryDecoder receive the value as pgx would pass it to them.
+// raw - the value as received from the server, nil if unknown
+func scanValue(dest any, src any, raw []byte, fd *pgproto3.FieldDescription) error {
+	switch d := dest.(type) {
+	case sql.Scanner:
+		return d.Scan(scannerValue(src, raw, fd))
+	case pgtype.TextDecoder:
+		if src == nil {
+			return d.DecodeText(scanConnInfo, nil)
+		}
+		if raw != nil && fd != nil && fd.Format == pgx.TextFormatCode {
+			return d.DecodeText(scanConnInfo, raw)
+		}
+		if s, ok := src.(string); ok {
+			return d.DecodeText(scanConnInfo, []byte(s))
+		}
+	case pgtype.BinaryDecoder:
+		if src == nil {
+			return d.DecodeBinary(scanConnInfo, nil)
+		}
+		if raw != nil && fd != nil && fd.Format == pgx.BinaryFormatCode {
+			return d.DecodeBinary(scanConnInfo, raw)
+		}
+	}
+
+	// nullable destination of a scanner type: **T
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() == reflect.Pointer && !dv.IsNil() && dv.Elem().Kind() == reflect.Pointer && isScannerType(dv.Elem().Type()) {
+		elem := dv.Elem()
+		if src == nil {
+			elem.Set(reflect.Zero(elem.Type()))
+			return nil
+		}
+
+		v := reflect.New(elem.Type().Elem())
+		if err := scanValue(v.Interface(), src, raw, fd); err != nil {
+			return err
+		}
+		elem.Set(v)
+		return nil
+	}
+
+	return assignValue(dest, src)
+}
+
+// isScannerType - pointer type that decodes values itself
+func isScannerType(t reflect.Type) bool {
+	return t.Implements(scannerType) || t.Implements(textDecoderType) || t.Implements(binaryDecoderType)
+}
+
+// scannerValue - value for sql.Scanner: one of the driver.Value types
+func scannerValue(src any, raw []byte, fd *pgproto3.FieldDescription) any {
+	switch d := src.(type) {
+	case nil, int64, float64, bool, []byte, string, time.Time:
+		return d
+	case int, int8, int16, int32, uint, uint8, uint16, uint32, uint64:
+		i, _ := intConvert[int64](d)
+		return i
+	case float32:
+		return float64(d)
+	case driver.Valuer:
+		if v, err := d.Value(); err == nil {
+			return v
+		}
+	}
+
+	if raw != nil && fd != nil && fd.Format == pgx.TextFormatCode {
+		return string(raw)
+	}
+	return src
+}
+
+// assignValue - write the field value into the destination pointer with type conversion.
+// NULL sets the destination to its zero value (nil for pointer destinations)
+func assignValue(dest any, src any) error {
+	if d, ok := dest.(*any); ok {
+		*d = src
+		return nil
+	}
+
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Pointer || dv.IsNil() {
+		return fmt.Errorf("destination must be a non-nil pointer: %T", dest)
+	}
+	elem := dv.Elem()
+
+	if src == nil {
+		elem.Set(reflect.Zero(elem.Type()))
+		return nil
+	}
+
+	var ok bool
+	switch d := dest.(type) {
+	case *string:
+		*d, ok = stringConvertHelper(src), true
+	case *[]byte:
+		switch s := src.(type) {
+		case []byte:
+			*d, ok = s, true
+		case string:
+			*d, ok = []byte(s), true
+		}
+	case *json.RawMessage:
+		switch s := src.(type) {
+		case []byte:
+			*d, ok = json.RawMessage(s), true
+		case string:
+			*d, ok = json.RawMessage(s), true
+		default:
+			if j, err := json.Marshal(s); err == nil {
+				*d, ok = json.RawMessage(j), true
+			}
+		}
+	case *bool:
+		*d, ok = boolConvertHelper(src)
+	case *int:
+		*d, ok = intConvert[int](src)
+	case *int8:
+		*d, ok = intConvert[int8](src)
+	case *int16:
+		*d, ok = intConvert[int16](src)
+	case *int32:
+		*d, ok = intConvert[int32](src)
+	case *int64:
+		*d, ok = intConvert[int64](src)
+	case *uint:
+		*d, ok = intConvert[uint](src)
+	case *uint8:
+		*d, ok = intConvert[uint8](src)
+	case *uint16:
+		*d, ok = intConvert[uint16](src)
+	case *uint32:
+		*d, ok = intConvert[uint32](src)
+	case *uint64:
+		*d, ok = intConvert[uint64](src)
+	case *float32:
+		var f float64
+		f, ok = floatConvertHelper(src)
+		*d = float32(f)
+	case *float64:
+		*d, ok = floatConvertHelper(src)
+	case *time.Time:
+		*d, ok = timeConvertHelper(src)
+	}
+	if ok {
+		return nil
+	}
+
+	// nullable destination: **T
+	if elem.Kind() == reflect.Pointer {
+		v := reflect.New(elem.Type().Elem())
+		if err := assignValue(v.Interface(), src); err != nil {
+			return err
+		}
+		elem.Set(v)
+		return nil
+	}
+
+	sv := reflect.ValueOf(src)
+	if sv.Type().AssignableTo(elem.Type()) {
+		elem.Set(sv)
+		return nil
+	}
+
+	if a, ok := src.(valueAssigner); ok {
+		if err := a.AssignTo(dest); err == nil {
+			return nil
+		}
+	}
+
+	if s, ok := dest.(valueSetter); ok {
+		if err := s.Set(src); err == nil {
+			return nil
+		}
+	}
+
+	// numeric to string conversion produces runes, not digits
+	if sv.Type().ConvertibleTo(elem.Type()) && (elem.Kind() != reflect.String || sv.Kind() == reflect.String) {
+		elem.Set(sv.Convert(elem.Type()))
+		return nil
+	}
+
+	return fmt.Errorf("can't convert %T to %s", src, elem.Type())
+}
+
+// structField - struct field that receives a column value
+type structField struct {
+	column string
+	// index - field index path, nested structs may be reached through pointers
+	index []int
+}
+
+type structPlanKey struct {
+	t      reflect.Type
+	mapper FieldMapper
+}
+
+// structPlans - cache of struct fields by type and mapper: structPlanKey -> []structField
+var structPlans sync.Map
+
+// cachedStructFields - columns of the struct, cached if the mapper is comparable (pointer mappers always are)
+func cachedStructFields(t reflect.Type, mapper FieldMapper) []structField {
+	if !reflect.TypeOf(mapper).Comparable() {
+		return structFields(t, mapper, "", nil)
+	}
+
+	key := structPlanKey{t: t, mapper: mapper}
+	if fields, ok := structPlans.Load(key); ok {
+		return fields.([]structField)
+	}
+
+	fields := structFields(t, mapper, "", nil)
+	structPlans.Store(key, fields)
+	return fields
+}
+
+// structFields - columns of the struct resolved by the mapper. Nested structs receive columns with the mapper prefix,
+// fields of embedded structs are mapped as if they were declared in the outer struct
+func structFields(t reflect.Type, mapper FieldMapper, prefix string, index []int) []structField {
+	var res []structField
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		ft := f.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+
+		fieldIndex := append(append([]int{}, index...), i)
+
+		// embedded struct fields are promoted without a prefix. Unexported embedded pointers can't be allocated
+		if f.Anonymous && isNestedStruct(ft) && (f.IsExported() || f.Type.Kind() != reflect.Pointer) {
+			if mapper.ColumnName(f) != "" {
+				res = append(res, structFields(ft, mapper, prefix, fieldIndex)...)
+			}
+			continue
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+
+		if isNestedStruct(ft) {
+			if p, ok := mapper.Prefix(f); ok {
+				res = append(res, structFields(ft, mapper, prefix+p, fieldIndex)...)
+			}
+			continue
+		}
+
+		if name := mapper.ColumnName(f); name != "" {
+			res = append(res, structField{column: prefix + name, index: fieldIndex})
+		}
+	}
+
+	return res
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// isNestedStruct - struct whose fields are mapped to columns, rather than a single value type like time.Time
+func isNestedStruct(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || t == timeType {
+		return false
+	}
+
+	// pgtype values, scanners and other types that accept a whole value
+	p := reflect.PointerTo(t)
+	if _, ok := p.MethodByName("Set"); ok {
+		return false
+	}
+	if isScannerType(p) {
+		return false
+	}
+
+	return true
+}
+
+// fieldByIndex - struct field by index path. Nil pointers on the path are allocated if alloc is true,
+// otherwise an invalid value is returned
+func fieldByIndex(v reflect.Value, index []int, alloc bool) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// snakeCase - convert the Go field name into snake_case: UserID -> user_id
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		} else {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,107 @@
+package sqlq
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgtype"
+)
+
+type scanAddress struct {
+	City   string
+	Street string
+}
+
+type scanUser struct {
+	ID        int64
+	Name      string
+	Email     *string
+	CreatedAt time.Time
+	Address   scanAddress `db:"addr"`
+}
+
+// uncachedMapper - not comparable, so its struct fields are resolved for every row
+type uncachedMapper struct {
+	DefaultFieldMapper
+	_ []int
+}
+
+// scanUserRows - in-memory result with the columns of scanUser
+func scanUserRows(n int) *cachedResult {
+	columns := []string{"id", "name", "email", "created_at", "addr_city", "addr_street"}
+	oids := []uint32{pgtype.Int8OID, pgtype.TextOID, pgtype.TextOID, pgtype.TimestamptzOID, pgtype.TextOID, pgtype.TextOID}
+
+	res := &cachedResult{}
+	for i, c := range columns {
+		res.fields = append(res.fields, pgproto3.FieldDescription{Name: []byte(c), DataTypeOID: oids[i]})
+	}
+
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		res.values = append(res.values, []any{int64(i), "user", "user@example.com", created, "city", "street"})
+		res.raw = append(res.raw, make([][]byte, len(columns)))
+	}
+	return res
+}
+
+func TestCachedStructFields(t *testing.T) {
+	typ := reflect.TypeOf(scanUser{})
+	mapper := &DefaultFieldMapper{}
+
+	fields := cachedStructFields(typ, mapper)
+	if _, ok := structPlans.Load(structPlanKey{t: typ, mapper: mapper}); !ok {
+		t.Fatal("plan is not cached")
+	}
+	if again := cachedStructFields(typ, mapper); &again[0] != &fields[0] {
+		t.Error("cached plan is not reused")
+	}
+
+	var columns []string
+	for _, f := range fields {
+		columns = append(columns, f.column)
+	}
+	want := []string{"id", "name", "email", "created_at", "addr_city", "addr_street"}
+	if !reflect.DeepEqual(columns, want) {
+		t.Errorf("got %q, want %q", columns, want)
+	}
+
+	// another mapper has its own plan
+	tagged := &DefaultFieldMapper{Tag: "json"}
+	cachedStructFields(typ, tagged)
+	if _, ok := structPlans.Load(structPlanKey{t: typ, mapper: tagged}); !ok {
+		t.Error("plan of the second mapper is not cached")
+	}
+
+	// not comparable mappers are not cached
+	uncached := uncachedMapper{}
+	if got := cachedStructFields(typ, uncached); len(got) != len(fields) {
+		t.Errorf("uncached mapper: %d fields", len(got))
+	}
+}
+
+func BenchmarkScanStruct(b *testing.B) {
+	const rows = 100
+	res := scanUserRows(rows)
+
+	bench := func(b *testing.B, mapper FieldMapper) {
+		b.ReportAllocs()
+		start := time.Now()
+		for i := 0; i < b.N; i++ {
+			q := FromPgxRows(newCachedRows(res))
+			q.SetFieldMapper(mapper)
+
+			var u scanUser
+			for q.Next() {
+				if err := q.ScanStruct(&u); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+		b.ReportMetric(float64(b.N*rows)/time.Since(start).Seconds(), "rows/s")
+	}
+
+	b.Run("cached", func(b *testing.B) { bench(b, &DefaultFieldMapper{}) })
+	b.Run("uncached", func(b *testing.B) { bench(b, uncachedMapper{}) })
+}
@@ -0,0 +1,210 @@
+package sqlq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/n-r-w/nerr"
+)
+
+// ShadowStats - statistics of mirrored statements
+type ShadowStats struct {
+	// Mirrored - statements executed on the shadow
+	Mirrored int64
+	// Matched - statements with the same number of affected rows
+	Matched int64
+	// Mismatched - statements with a different number of affected rows
+	Mismatched int64
+	// Failed - statements that failed on the shadow
+	Failed int64
+	// Dropped - statements not mirrored because the queue was full
+	Dropped int64
+}
+
+// MatchRate - share of mirrored statements that matched the primary (0-1)
+func (s ShadowStats) MatchRate() float64 {
+	total := s.Matched + s.Mismatched + s.Failed
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Matched) / float64(total)
+}
+
+// ShadowOptions - ShadowWriter options
+type ShadowOptions struct {
+	// Match - statements to mirror. nil - all successful Exec statements
+	Match func(sql string) bool
+	// Rewrite - statement for the shadow, for example with the shadow table name. nil - as is
+	Rewrite func(sql string) string
+	// OnMismatch - called when the shadow result differs from the primary. err - shadow error, if any
+	OnMismatch func(sql string, primaryRows int64, shadowRows int64, err error)
+	// QueueSize - maximum number of pending statements. 0 - 1000
+	QueueSize int
+	// Workers - number of goroutines executing statements on the shadow. 0 - 1, which preserves the order of statements
+	Workers int
+}
+
+type shadowJob struct {
+	sql  string
+	args []any
+	rows int64
+}
+
+// ErrShadowClosed - the statement is executed with the ShadowWriter middleware after Close
+var ErrShadowClosed = errors.New("shadow writer is closed")
+
+// ShadowWriter - middleware that asynchronously mirrors write statements to a shadow database (or shadow tables
+// via Rewrite) and compares the number of affected rows, for gradual migrations. Mirroring never delays or fails
+// the primary statement. Statements executed in a transaction are buffered and mirrored only after the outermost
+// transaction commits, the statements of rolled back transactions and savepoints are not mirrored
+type ShadowWriter struct {
+	pool *pgxpool.Pool
+	opts ShadowOptions
+
+	// protects closing of the queue
+	mu     sync.RWMutex
+	closed bool
+	queue  chan shadowJob
+	wg     sync.WaitGroup
+
+	mirrored   int64
+	matched    int64
+	mismatched int64
+	failed     int64
+	dropped    int64
+}
+
+// NewShadowWriter - create a shadow writer executing statements on the pool. Call Close to stop it
+func NewShadowWriter(pool *pgxpool.Pool, opts ShadowOptions) *ShadowWriter {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1000
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+
+	w := &ShadowWriter{
+		pool:  pool,
+		opts:  opts,
+		queue: make(chan shadowJob, opts.QueueSize),
+	}
+
+	for i := 0; i < opts.Workers; i++ {
+		w.wg.Add(1)
+		go w.run()
+	}
+
+	return w
+}
+
+// Exec - implementation of Middleware. Returns ErrShadowClosed without executing the statement after Close
+func (w *ShadowWriter) Exec(next ExecFunc) ExecFunc {
+	return func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+		if w.isClosed() {
+			return nil, nerr.New(ErrShadowClosed)
+		}
+
+		tag, err := next(ctx, sql, args...)
+		if err != nil || (w.opts.Match != nil && !w.opts.Match(sql)) {
+			return tag, err
+		}
+
+		shadowSql := sql
+		if w.opts.Rewrite != nil {
+			shadowSql = w.opts.Rewrite(sql)
+		}
+		job := shadowJob{sql: shadowSql, args: args, rows: tag.RowsAffected()}
+
+		if tx := TxFromContext(ctx); tx != nil {
+			// the hooks of the rolled back levels are discarded
+			if tx.OnCommit(func() { w.enqueue(job) }) == nil {
+				return tag, err
+			}
+		}
+
+		w.enqueue(job)
+		return tag, err
+	}
+}
+
+// enqueue - queue the statement for the shadow, dropped if the queue is full or the writer is closed
+func (w *ShadowWriter) enqueue(job shadowJob) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		atomic.AddInt64(&w.dropped, 1)
+		return
+	}
+
+	select {
+	case w.queue <- job:
+	default:
+		atomic.AddInt64(&w.dropped, 1)
+	}
+}
+
+func (w *ShadowWriter) isClosed() bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.closed
+}
+
+// Query - implementation of Middleware. Selects are not mirrored
+func (w *ShadowWriter) Query(next QueryFunc) QueryFunc {
+	return next
+}
+
+// Stats - mirroring statistics
+func (w *ShadowWriter) Stats() ShadowStats {
+	return ShadowStats{
+		Mirrored:   atomic.LoadInt64(&w.mirrored),
+		Matched:    atomic.LoadInt64(&w.matched),
+		Mismatched: atomic.LoadInt64(&w.mismatched),
+		Failed:     atomic.LoadInt64(&w.failed),
+		Dropped:    atomic.LoadInt64(&w.dropped),
+	}
+}
+
+// Close - stop accepting statements and wait for the pending ones. The statements of the transactions
+// committed after Close are counted as dropped
+func (w *ShadowWriter) Close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	w.wg.Wait()
+}
+
+func (w *ShadowWriter) run() {
+	defer w.wg.Done()
+
+	for job := range w.queue {
+		q := NewQuery(w.pool, context.Background())
+		err := q.ExecArgs(job.sql, job.args...)
+		atomic.AddInt64(&w.mirrored, 1)
+
+		var rows int64
+		switch {
+		case err != nil:
+			atomic.AddInt64(&w.failed, 1)
+		case q.RowsAffected() != job.rows:
+			rows = q.RowsAffected()
+			atomic.AddInt64(&w.mismatched, 1)
+		default:
+			atomic.AddInt64(&w.matched, 1)
+			continue
+		}
+
+		if w.opts.OnMismatch != nil {
+			w.opts.OnMismatch(job.sql, job.rows, rows, err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package sqlq
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+func TestShadowWriterTransactions(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect("INSERT INTO t (a) VALUES (1)", sqlqtest.Result{Tag: "INSERT 0 1"})
+	srv.Expect("INSERT INTO t (a) VALUES (2)", sqlqtest.Result{Tag: "INSERT 0 1"})
+	srv.Expect("INSERT INTO shadow_t (a) VALUES (1)", sqlqtest.Result{Tag: "INSERT 0 1"})
+	srv.Expect("INSERT INTO shadow_t (a) VALUES (2)", sqlqtest.Result{Tag: "INSERT 0 1"})
+
+	w := NewShadowWriter(pool, ShadowOptions{
+		Rewrite: func(sql string) string { return strings.Replace(sql, " t ", " shadow_t ", 1) },
+	})
+
+	run := func(sql string, commit bool) {
+		tx := NewTx(pool, context.Background())
+		tx.Use(w)
+		if err := tx.Begin(); err != nil {
+			t.Fatal(err)
+		}
+		if err := NewQueryTx(tx, context.Background()).Exec(sql); err != nil {
+			t.Fatal(err)
+		}
+		var err error
+		if commit {
+			err = tx.Commit()
+		} else {
+			err = tx.Rollback()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run("INSERT INTO t (a) VALUES (1)", false)
+	run("INSERT INTO t (a) VALUES (2)", true)
+	w.Close()
+
+	var shadow []string
+	for _, q := range srv.Queries() {
+		if strings.Contains(q, "shadow_t") {
+			shadow = append(shadow, q)
+		}
+	}
+	if len(shadow) != 1 || shadow[0] != "INSERT INTO shadow_t (a) VALUES (2)" {
+		t.Fatalf("mirrored: %q", shadow)
+	}
+	if st := w.Stats(); st.Mirrored != 1 || st.Matched != 1 {
+		t.Errorf("stats: %+v", st)
+	}
+
+	q := NewQuery(pool, context.Background())
+	q.Use(w)
+	if err := q.Exec("INSERT INTO t (a) VALUES (1)"); !errors.Is(err, ErrShadowClosed) {
+		t.Errorf("expected ErrShadowClosed, got %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package sqlq
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+type sqlCommentKey struct{}
+
+// WithSqlComment - add the tag to the comments of the statements executed with the context by SqlCommenter:
+// WithSqlComment(ctx, "route", "/api/users")
+func WithSqlComment(ctx context.Context, key string, value string) context.Context {
+	tags := map[string]string{key: value}
+	for k, v := range sqlCommentsFromContext(ctx) {
+		if k != key {
+			tags[k] = v
+		}
+	}
+	return context.WithValue(ctx, sqlCommentKey{}, tags)
+}
+
+func sqlCommentsFromContext(ctx context.Context) map[string]string {
+	tags, _ := ctx.Value(sqlCommentKey{}).(map[string]string)
+	return tags
+}
+
+// SqlCommenter - middleware that appends a sqlcommenter comment to the statements:
+// SELECT ... /*request_id='42',route='%2Fapi%2Fusers',service='billing'*/.
+// The comment is visible in pg_stat_activity and the server log, so the statements can be correlated with the application endpoints.
+// Statements that already contain a comment are not changed.
+// Comments with per-request values make each statement text unique, the statement cache should not be used with them
+type SqlCommenter struct {
+	tags    map[string]string
+	tagFunc func(ctx context.Context) map[string]string
+}
+
+// NewSqlCommenter - create the middleware. tags - static tags (service, application etc.)
+func NewSqlCommenter(tags map[string]string) *SqlCommenter {
+	c := &SqlCommenter{tags: map[string]string{}}
+	for k, v := range tags {
+		c.tags[k] = v
+	}
+	return c
+}
+
+// SetTagFunc - tags taken from the context of the statement, e.g. the trace id of the tracing library.
+// They take precedence over the static tags, the tags of WithSqlComment take precedence over them
+func (c *SqlCommenter) SetTagFunc(f func(ctx context.Context) map[string]string) *SqlCommenter {
+	c.tagFunc = f
+	return c
+}
+
+// Exec - implementation of Middleware
+func (c *SqlCommenter) Exec(next ExecFunc) ExecFunc {
+	return func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+		return next(ctx, c.Comment(ctx, sql), args...)
+	}
+}
+
+// Query - implementation of Middleware
+func (c *SqlCommenter) Query(next QueryFunc) QueryFunc {
+	return func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+		return next(ctx, c.Comment(ctx, sql), args...)
+	}
+}
+
+// Comment - the statement with the comment of the tags of the context
+func (c *SqlCommenter) Comment(ctx context.Context, sql string) string {
+	if strings.Contains(sql, "/*") || strings.Contains(sql, "--") {
+		return sql
+	}
+
+	tags := make(map[string]string, len(c.tags))
+	for k, v := range c.tags {
+		tags[k] = v
+	}
+	if c.tagFunc != nil {
+		for k, v := range c.tagFunc(ctx) {
+			tags[k] = v
+		}
+	}
+	for k, v := range sqlCommentsFromContext(ctx) {
+		tags[k] = v
+	}
+
+	comment := sqlComment(tags)
+	if comment == "" {
+		return sql
+	}
+	return strings.TrimRight(sql, " \t\r\n;") + " " + comment
+}
+
+// sqlComment - the tags in the sqlcommenter format: keys are sorted, keys and values are url encoded,
+// values are quoted. Empty values are skipped
+func sqlComment(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k, v := range tags {
+		if k != "" && v != "" {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = sqlCommentEscape(k) + "='" + sqlCommentEscape(tags[k]) + "'"
+	}
+	return "/*" + strings.Join(pairs, ",") + "*/"
+}
+
+// sqlCommentEscape - url encoding, the result has no quotes and no comment terminators
+func sqlCommentEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
@@ -0,0 +1,56 @@
+package sqlq
+
+import (
+	"context"
+	"testing"
+
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+func TestSqlCommenterComment(t *testing.T) {
+	c := NewSqlCommenter(map[string]string{"service": "billing", "route": "static", "empty": ""}).
+		SetTagFunc(func(ctx context.Context) map[string]string {
+			return map[string]string{"trace": "t-1", "route": "func"}
+		})
+	ctx := WithSqlComment(WithSqlComment(context.Background(), "route", "/api/users"), "user", "o'neil */")
+
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{
+			name: "tags",
+			sql:  "SELECT 1;\n",
+			want: `SELECT 1 /*route='%2Fapi%2Fusers',service='billing',trace='t-1',user='o%27neil%20%2A%2F'*/`,
+		},
+		{"block comment", "SELECT 1 /* own */", "SELECT 1 /* own */"},
+		{"line comment", "SELECT 1 -- own", "SELECT 1 -- own"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Comment(ctx, tt.sql); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// no tags
+	if got := NewSqlCommenter(nil).Comment(context.Background(), "SELECT 1"); got != "SELECT 1" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestSqlCommenterMiddleware(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect("UPDATE t SET a = 1 /*service='billing'*/", sqlqtest.Result{Tag: "UPDATE 1"})
+
+	q := NewQuery(pool, context.Background())
+	defer q.Release()
+	q.Use(NewSqlCommenter(map[string]string{"service": "billing"}))
+
+	if err := q.Exec("UPDATE t SET a = 1"); err != nil {
+		t.Fatal(err)
+	}
+}
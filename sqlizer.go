@@ -0,0 +1,94 @@
+package sqlq
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/n-r-w/nerr"
+)
+
+// Sqlizer - external statement builder (squirrel, goqu etc.). The statement is executed with parameters
+// through the same path as SelectArgs/ExecArgs. ? placeholders (squirrel default format) are converted to $n,
+// unless the statement already uses $n placeholders. Placeholders inside literals and comments are ignored
+type Sqlizer interface {
+	ToSql() (string, []any, error)
+}
+
+// ToSql - implementation of Sqlizer
+func (b *QueryBuilder) ToSql() (string, []any, error) {
+	return b.Sql()
+}
+
+// sqlizerSql - sql and arguments of the builder with $n placeholders
+func sqlizerSql(b Sqlizer) (string, []any, error) {
+	sql, args, err := b.ToSql()
+	if err != nil {
+		return "", nil, nerr.New(err)
+	}
+
+	if len(args) == 0 {
+		return sql, args, nil
+	}
+
+	dollar, err := hasDollarPlaceholders(sql)
+	if err != nil {
+		return "", nil, nerr.New(err)
+	}
+	if !dollar {
+		if sql, _, err = builderPlaceholders(sql, 0); err != nil {
+			return "", nil, nerr.New(err)
+		}
+	}
+
+	return sql, args, nil
+}
+
+// SelectSqlizer - executing the select command of the external builder
+func (q *Query) SelectSqlizer(b Sqlizer) error {
+	sql, args, err := sqlizerSql(b)
+	if err != nil {
+		return err
+	}
+	return q.SelectArgs(sql, args...)
+}
+
+// ExecSqlizer - execution of the insert, update, delete command of the external builder
+func (q *Query) ExecSqlizer(b Sqlizer) error {
+	sql, args, err := sqlizerSql(b)
+	if err != nil {
+		return err
+	}
+	return q.ExecArgs(sql, args...)
+}
+
+func SelectSqlizer(pool *pgxpool.Pool, ctx context.Context, b Sqlizer) (*Query, error) {
+	q := NewQuery(pool, ctx)
+	if err := q.SelectSqlizer(b); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func SelectTxSqlizer(tx *Tx, b Sqlizer) (*Query, error) {
+	q := NewQueryTx(tx, tx.ctx)
+	if err := q.SelectSqlizer(b); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func ExecSqlizer(pool *pgxpool.Pool, ctx context.Context, b Sqlizer) (*Query, error) {
+	q := NewQuery(pool, ctx)
+	if err := q.ExecSqlizer(b); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func ExecTxSqlizer(tx *Tx, b Sqlizer) (*Query, error) {
+	q := NewQueryTx(tx, tx.ctx)
+	if err := q.ExecSqlizer(b); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
@@ -0,0 +1,36 @@
+package sqlq
+
+import "testing"
+
+type testSqlizer struct {
+	sql  string
+	args []any
+}
+
+func (s testSqlizer) ToSql() (string, []any, error) {
+	return s.sql, s.args, nil
+}
+
+func TestSqlizerSqlPlaceholders(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"SELECT * FROM t WHERE a = ? AND b = ?", "SELECT * FROM t WHERE a = $1 AND b = $2"},
+		{"SELECT * FROM t WHERE a = $1 AND b = $2", "SELECT * FROM t WHERE a = $1 AND b = $2"},
+		{"SELECT '$1', a FROM t WHERE b = ?", "SELECT '$1', a FROM t WHERE b = $1"},
+		{"SELECT a FROM t /* $1 ? */ WHERE b = ? -- $1 ?", "SELECT a FROM t /* $1 ? */ WHERE b = $1 -- $1 ?"},
+		{"SELECT $x$ $1 ? $x$, a$1 FROM t WHERE b = ?", "SELECT $x$ $1 ? $x$, a$1 FROM t WHERE b = $1"},
+		{`SELECT "?" FROM t WHERE data ?? 'k' AND b = ?`, `SELECT "?" FROM t WHERE data ? 'k' AND b = $1`},
+	}
+
+	for _, tt := range tests {
+		got, _, err := sqlizerSql(testSqlizer{sql: tt.sql, args: []any{1}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package sqlqgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+const sqlqImport = "github.com/n-r-w/sqlq"
+
+// Generate - Go source with the functions of the queries
+func Generate(pkg string, queries []*QueryDef) ([]byte, error) {
+	names := make(map[string]bool)
+	imports := map[string]bool{sqlqImport: true}
+
+	data := make([]genQuery, len(queries))
+	for i, d := range queries {
+		if names[d.Name] {
+			return nil, fmt.Errorf("%s:%d: duplicate query name %s", d.File, d.Line, d.Name)
+		}
+		names[d.Name] = true
+
+		for _, p := range d.Imports {
+			imports[p] = true
+		}
+		for _, f := range append(append([]Field{}, d.Params...), d.Columns...) {
+			if strings.Contains(f.Type, "time.") {
+				imports["time"] = true
+			}
+			if strings.Contains(f.Type, "json.") {
+				imports["encoding/json"] = true
+			}
+		}
+
+		data[i] = newGenQuery(d)
+	}
+
+	// standard library first
+	importList := make([]string, 0, len(imports))
+	for p := range imports {
+		importList = append(importList, p)
+	}
+	sort.Slice(importList, func(i, j int) bool {
+		si, sj := isStdImport(importList[i]), isStdImport(importList[j])
+		if si != sj {
+			return si
+		}
+		return importList[i] < importList[j]
+	})
+
+	var buf bytes.Buffer
+	err := fileTemplate.Execute(&buf, map[string]any{
+		"Package": pkg,
+		"Imports": importList,
+		"Queries": data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w", err)
+	}
+	return src, nil
+}
+
+func isStdImport(path string) bool {
+	return !strings.Contains(strings.SplitN(path, "/", 2)[0], ".")
+}
+
+type genField struct {
+	Name string
+	Type string
+}
+
+type genQuery struct {
+	*QueryDef
+	SqlConst string
+	SqlText  string
+	Row      string
+	Fields   []genField
+	Args     []genField
+}
+
+func newGenQuery(d *QueryDef) genQuery {
+	g := genQuery{
+		QueryDef: d,
+		SqlConst: lowerFirst(d.Name) + "Sql",
+		SqlText:  quoteSql(d.Sql),
+		Row:      d.Name + "Row",
+	}
+
+	for _, f := range d.Columns {
+		g.Fields = append(g.Fields, genField{Name: goName(f.Name), Type: f.Type})
+	}
+	for _, f := range d.Params {
+		g.Args = append(g.Args, genField{Name: argName(f.Name), Type: f.Type})
+	}
+	return g
+}
+
+// quoteSql - raw string literal if possible
+func quoteSql(sql string) string {
+	if !strings.Contains(sql, "`") {
+		return "`" + sql + "`"
+	}
+	return strconv.Quote(sql)
+}
+
+// common initialisms in Go names
+var initialisms = map[string]string{
+	"id":   "ID",
+	"url":  "URL",
+	"uuid": "UUID",
+	"json": "JSON",
+	"api":  "API",
+	"ip":   "IP",
+	"sql":  "SQL",
+	"http": "HTTP",
+}
+
+// goName - exported Go name of the snake_case name
+func goName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		if part == "" {
+			continue
+		}
+		if s, ok := initialisms[strings.ToLower(part)]; ok {
+			b.WriteString(s)
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+
+	res := b.String()
+	if res == "" || (res[0] >= '0' && res[0] <= '9') {
+		res = "F" + res
+	}
+	return res
+}
+
+// argName - unexported Go name of the parameter
+func argName(name string) string {
+	res := lowerFirst(goName(name))
+	if token.IsKeyword(res) || res == "q" {
+		res += "_"
+	}
+	return res
+}
+
+func lowerFirst(s string) string {
+	// initialism at the beginning: ID -> id, URLPath -> urlPath
+	n := 0
+	for n < len(s) && s[n] >= 'A' && s[n] <= 'Z' {
+		n++
+	}
+	switch {
+	case n == 0:
+		return s
+	case n == 1 || n == len(s):
+		return strings.ToLower(s[:n]) + s[n:]
+	default:
+		return strings.ToLower(s[:n-1]) + s[n-1:]
+	}
+}
+
+var fileTemplate = template.Must(template.New("file").Funcs(template.FuncMap{
+	"std": isStdImport,
+	"dec": func(i int) int { return i - 1 },
+}).Parse(`// Code generated by sqlq-gen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- range $i, $p := .Imports}}
+{{- if and $i (not (std $p)) (std (index $.Imports (dec $i)))}}
+{{end}}
+	"{{$p}}"
+{{- end}}
+)
+{{range .Queries}}
+const {{.SqlConst}} = {{.SqlText}}
+{{if ne .Kind ":exec"}}
+// {{.Row}} - row of {{.Name}}
+type {{.Row}} struct {
+{{- range .Fields}}
+	{{.Name}} {{.Type}}
+{{- end}}
+}
+{{end}}
+{{- if eq .Kind ":one"}}
+// {{.Name}} - first row of the query, false - no rows
+func {{.Name}}(q *sqlq.Query{{range .Args}}, {{.Name}} {{.Type}}{{end}}) ({{.Row}}, bool, error) {
+	var row {{.Row}}
+	if err := q.SelectArgs({{.SqlConst}}{{range .Args}}, {{.Name}}{{end}}); err != nil {
+		return row, false, err
+	}
+	defer q.Close()
+
+	if !q.Next() {
+		return row, false, q.Close()
+	}
+	if err := q.Scan({{range $i, $f := .Fields}}{{if $i}}, {{end}}&row.{{$f.Name}}{{end}}); err != nil {
+		return row, false, err
+	}
+	return row, true, q.Close()
+}
+{{else if eq .Kind ":many"}}
+// {{.Name}} - rows of the query
+func {{.Name}}(q *sqlq.Query{{range .Args}}, {{.Name}} {{.Type}}{{end}}) ([]{{.Row}}, error) {
+	if err := q.SelectArgs({{.SqlConst}}{{range .Args}}, {{.Name}}{{end}}); err != nil {
+		return nil, err
+	}
+	defer q.Close()
+
+	var rows []{{.Row}}
+	for q.Next() {
+		var row {{.Row}}
+		if err := q.Scan({{range $i, $f := .Fields}}{{if $i}}, {{end}}&row.{{$f.Name}}{{end}}); err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, q.Close()
+}
+{{else}}
+// {{.Name}} - execute the query, returns the number of affected rows
+func {{.Name}}(q *sqlq.Query{{range .Args}}, {{.Name}} {{.Type}}{{end}}) (int64, error) {
+	if err := q.ExecArgs({{.SqlConst}}{{range .Args}}, {{.Name}}{{end}}); err != nil {
+		return 0, err
+	}
+	return q.RowsAffected(), nil
+}
+{{end}}
+{{- end}}`))
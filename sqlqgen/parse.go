@@ -0,0 +1,199 @@
+// Package sqlqgen - generation of typed Go functions on top of sqlq.Query from annotated sql files.
+//
+// Each query in a file starts with the annotations:
+//
+//	-- name: GetUser :one
+//	-- param: id int64
+//	-- column: id int64
+//	-- column: name string
+//	-- column: created_at time.Time
+//	SELECT id, name, created_at FROM users WHERE id = $1;
+//
+// Kinds: :one - first row and a found flag, :many - slice of rows, :exec - number of affected rows.
+// Params are $1, $2... in the order of declaration, columns are scanned by position.
+// "-- import: path" adds an import for the types of other packages (time and encoding/json are added automatically)
+package sqlqgen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/n-r-w/sqlq"
+)
+
+// Kind - result kind of the generated function
+type Kind string
+
+const (
+	KindOne  Kind = ":one"
+	KindMany Kind = ":many"
+	KindExec Kind = ":exec"
+)
+
+// Field - name and Go type of a parameter or column
+type Field struct {
+	Name string
+	Type string
+}
+
+// QueryDef - annotated query
+type QueryDef struct {
+	Name    string
+	Kind    Kind
+	Params  []Field
+	Columns []Field
+	Imports []string
+	Sql     string
+	// source position for errors
+	File string
+	Line int
+}
+
+var (
+	annotationRe = regexp.MustCompile(`^--\s*(name|param|column|import)\s*:\s*(.*)$`)
+	identRe      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Parse - read the queries from the sql file
+func Parse(file string, r io.Reader) ([]*QueryDef, error) {
+	var (
+		res     []*QueryDef
+		current *QueryDef
+		body    []string
+	)
+
+	finish := func() error {
+		if current == nil {
+			return nil
+		}
+		current.Sql = strings.TrimSuffix(strings.TrimSpace(strings.Join(body, "\n")), ";")
+		if err := current.validate(); err != nil {
+			return err
+		}
+		res = append(res, current)
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+
+		m := annotationRe.FindStringSubmatch(strings.TrimSpace(text))
+		if m == nil {
+			if current != nil {
+				body = append(body, text)
+			} else if strings.TrimSpace(text) != "" && !strings.HasPrefix(strings.TrimSpace(text), "--") {
+				return nil, fmt.Errorf("%s:%d: sql without -- name annotation", file, line)
+			}
+			continue
+		}
+
+		value := strings.Fields(m[2])
+		if m[1] == "name" {
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			if len(value) != 2 {
+				return nil, fmt.Errorf("%s:%d: expected -- name: <Name> <:one|:many|:exec>", file, line)
+			}
+			current = &QueryDef{
+				Name: value[0],
+				Kind: Kind(value[1]),
+				File: file,
+				Line: line,
+			}
+			body = nil
+			continue
+		}
+
+		if current == nil {
+			return nil, fmt.Errorf("%s:%d: annotation before -- name", file, line)
+		}
+
+		switch m[1] {
+		case "param", "column":
+			if len(value) != 2 {
+				return nil, fmt.Errorf("%s:%d: expected -- %s: <name> <type>", file, line, m[1])
+			}
+			f := Field{Name: value[0], Type: value[1]}
+			if m[1] == "param" {
+				current.Params = append(current.Params, f)
+			} else {
+				current.Columns = append(current.Columns, f)
+			}
+
+		case "import":
+			if len(value) != 1 {
+				return nil, fmt.Errorf("%s:%d: expected -- import: <path>", file, line)
+			}
+			path, err := strconv.Unquote(value[0])
+			if err != nil {
+				path = value[0]
+			}
+			current.Imports = append(current.Imports, path)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := finish(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (d *QueryDef) validate() error {
+	pos := fmt.Sprintf("%s:%d", d.File, d.Line)
+
+	if !identRe.MatchString(d.Name) {
+		return fmt.Errorf("%s: invalid query name %s", pos, d.Name)
+	}
+
+	switch d.Kind {
+	case KindOne, KindMany:
+		if len(d.Columns) == 0 {
+			return fmt.Errorf("%s: %s query %s has no columns", pos, d.Kind, d.Name)
+		}
+	case KindExec:
+		if len(d.Columns) > 0 {
+			return fmt.Errorf("%s: %s query %s can't have columns", pos, d.Kind, d.Name)
+		}
+	default:
+		return fmt.Errorf("%s: unknown kind %s of query %s", pos, d.Kind, d.Name)
+	}
+
+	if d.Sql == "" {
+		return fmt.Errorf("%s: query %s has no sql", pos, d.Name)
+	}
+
+	n, err := sqlq.ParamCount(d.Sql)
+	if err != nil {
+		return fmt.Errorf("%s: query %s: %w", pos, d.Name, err)
+	}
+	if n != len(d.Params) {
+		return fmt.Errorf("%s: query %s uses %d parameters, %d declared", pos, d.Name, n, len(d.Params))
+	}
+
+	seen := make(map[string]bool)
+	for _, f := range append(append([]Field{}, d.Params...), d.Columns...) {
+		if !identRe.MatchString(f.Name) {
+			return fmt.Errorf("%s: invalid name %s in query %s", pos, f.Name, d.Name)
+		}
+	}
+	for _, f := range d.Columns {
+		name := goName(f.Name)
+		if seen[name] {
+			return fmt.Errorf("%s: duplicate column %s in query %s", pos, f.Name, d.Name)
+		}
+		seen[name] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package sqlqgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// parseTestdata - queries of the sql files of testdata in the order of the file names
+func parseTestdata(t *testing.T) []*QueryDef {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join("testdata", "*.sql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var res []*QueryDef
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defs, err := Parse(filepath.ToSlash(file), bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res = append(res, defs...)
+	}
+	return res
+}
+
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs, run go test -update:\n%s", name, got)
+	}
+}
+
+func TestParseGolden(t *testing.T) {
+	got, err := json.MarshalIndent(parseTestdata(t), "", "\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "parse.golden", append(got, '\n'))
+}
+
+func TestGenerateGolden(t *testing.T) {
+	got, err := Generate("queries", parseTestdata(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "queries.gen.go.golden", got)
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		err  string
+	}{
+		{"no name", "SELECT 1", "sql without -- name annotation"},
+		{"unknown kind", "-- name: A :all\nSELECT 1", "unknown kind"},
+		{"no columns", "-- name: A :one\nSELECT 1", "has no columns"},
+		{"exec columns", "-- name: A :exec\n-- column: a int\nDELETE FROM t", "can't have columns"},
+		{"undeclared param", "-- name: A :exec\nDELETE FROM t WHERE id = $1", "uses 1 parameters, 0 declared"},
+		{"quoted param", "-- name: A :exec\n-- param: id int64\nDELETE FROM t WHERE a = '$2' AND id = $1", ""},
+		{"unterminated literal", "-- name: A :exec\nDELETE FROM t WHERE a = 'x", "unterminated string literal"},
+		{"duplicate column", "-- name: A :many\n-- column: a_b int\n-- column: aB int\nSELECT 1, 2", "duplicate column"},
+		{"invalid name", "-- name: 1A :exec\nDELETE FROM t", "invalid query name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse("test.sql", strings.NewReader(tt.sql))
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestGenerateDuplicateName(t *testing.T) {
+	defs := []*QueryDef{
+		{Name: "A", Kind: KindExec, Sql: "DELETE FROM t"},
+		{Name: "A", Kind: KindExec, Sql: "DELETE FROM t"},
+	}
+	if _, err := Generate("queries", defs); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,6 @@
+-- name: ListEvents :many
+-- import: github.com/google/uuid
+-- param: user_id int64
+-- column: id uuid.UUID
+-- column: payload json.RawMessage
+SELECT id, payload FROM events WHERE user_id = $1 AND kind <> E'\'$2';
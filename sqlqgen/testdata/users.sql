@@ -0,0 +1,23 @@
+-- name: GetUser :one
+-- param: id int64
+-- column: id int64
+-- column: name string
+-- column: created_at time.Time
+SELECT id, name, created_at FROM users WHERE id = $1;
+
+-- name: ListUsers :many
+-- param: name_prefix string
+-- param: limit int32
+-- column: id int64
+-- column: name string
+-- column: price_label string
+-- the literal, the comment and the dollar quoted text are not parameters: $3
+SELECT id, name, 'costs $5' AS price_label
+FROM users
+WHERE name LIKE $1 || '%' /* $4 */ AND note <> $$ $6 $$
+ORDER BY id
+LIMIT $2;
+
+-- name: DeleteUser :exec
+-- param: id int64
+DELETE FROM users WHERE id = $1;
@@ -0,0 +1,865 @@
+// Package sqlqtest - testing helpers for sqlq
+package sqlqtest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgtype"
+)
+
+// Column - column of the scripted result. OID 0 - the type is taken from the first not nil value of the column
+type Column struct {
+	Name string
+	OID  uint32
+}
+
+// Result - scripted result of the statement
+type Result struct {
+	Columns []Column
+	Rows    [][]any
+	// command tag, "SELECT <number of rows>" by default
+	Tag string
+	// parameter types for the extended protocol. By default json: pgx encodes values of any type into it,
+	// parameter values are not used by the server
+	Params []uint32
+	// error returned instead of the result
+	Err *pgconn.PgError
+	// data sent for COPY ... TO STDOUT
+	CopyData []byte
+}
+
+// Handler - dynamic result of the statement, see FakePostgres.Handle. args - decoded parameter values of
+// the extended protocol, nil when the statement is prepared or described: the result must have the same Columns
+// and Params then. For COPY FROM STDIN args contains the received data ([]byte). false - the statement is not handled
+type Handler func(sql string, args []any) (Result, bool)
+
+// FakePostgres - server that speaks enough of the PostgreSQL wire protocol to serve scripted results to pgx/pgxpool.
+// Statements are matched by the text with collapsed whitespace. Transaction control statements (BEGIN, COMMIT, ROLLBACK,
+// SAVEPOINT, RELEASE, SET, RESET) are answered automatically, any other unexpected statement returns an error.
+// COPY FROM STDIN and COPY TO STDOUT are supported by the simple protocol
+type FakePostgres struct {
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu       sync.Mutex
+	results  map[string]Result
+	handlers []Handler
+	queries  []string
+	conns    map[net.Conn]struct{}
+	closed   bool
+}
+
+// NewFakePostgres - start the server on a random local port
+func NewFakePostgres() (*FakePostgres, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	f := &FakePostgres{
+		ln:      ln,
+		results: make(map[string]Result),
+		conns:   make(map[net.Conn]struct{}),
+	}
+
+	f.wg.Add(1)
+	go f.accept()
+
+	return f, nil
+}
+
+// ConnString - connection string for pgx.Connect/pgxpool.Connect
+func (f *FakePostgres) ConnString() string {
+	return fmt.Sprintf("postgres://fake@%s/fake?sslmode=disable", f.ln.Addr().String())
+}
+
+// Expect - register the result of the statement
+func (f *FakePostgres) Expect(sql string, res Result) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.results[normalizeSql(sql)] = res
+}
+
+// Handle - register the handler of the statements without a scripted result. Handlers are called in the order
+// of registration until one of them handles the statement
+func (f *FakePostgres) Handle(h Handler) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.handlers = append(f.handlers, h)
+}
+
+// ExpectError - register the error of the statement
+func (f *FakePostgres) ExpectError(sql string, code string, message string) {
+	f.Expect(sql, Result{Err: &pgconn.PgError{Severity: "ERROR", Code: code, Message: message}})
+}
+
+// Queries - executed statements in order
+func (f *FakePostgres) Queries() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	res := make([]string, len(f.queries))
+	copy(res, f.queries)
+	return res
+}
+
+// Close - stop the server and close all connections
+func (f *FakePostgres) Close() error {
+	f.mu.Lock()
+	f.closed = true
+	for c := range f.conns {
+		_ = c.Close()
+	}
+	f.mu.Unlock()
+
+	err := f.ln.Close()
+	f.wg.Wait()
+	return err
+}
+
+func (f *FakePostgres) accept() {
+	defer f.wg.Done()
+
+	for {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		f.mu.Lock()
+		if f.closed {
+			f.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		f.conns[conn] = struct{}{}
+		f.mu.Unlock()
+
+		f.wg.Add(1)
+		go func() {
+			defer f.wg.Done()
+			defer func() {
+				f.mu.Lock()
+				delete(f.conns, conn)
+				f.mu.Unlock()
+				_ = conn.Close()
+			}()
+
+			s := newFakeSession(f, conn)
+			_ = s.serve()
+		}()
+	}
+}
+
+// lookup - registered result of the statement or the result of the first handler that handles it
+func (f *FakePostgres) lookup(sql string, args []any) (Result, bool) {
+	f.mu.Lock()
+	res, ok := f.results[normalizeSql(sql)]
+	handlers := f.handlers
+	f.mu.Unlock()
+
+	if ok {
+		return res, true
+	}
+	for _, h := range handlers {
+		if res, ok := h(sql, args); ok {
+			return res, true
+		}
+	}
+	return Result{}, false
+}
+
+func (f *FakePostgres) record(sql string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.queries = append(f.queries, sql)
+}
+
+type fakeStatement struct {
+	sql    string
+	params []uint32
+}
+
+type fakePortal struct {
+	stmt    *fakeStatement
+	formats []int16
+	args    []any
+}
+
+// fakeSession - single client connection
+type fakeSession struct {
+	f        *FakePostgres
+	w        *bufio.Writer
+	backend  *pgproto3.Backend
+	ci       *pgtype.ConnInfo
+	txStatus byte
+	// error in the extended protocol: messages are skipped until Sync
+	failed     bool
+	statements map[string]*fakeStatement
+	portals    map[string]*fakePortal
+}
+
+func newFakeSession(f *FakePostgres, conn net.Conn) *fakeSession {
+	w := bufio.NewWriter(conn)
+	return &fakeSession{
+		f:          f,
+		w:          w,
+		backend:    pgproto3.NewBackend(pgproto3.NewChunkReader(conn), w),
+		ci:         pgtype.NewConnInfo(),
+		txStatus:   'I',
+		statements: make(map[string]*fakeStatement),
+		portals:    make(map[string]*fakePortal),
+	}
+}
+
+func (s *fakeSession) serve() error {
+	if ok, err := s.startup(); err != nil || !ok {
+		return err
+	}
+
+	for {
+		msg, err := s.backend.Receive()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		if _, ok := msg.(*pgproto3.Terminate); ok {
+			return nil
+		}
+
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// startup - handshake, false - connection should be closed
+func (s *fakeSession) startup() (bool, error) {
+	for {
+		msg, err := s.backend.ReceiveStartupMessage()
+		if err != nil {
+			return false, err
+		}
+
+		switch msg.(type) {
+		case *pgproto3.SSLRequest, *pgproto3.GSSEncRequest:
+			// encryption is not supported
+			if err := s.w.WriteByte('N'); err != nil {
+				return false, err
+			}
+			if err := s.w.Flush(); err != nil {
+				return false, err
+			}
+
+		case *pgproto3.StartupMessage:
+			s.send(&pgproto3.AuthenticationOk{})
+			for name, value := range map[string]string{
+				"server_version":              "14.0",
+				"server_encoding":             "UTF8",
+				"client_encoding":             "UTF8",
+				"DateStyle":                   "ISO, MDY",
+				"TimeZone":                    "UTC",
+				"integer_datetimes":           "on",
+				"standard_conforming_strings": "on",
+			} {
+				s.send(&pgproto3.ParameterStatus{Name: name, Value: value})
+			}
+			s.send(&pgproto3.BackendKeyData{ProcessID: 1, SecretKey: 1})
+			s.send(&pgproto3.ReadyForQuery{TxStatus: s.txStatus})
+			return true, s.w.Flush()
+
+		default:
+			// CancelRequest
+			return false, nil
+		}
+	}
+}
+
+func (s *fakeSession) send(msg pgproto3.BackendMessage) {
+	// write errors are returned by Flush
+	_ = s.backend.Send(msg)
+}
+
+func (s *fakeSession) handle(msg pgproto3.FrontendMessage) error {
+	switch m := msg.(type) {
+	case *pgproto3.Query:
+		if isCopy(m.String, "FROM STDIN") {
+			if err := s.copyIn(m.String); err != nil {
+				return err
+			}
+		} else {
+			s.simpleQuery(m.String)
+		}
+		s.send(&pgproto3.ReadyForQuery{TxStatus: s.txStatus})
+		return s.w.Flush()
+
+	case *pgproto3.Sync:
+		s.failed = false
+		s.send(&pgproto3.ReadyForQuery{TxStatus: s.txStatus})
+		return s.w.Flush()
+
+	case *pgproto3.Flush:
+		return s.w.Flush()
+	}
+
+	if s.failed {
+		return nil
+	}
+
+	switch m := msg.(type) {
+	case *pgproto3.Parse:
+		s.parse(m)
+
+	case *pgproto3.Describe:
+		s.describe(m)
+
+	case *pgproto3.Bind:
+		s.bind(m)
+
+	case *pgproto3.Execute:
+		s.execute(m)
+
+	case *pgproto3.Close:
+		if m.ObjectType == 'S' {
+			delete(s.statements, m.Name)
+		} else {
+			delete(s.portals, m.Name)
+		}
+		s.send(&pgproto3.CloseComplete{})
+
+	default:
+		s.fail(&pgconn.PgError{Code: "08P01", Message: fmt.Sprintf("unsupported message %T", msg)})
+	}
+
+	return nil
+}
+
+func (s *fakeSession) simpleQuery(sql string) {
+	if strings.TrimSpace(sql) == "" {
+		s.send(&pgproto3.EmptyQueryResponse{})
+		return
+	}
+
+	res, pgErr := s.run(sql, []any{})
+	if pgErr != nil {
+		s.sendError(pgErr)
+		return
+	}
+
+	if isCopy(sql, "TO STDOUT") {
+		s.copyOut(res)
+		return
+	}
+
+	rows, pgErr := s.encodeRows(res, nil)
+	if pgErr != nil {
+		s.sendError(pgErr)
+		return
+	}
+
+	if len(res.Columns) > 0 {
+		s.send(s.rowDescription(res, nil))
+	}
+	s.sendRows(res, rows)
+}
+
+// copyIn - COPY FROM STDIN: the data is read until CopyDone, the command tag is "COPY <number of rows>" by default
+func (s *fakeSession) copyIn(sql string) error {
+	var format byte
+	if strings.Contains(strings.ToUpper(sql), "BINARY") {
+		format = 1
+	}
+	s.send(&pgproto3.CopyInResponse{OverallFormat: format})
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+
+	var data []byte
+	for {
+		msg, err := s.backend.Receive()
+		if err != nil {
+			return err
+		}
+
+		switch m := msg.(type) {
+		case *pgproto3.CopyData:
+			data = append(data, m.Data...)
+
+		case *pgproto3.CopyFail:
+			s.f.record(sql)
+			if s.txStatus == 'T' {
+				s.txStatus = 'E'
+			}
+			s.sendError(&pgconn.PgError{Code: "57014", Message: "COPY from stdin failed: " + m.Message})
+			return nil
+
+		case *pgproto3.CopyDone:
+			res, pgErr := s.run(sql, []any{data})
+			if pgErr != nil {
+				s.sendError(pgErr)
+				return nil
+			}
+			s.sendCopyComplete(res, data)
+			return nil
+
+		default:
+			// Flush and Sync are ignored during COPY
+		}
+	}
+}
+
+// copyOut - COPY TO STDOUT: Result.CopyData is sent in one message
+func (s *fakeSession) copyOut(res Result) {
+	var format byte
+	if bytes.HasPrefix(res.CopyData, copySignature) {
+		format = 1
+	}
+	s.send(&pgproto3.CopyOutResponse{OverallFormat: format})
+	if len(res.CopyData) > 0 {
+		s.send(&pgproto3.CopyData{Data: res.CopyData})
+	}
+	s.send(&pgproto3.CopyDone{})
+	s.sendCopyComplete(res, res.CopyData)
+}
+
+func (s *fakeSession) sendCopyComplete(res Result, data []byte) {
+	tag := res.Tag
+	if tag == "" {
+		n, err := copyRowCount(data)
+		if err != nil {
+			s.sendError(&pgconn.PgError{Code: "22P04", Message: fmt.Sprintf("fake postgres: %v", err)})
+			return
+		}
+		tag = "COPY " + strconv.Itoa(n)
+	}
+	s.send(&pgproto3.CommandComplete{CommandTag: []byte(tag)})
+}
+
+func (s *fakeSession) parse(m *pgproto3.Parse) {
+	stmt := &fakeStatement{
+		sql:    m.Query,
+		params: make([]uint32, paramCount(m.Query)),
+	}
+
+	res, _ := s.f.lookup(m.Query, nil)
+	for i := range stmt.params {
+		switch {
+		case i < len(m.ParameterOIDs) && m.ParameterOIDs[i] != 0:
+			stmt.params[i] = m.ParameterOIDs[i]
+		case i < len(res.Params) && res.Params[i] != 0:
+			stmt.params[i] = res.Params[i]
+		default:
+			stmt.params[i] = pgtype.JSONOID
+		}
+	}
+
+	s.statements[m.Name] = stmt
+	s.send(&pgproto3.ParseComplete{})
+}
+
+func (s *fakeSession) describe(m *pgproto3.Describe) {
+	var (
+		stmt    *fakeStatement
+		formats []int16
+	)
+
+	if m.ObjectType == 'S' {
+		stmt = s.statements[m.Name]
+		if stmt == nil {
+			s.fail(&pgconn.PgError{Code: "26000", Message: fmt.Sprintf("prepared statement \"%s\" does not exist", m.Name)})
+			return
+		}
+		s.send(&pgproto3.ParameterDescription{ParameterOIDs: stmt.params})
+	} else {
+		portal := s.portals[m.Name]
+		if portal == nil {
+			s.fail(&pgconn.PgError{Code: "34000", Message: fmt.Sprintf("portal \"%s\" does not exist", m.Name)})
+			return
+		}
+		stmt = portal.stmt
+		formats = portal.formats
+	}
+
+	res, _ := s.f.lookup(stmt.sql, nil)
+	if len(res.Columns) == 0 || res.Err != nil {
+		s.send(&pgproto3.NoData{})
+		return
+	}
+	s.send(s.rowDescription(res, formats))
+}
+
+func (s *fakeSession) bind(m *pgproto3.Bind) {
+	stmt := s.statements[m.PreparedStatement]
+	if stmt == nil {
+		s.fail(&pgconn.PgError{Code: "26000", Message: fmt.Sprintf("prepared statement \"%s\" does not exist", m.PreparedStatement)})
+		return
+	}
+
+	args, err := s.decodeArgs(stmt, m)
+	if err != nil {
+		s.fail(&pgconn.PgError{Code: "22P02", Message: fmt.Sprintf("fake postgres: %v", err)})
+		return
+	}
+
+	s.portals[m.DestinationPortal] = &fakePortal{
+		stmt:    stmt,
+		formats: m.ResultFormatCodes,
+		args:    args,
+	}
+	s.send(&pgproto3.BindComplete{})
+}
+
+// decodeArgs - parameter values of the Bind message by the parameter types of the statement
+func (s *fakeSession) decodeArgs(stmt *fakeStatement, m *pgproto3.Bind) ([]any, error) {
+	if len(m.Parameters) != len(stmt.params) {
+		return nil, fmt.Errorf("bind message supplies %d parameters, statement requires %d", len(m.Parameters), len(stmt.params))
+	}
+
+	args := make([]any, len(m.Parameters))
+	for i, p := range m.Parameters {
+		if p == nil {
+			continue
+		}
+
+		dt, ok := s.ci.DataTypeForOID(stmt.params[i])
+		if !ok {
+			return nil, fmt.Errorf("parameter %d: unknown oid %d", i+1, stmt.params[i])
+		}
+		value := pgtype.NewValue(dt.Value)
+		// the message buffer is reused by the next message
+		src := append([]byte{}, p...)
+
+		if formatCode(m.ParameterFormatCodes, i) == pgtype.BinaryFormatCode {
+			dec, ok := value.(pgtype.BinaryDecoder)
+			if !ok {
+				return nil, fmt.Errorf("parameter %d: binary format is not supported for %s", i+1, dt.Name)
+			}
+			if err := dec.DecodeBinary(s.ci, src); err != nil {
+				return nil, fmt.Errorf("parameter %d: %w", i+1, err)
+			}
+		} else {
+			dec, ok := value.(pgtype.TextDecoder)
+			if !ok {
+				return nil, fmt.Errorf("parameter %d: text format is not supported for %s", i+1, dt.Name)
+			}
+			if err := dec.DecodeText(s.ci, src); err != nil {
+				return nil, fmt.Errorf("parameter %d: %w", i+1, err)
+			}
+		}
+		args[i] = value.Get()
+	}
+	return args, nil
+}
+
+func (s *fakeSession) execute(m *pgproto3.Execute) {
+	portal := s.portals[m.Portal]
+	if portal == nil {
+		s.fail(&pgconn.PgError{Code: "34000", Message: fmt.Sprintf("portal \"%s\" does not exist", m.Portal)})
+		return
+	}
+
+	res, pgErr := s.run(portal.stmt.sql, portal.args)
+	if pgErr != nil {
+		s.fail(pgErr)
+		return
+	}
+
+	rows, pgErr := s.encodeRows(res, portal.formats)
+	if pgErr != nil {
+		s.fail(pgErr)
+		return
+	}
+	s.sendRows(res, rows)
+}
+
+// run - result of the statement with transaction status changes
+func (s *fakeSession) run(sql string, args []any) (Result, *pgconn.PgError) {
+	s.f.record(sql)
+
+	command := strings.ToUpper(firstWord(sql))
+
+	if s.txStatus == 'E' && command != "ROLLBACK" && command != "COMMIT" && command != "END" {
+		return Result{}, &pgconn.PgError{Code: "25P02", Message: "current transaction is aborted, commands ignored until end of transaction block"}
+	}
+
+	if res, ok := s.f.lookup(sql, args); ok {
+		if res.Err != nil {
+			if s.txStatus == 'T' {
+				s.txStatus = 'E'
+			}
+			return Result{}, res.Err
+		}
+		return res, nil
+	}
+
+	switch command {
+	case "BEGIN", "START":
+		s.txStatus = 'T'
+		return Result{Tag: "BEGIN"}, nil
+
+	case "COMMIT", "END":
+		tag := "COMMIT"
+		if s.txStatus == 'E' {
+			tag = "ROLLBACK"
+		}
+		s.txStatus = 'I'
+		return Result{Tag: tag}, nil
+
+	case "ROLLBACK":
+		if strings.Contains(strings.ToUpper(sql), "SAVEPOINT") {
+			s.txStatus = 'T'
+		} else {
+			s.txStatus = 'I'
+		}
+		return Result{Tag: "ROLLBACK"}, nil
+
+	case "SAVEPOINT", "RELEASE", "SET", "RESET", "DEALLOCATE":
+		return Result{Tag: command}, nil
+	}
+
+	if s.txStatus == 'T' {
+		s.txStatus = 'E'
+	}
+	return Result{}, &pgconn.PgError{Code: "XX000", Message: fmt.Sprintf("fake postgres: unexpected statement: %s", sql)}
+}
+
+func (s *fakeSession) fail(pgErr *pgconn.PgError) {
+	s.sendError(pgErr)
+	s.failed = true
+}
+
+func (s *fakeSession) sendError(pgErr *pgconn.PgError) {
+	severity := pgErr.Severity
+	if severity == "" {
+		severity = "ERROR"
+	}
+
+	s.send(&pgproto3.ErrorResponse{
+		Severity:            severity,
+		SeverityUnlocalized: severity,
+		Code:                pgErr.Code,
+		Message:             pgErr.Message,
+		Detail:              pgErr.Detail,
+		Hint:                pgErr.Hint,
+	})
+}
+
+func (s *fakeSession) sendRows(res Result, rows [][][]byte) {
+	for _, row := range rows {
+		s.send(&pgproto3.DataRow{Values: row})
+	}
+
+	tag := res.Tag
+	if tag == "" {
+		tag = "SELECT " + strconv.Itoa(len(rows))
+	}
+	s.send(&pgproto3.CommandComplete{CommandTag: []byte(tag)})
+}
+
+func (s *fakeSession) rowDescription(res Result, formats []int16) *pgproto3.RowDescription {
+	fields := make([]pgproto3.FieldDescription, len(res.Columns))
+	for i, c := range res.Columns {
+		fields[i] = pgproto3.FieldDescription{
+			Name:         []byte(c.Name),
+			DataTypeOID:  columnOID(res, i),
+			DataTypeSize: -1,
+			TypeModifier: -1,
+			Format:       formatCode(formats, i),
+		}
+	}
+	return &pgproto3.RowDescription{Fields: fields}
+}
+
+func (s *fakeSession) encodeRows(res Result, formats []int16) ([][][]byte, *pgconn.PgError) {
+	rows := make([][][]byte, len(res.Rows))
+	for i, row := range res.Rows {
+		if len(row) != len(res.Columns) {
+			return nil, &pgconn.PgError{Code: "XX000", Message: fmt.Sprintf("fake postgres: row %d has %d values, expected %d", i, len(row), len(res.Columns))}
+		}
+
+		rows[i] = make([][]byte, len(row))
+		for j, v := range row {
+			data, err := s.encode(columnOID(res, j), formatCode(formats, j), v)
+			if err != nil {
+				return nil, &pgconn.PgError{Code: "XX000", Message: fmt.Sprintf("fake postgres: column %s: %v", res.Columns[j].Name, err)}
+			}
+			rows[i][j] = data
+		}
+	}
+	return rows, nil
+}
+
+// encode - value in the text or binary format, nil - NULL
+func (s *fakeSession) encode(oid uint32, format int16, v any) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	dt, ok := s.ci.DataTypeForOID(oid)
+	if !ok {
+		return nil, fmt.Errorf("unknown oid %d", oid)
+	}
+
+	value := pgtype.NewValue(dt.Value)
+	if err := value.Set(v); err != nil {
+		return nil, err
+	}
+
+	// not nil buffer: empty value is not NULL
+	buf := []byte{}
+	if format == pgtype.BinaryFormatCode {
+		if enc, ok := value.(pgtype.BinaryEncoder); ok {
+			return enc.EncodeBinary(s.ci, buf)
+		}
+		return nil, fmt.Errorf("binary format is not supported for %s", dt.Name)
+	}
+
+	if enc, ok := value.(pgtype.TextEncoder); ok {
+		return enc.EncodeText(s.ci, buf)
+	}
+	return nil, fmt.Errorf("text format is not supported for %s", dt.Name)
+}
+
+// columnOID - type of the column, taken from the first not nil value if not specified
+func columnOID(res Result, col int) uint32 {
+	if oid := res.Columns[col].OID; oid != 0 {
+		return oid
+	}
+
+	for _, row := range res.Rows {
+		if col < len(row) && row[col] != nil {
+			return valueOID(row[col])
+		}
+	}
+	return pgtype.TextOID
+}
+
+func valueOID(v any) uint32 {
+	switch v.(type) {
+	case bool:
+		return pgtype.BoolOID
+	case int16, int8, uint8:
+		return pgtype.Int2OID
+	case int32, uint16:
+		return pgtype.Int4OID
+	case int, int64, uint32:
+		return pgtype.Int8OID
+	case float32:
+		return pgtype.Float4OID
+	case float64:
+		return pgtype.Float8OID
+	case []byte:
+		return pgtype.ByteaOID
+	case time.Time:
+		return pgtype.TimestamptzOID
+	}
+	return pgtype.TextOID
+}
+
+// formatCode - result format of the column by the Bind format codes
+func formatCode(formats []int16, col int) int16 {
+	switch len(formats) {
+	case 0:
+		return pgtype.TextFormatCode
+	case 1:
+		return formats[0]
+	}
+	if col < len(formats) {
+		return formats[col]
+	}
+	return pgtype.TextFormatCode
+}
+
+var paramRe = regexp.MustCompile(`\$(\d+)`)
+
+// paramCount - number of $n parameters in the statement
+func paramCount(sql string) int {
+	n := 0
+	for _, m := range paramRe.FindAllStringSubmatch(sql, -1) {
+		if i, err := strconv.Atoi(m[1]); err == nil && i > n {
+			n = i
+		}
+	}
+	return n
+}
+
+func firstWord(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.TrimSuffix(fields[0], ";")
+}
+
+// normalizeSql - statement text with collapsed whitespace and without the trailing semicolon
+// isCopy - COPY statement with the direction, e.g. "FROM STDIN"
+func isCopy(sql string, direction string) bool {
+	return strings.EqualFold(firstWord(sql), "COPY") && strings.Contains(strings.ToUpper(normalizeSql(sql)), direction)
+}
+
+var copySignature = []byte("PGCOPY\n\377\r\n\000")
+
+// copyRowCount - number of rows in the COPY data: tuples of the binary format or lines of the text one
+func copyRowCount(data []byte) (int, error) {
+	if !bytes.HasPrefix(data, copySignature) {
+		return bytes.Count(data, []byte("\n")), nil
+	}
+
+	// signature, flags, header extension length
+	pos := len(copySignature) + 4
+	if len(data) < pos+4 {
+		return 0, errors.New("invalid binary copy header")
+	}
+	pos += 4 + int(binary.BigEndian.Uint32(data[pos:]))
+
+	n := 0
+	for {
+		// the trailer is optional
+		if len(data) == pos {
+			return n, nil
+		}
+		if len(data) < pos+2 {
+			return 0, errors.New("unexpected end of binary copy data")
+		}
+		fields := int16(binary.BigEndian.Uint16(data[pos:]))
+		pos += 2
+		if fields == -1 {
+			return n, nil
+		}
+
+		for i := 0; i < int(fields); i++ {
+			if len(data) < pos+4 {
+				return 0, errors.New("unexpected end of binary copy data")
+			}
+			size := int32(binary.BigEndian.Uint32(data[pos:]))
+			pos += 4
+			if size > 0 {
+				pos += int(size)
+			}
+		}
+		n++
+	}
+}
+
+func normalizeSql(sql string) string {
+	return strings.TrimSpace(strings.TrimSuffix(strings.Join(strings.Fields(sql), " "), ";"))
+}
@@ -0,0 +1,237 @@
+package sqlqtest
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgtype"
+)
+
+// LargeObjects - in-memory Large Objects for the fake server: handler of the statements of pgx.LargeObjects.
+// Descriptors are shared by all connections and are not closed at the end of the transaction
+//
+//	lo := sqlqtest.NewLargeObjects()
+//	srv.Handle(lo.Handle)
+type LargeObjects struct {
+	mu      sync.Mutex
+	objects map[uint32][]byte
+	fds     map[int32]*largeObjectFd
+	nextOid uint32
+	nextFd  int32
+	// bytes returned by loread
+	read int64
+}
+
+type largeObjectFd struct {
+	oid uint32
+	pos int64
+}
+
+// NewLargeObjects - empty storage
+func NewLargeObjects() *LargeObjects {
+	return &LargeObjects{
+		objects: make(map[uint32][]byte),
+		fds:     make(map[int32]*largeObjectFd),
+		nextOid: 16384,
+	}
+}
+
+// Set - create or replace the object
+func (l *LargeObjects) Set(oid uint32, data []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.objects[oid] = append([]byte{}, data...)
+}
+
+// Get - content of the object, false if it doesn't exist
+func (l *LargeObjects) Get(oid uint32) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	data, ok := l.objects[oid]
+	return append([]byte{}, data...), ok
+}
+
+// Oids - ids of the existing objects in ascending order
+func (l *LargeObjects) Oids() []uint32 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	res := make([]uint32, 0, len(l.objects))
+	for oid := range l.objects {
+		res = append(res, oid)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res
+}
+
+// Open - number of the open descriptors
+func (l *LargeObjects) Open() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.fds)
+}
+
+// BytesRead - total number of bytes read from the objects
+func (l *LargeObjects) BytesRead() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.read
+}
+
+// Handle - Handler of the large object statements
+func (l *LargeObjects) Handle(sql string, args []any) (Result, bool) {
+	var (
+		params []uint32
+		column Column
+	)
+	switch normalizeSql(sql) {
+	case "select lo_create($1)":
+		params, column = []uint32{pgtype.OIDOID}, Column{Name: "lo_create", OID: pgtype.OIDOID}
+	case "select lo_open($1, $2)":
+		params, column = []uint32{pgtype.OIDOID, pgtype.Int4OID}, Column{Name: "lo_open", OID: pgtype.Int4OID}
+	case "select lo_unlink($1)":
+		params, column = []uint32{pgtype.OIDOID}, Column{Name: "lo_unlink", OID: pgtype.Int4OID}
+	case "select lowrite($1, $2)":
+		params, column = []uint32{pgtype.Int4OID, pgtype.ByteaOID}, Column{Name: "lowrite", OID: pgtype.Int4OID}
+	case "select loread($1, $2)":
+		params, column = []uint32{pgtype.Int4OID, pgtype.Int4OID}, Column{Name: "loread", OID: pgtype.ByteaOID}
+	case "select lo_lseek64($1, $2, $3)":
+		params, column = []uint32{pgtype.Int4OID, pgtype.Int8OID, pgtype.Int4OID}, Column{Name: "lo_lseek64", OID: pgtype.Int8OID}
+	case "select lo_tell64($1)":
+		params, column = []uint32{pgtype.Int4OID}, Column{Name: "lo_tell64", OID: pgtype.Int8OID}
+	case "select lo_truncate64($1, $2)":
+		params, column = []uint32{pgtype.Int4OID, pgtype.Int8OID}, Column{Name: "lo_truncate64", OID: pgtype.Int4OID}
+	case "select lo_close($1)":
+		params, column = []uint32{pgtype.Int4OID}, Column{Name: "lo_close", OID: pgtype.Int4OID}
+	default:
+		return Result{}, false
+	}
+
+	res := Result{Columns: []Column{column}, Params: params}
+	if args == nil {
+		return res, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	value, err := l.call(column.Name, args)
+	if err != nil {
+		res.Err = err
+		return res, true
+	}
+	res.Rows = [][]any{{value}}
+	return res, true
+}
+
+func (l *LargeObjects) call(name string, args []any) (any, *pgconn.PgError) {
+	switch name {
+	case "lo_create":
+		oid := args[0].(uint32)
+		if oid == 0 {
+			for l.objects[l.nextOid] != nil {
+				l.nextOid++
+			}
+			oid = l.nextOid
+		} else if _, ok := l.objects[oid]; ok {
+			return nil, &pgconn.PgError{Code: "23505", Message: fmt.Sprintf("large object %d already exists", oid)}
+		}
+		l.objects[oid] = []byte{}
+		return oid, nil
+
+	case "lo_open":
+		oid := args[0].(uint32)
+		if _, ok := l.objects[oid]; !ok {
+			return nil, largeObjectNotFound(oid)
+		}
+		l.nextFd++
+		l.fds[l.nextFd] = &largeObjectFd{oid: oid}
+		return l.nextFd, nil
+
+	case "lo_unlink":
+		oid := args[0].(uint32)
+		if _, ok := l.objects[oid]; !ok {
+			return nil, largeObjectNotFound(oid)
+		}
+		delete(l.objects, oid)
+		return int32(1), nil
+	}
+
+	fd, ok := l.fds[args[0].(int32)]
+	if !ok {
+		return nil, &pgconn.PgError{Code: "42704", Message: fmt.Sprintf("invalid large-object descriptor: %d", args[0])}
+	}
+	data, ok := l.objects[fd.oid]
+	if !ok {
+		return nil, largeObjectNotFound(fd.oid)
+	}
+
+	switch name {
+	case "lowrite":
+		p := args[1].([]byte)
+		if end := fd.pos + int64(len(p)); end > int64(len(data)) {
+			data = append(data, make([]byte, end-int64(len(data)))...)
+		}
+		copy(data[fd.pos:], p)
+		l.objects[fd.oid] = data
+		fd.pos += int64(len(p))
+		return int32(len(p)), nil
+
+	case "loread":
+		n := int64(args[1].(int32))
+		if fd.pos >= int64(len(data)) {
+			return []byte{}, nil
+		}
+		end := fd.pos + n
+		if end > int64(len(data)) {
+			end = int64(len(data))
+		}
+		p := append([]byte{}, data[fd.pos:end]...)
+		fd.pos = end
+		l.read += int64(len(p))
+		return p, nil
+
+	case "lo_lseek64":
+		offset, whence := args[1].(int64), args[2].(int32)
+		switch whence {
+		case io.SeekCurrent:
+			offset += fd.pos
+		case io.SeekEnd:
+			offset += int64(len(data))
+		}
+		if offset < 0 {
+			return nil, &pgconn.PgError{Code: "22023", Message: fmt.Sprintf("invalid seek offset: %d", offset)}
+		}
+		fd.pos = offset
+		return offset, nil
+
+	case "lo_tell64":
+		return fd.pos, nil
+
+	case "lo_truncate64":
+		size := args[1].(int64)
+		if size < int64(len(data)) {
+			data = data[:size]
+		} else {
+			data = append(data, make([]byte, size-int64(len(data)))...)
+		}
+		l.objects[fd.oid] = data
+		return int32(0), nil
+
+	case "lo_close":
+		delete(l.fds, args[0].(int32))
+		return int32(0), nil
+	}
+	return nil, &pgconn.PgError{Code: "XX000", Message: "fake postgres: unknown large object function " + name}
+}
+
+func largeObjectNotFound(oid uint32) *pgconn.PgError {
+	return &pgconn.PgError{Code: "42704", Message: fmt.Sprintf("large object %d does not exist", oid)}
+}
@@ -0,0 +1,150 @@
+package sqlq
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+const (
+	// number of the latest latencies of the statement used for the percentile
+	statsWindow = 1024
+	// default maximum number of statements of Stats
+	defaultStatsMaxStatements = 1000
+)
+
+// StatementStats - accumulated statistics of the statement
+type StatementStats struct {
+	// Fingerprint - statement text without the values, see Fingerprint
+	Fingerprint string
+	Calls       int64
+	Errors      int64
+	// Rows - rows affected by the commands or read from the selects
+	Rows      int64
+	TotalTime time.Duration
+	MeanTime  time.Duration
+	// P95Time - 95th percentile of the latest latencies
+	P95Time time.Duration
+}
+
+type statementStats struct {
+	calls     int64
+	errors    int64
+	rows      int64
+	totalTime time.Duration
+	// ring buffer of the latest latencies
+	latencies []time.Duration
+	next      int
+}
+
+// Stats - middleware that accumulates the statistics of the statements grouped by fingerprint,
+// a lightweight in-process pg_stat_statements: sqlq.Use(stats)
+type Stats struct {
+	maxStatements int
+
+	mu         sync.Mutex
+	statements map[string]*statementStats
+}
+
+// NewStats - create the statistics. maxStatements - maximum number of tracked statements (0 - 1000),
+// statements beyond the limit are not tracked until Reset
+func NewStats(maxStatements int) *Stats {
+	if maxStatements <= 0 {
+		maxStatements = defaultStatsMaxStatements
+	}
+	return &Stats{
+		maxStatements: maxStatements,
+		statements:    map[string]*statementStats{},
+	}
+}
+
+// Exec - implementation of Middleware
+func (s *Stats) Exec(next ExecFunc) ExecFunc {
+	return func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+		start := time.Now()
+		tag, err := next(ctx, sql, args...)
+		s.record(sql, time.Since(start), tag.RowsAffected(), err)
+		return tag, err
+	}
+}
+
+// Query - implementation of Middleware. The latency includes reading the rows
+func (s *Stats) Query(next QueryFunc) QueryFunc {
+	return func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+		start := time.Now()
+		rows, err := next(ctx, sql, args...)
+		if err != nil {
+			s.record(sql, time.Since(start), 0, err)
+			return nil, err
+		}
+
+		counted := &countRows{Rows: rows}
+		return &closeHookRows{Rows: counted, onClose: func(rows pgx.Rows) { s.record(sql, time.Since(start), counted.n, rows.Err()) }}, nil
+	}
+}
+
+func (s *Stats) record(sql string, duration time.Duration, rows int64, err error) {
+	fp := Fingerprint(sql)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st, ok := s.statements[fp]
+	if !ok {
+		if len(s.statements) >= s.maxStatements {
+			return
+		}
+		st = &statementStats{}
+		s.statements[fp] = st
+	}
+
+	st.calls++
+	if err != nil {
+		st.errors++
+	}
+	st.rows += rows
+	st.totalTime += duration
+
+	if len(st.latencies) < statsWindow {
+		st.latencies = append(st.latencies, duration)
+	} else {
+		st.latencies[st.next] = duration
+		st.next = (st.next + 1) % statsWindow
+	}
+}
+
+// Snapshot - statistics of the statements ordered by the total time, the most expensive first
+func (s *Stats) Snapshot() []StatementStats {
+	s.mu.Lock()
+	res := make([]StatementStats, 0, len(s.statements))
+	var sorted []time.Duration
+	for fp, st := range s.statements {
+		sorted = append(sorted[:0], st.latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+		res = append(res, StatementStats{
+			Fingerprint: fp,
+			Calls:       st.calls,
+			Errors:      st.errors,
+			Rows:        st.rows,
+			TotalTime:   st.totalTime,
+			MeanTime:    st.totalTime / time.Duration(st.calls),
+			P95Time:     percentile(sorted, 0.95),
+		})
+	}
+	s.mu.Unlock()
+
+	sort.Slice(res, func(i, j int) bool { return res[i].TotalTime > res[j].TotalTime })
+	return res
+}
+
+// Reset - drop the accumulated statistics
+func (s *Stats) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statements = map[string]*statementStats{}
+}
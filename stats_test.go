@@ -0,0 +1,76 @@
+package sqlq
+
+import (
+	"context"
+	"testing"
+
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+func TestStats(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect("SELECT id FROM users WHERE id = 1", sqlqtest.Result{Columns: []sqlqtest.Column{{Name: "id"}}, Rows: [][]any{{1}}})
+	srv.Expect("SELECT id FROM users WHERE id = 2", sqlqtest.Result{Columns: []sqlqtest.Column{{Name: "id"}}, Rows: [][]any{{2}, {3}}})
+	srv.Expect("UPDATE users SET name = 'a'", sqlqtest.Result{Tag: "UPDATE 5"})
+	srv.ExpectError("UPDATE users SET name = 'b'", "23505", "duplicate key value")
+
+	stats := NewStats(0)
+	q := NewQuery(pool, context.Background())
+	defer q.Release()
+	q.Use(stats)
+
+	for _, sql := range []string{"SELECT id FROM users WHERE id = 1", "SELECT id FROM users WHERE id = 2"} {
+		if err := q.Select(sql); err != nil {
+			t.Fatal(err)
+		}
+		for q.Next() {
+		}
+		if err := q.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Exec("UPDATE users SET name = 'a'"); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Exec("UPDATE users SET name = 'b'"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	got := map[string]StatementStats{}
+	for _, st := range stats.Snapshot() {
+		got[st.Fingerprint] = st
+	}
+	if len(got) != 2 {
+		t.Fatalf("%d statements, want 2: %v", len(got), got)
+	}
+
+	// the statements with different values are grouped
+	sel := got[Fingerprint("SELECT id FROM users WHERE id = 1")]
+	if sel.Calls != 2 || sel.Errors != 0 || sel.Rows != 3 {
+		t.Errorf("select %+v", sel)
+	}
+	upd := got[Fingerprint("UPDATE users SET name = 'a'")]
+	if upd.Calls != 2 || upd.Errors != 1 || upd.Rows != 5 {
+		t.Errorf("update %+v", upd)
+	}
+	if upd.MeanTime != upd.TotalTime/2 || upd.P95Time <= 0 {
+		t.Errorf("update times %+v", upd)
+	}
+
+	stats.Reset()
+	if n := len(stats.Snapshot()); n != 0 {
+		t.Errorf("%d statements after reset", n)
+	}
+}
+
+func TestStatsMaxStatements(t *testing.T) {
+	stats := NewStats(1)
+	stats.record("SELECT 1", 1, 0, nil)
+	stats.record("SELECT a FROM t", 1, 0, nil)
+	stats.record("SELECT 2", 1, 0, nil)
+
+	snapshot := stats.Snapshot()
+	if len(snapshot) != 1 || snapshot[0].Calls != 2 {
+		t.Errorf("snapshot %+v", snapshot)
+	}
+}
@@ -0,0 +1,176 @@
+package sqlq
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// StatementCache - opt-in cache of prepared statements keyed by the sql text. Parameterized statements executed
+// with the cache are prepared once per connection and then executed without parse/plan. Prepared statements belong
+// to a connection, so the cache keeps the last capacity statements of each connection, older ones are deallocated.
+// Statements without parameters are executed with the simple protocol and are not cached
+type StatementCache struct {
+	capacity int
+
+	mu         sync.Mutex
+	conns      map[*pgx.Conn]*connStatements
+	generation uint64
+
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+// StatementCacheStats - statement cache metrics
+type StatementCacheStats struct {
+	// statement was already prepared on the connection
+	Hits int64
+	// statement was prepared
+	Misses int64
+	// statements deallocated because of the capacity limit
+	Evictions int64
+	// statements prepared on the open connections
+	Prepared int
+	// open connections with prepared statements
+	Connections int
+}
+
+// prepared statements of a connection, front - recently used
+type connStatements struct {
+	generation uint64
+	names      map[string]*list.Element
+	order      *list.List
+}
+
+// default number of prepared statements per connection
+const defaultStatementCacheCapacity = 256
+
+// NewStatementCache - create a statement cache. capacity - maximum number of prepared statements per connection,
+// 0 - default (256)
+func NewStatementCache(capacity int) *StatementCache {
+	if capacity <= 0 {
+		capacity = defaultStatementCacheCapacity
+	}
+
+	return &StatementCache{
+		capacity: capacity,
+		conns:    make(map[*pgx.Conn]*connStatements),
+	}
+}
+
+// Stats - cache metrics
+func (c *StatementCache) Stats() StatementCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := StatementCacheStats{
+		Hits:      atomic.LoadInt64(&c.hits),
+		Misses:    atomic.LoadInt64(&c.misses),
+		Evictions: atomic.LoadInt64(&c.evictions),
+	}
+	for conn, cs := range c.conns {
+		if conn.IsClosed() || cs.generation != c.generation {
+			continue
+		}
+		stats.Prepared += cs.order.Len()
+		stats.Connections++
+	}
+	return stats
+}
+
+// Clear - forget all statements. They are deallocated on each connection the next time it is used with the cache,
+// e.g. after a schema change that invalidates cached plans
+func (c *StatementCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+}
+
+// prepare - prepare the statement on the connection if needed and return its name.
+// The connection must not be used concurrently, as usual for pgx.Conn
+func (c *StatementCache) prepare(ctx context.Context, conn *pgx.Conn, sql string) (string, error) {
+	name := statementName(sql)
+
+	c.mu.Lock()
+	cs := c.conns[conn]
+	if cs == nil {
+		c.removeClosed()
+		cs = &connStatements{
+			generation: c.generation,
+			names:      make(map[string]*list.Element),
+			order:      list.New(),
+		}
+		c.conns[conn] = cs
+	}
+
+	var stale []string
+	if cs.generation != c.generation {
+		for e := cs.order.Front(); e != nil; e = e.Next() {
+			stale = append(stale, e.Value.(string))
+		}
+		cs.generation = c.generation
+		cs.names = make(map[string]*list.Element)
+		cs.order.Init()
+	}
+
+	if e, ok := cs.names[name]; ok {
+		cs.order.MoveToFront(e)
+		c.mu.Unlock()
+		atomic.AddInt64(&c.hits, 1)
+		return name, nil
+	}
+	c.mu.Unlock()
+
+	for _, s := range stale {
+		if err := conn.Deallocate(ctx, s); err != nil {
+			return "", err
+		}
+	}
+
+	if _, err := conn.Prepare(ctx, name, sql); err != nil {
+		return "", err
+	}
+	atomic.AddInt64(&c.misses, 1)
+
+	var evicted []string
+	c.mu.Lock()
+	cs.names[name] = cs.order.PushFront(name)
+	for cs.order.Len() > c.capacity {
+		e := cs.order.Back()
+		cs.order.Remove(e)
+		delete(cs.names, e.Value.(string))
+		evicted = append(evicted, e.Value.(string))
+	}
+	c.mu.Unlock()
+
+	for _, s := range evicted {
+		atomic.AddInt64(&c.evictions, 1)
+		if err := conn.Deallocate(ctx, s); err != nil {
+			return "", err
+		}
+	}
+
+	return name, nil
+}
+
+// removeClosed - forget the connections closed by the pool
+func (c *StatementCache) removeClosed() {
+	for conn := range c.conns {
+		if conn.IsClosed() {
+			delete(c.conns, conn)
+		}
+	}
+}
+
+// statementName - name of the prepared statement for the sql text
+func statementName(sql string) string {
+	h := sha256.Sum256([]byte(sql))
+	return "sqlq_" + hex.EncodeToString(h[:16])
+}
@@ -0,0 +1,103 @@
+package sqlq
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+// deallocated - statements deallocated on the server
+func deallocated(srv *sqlqtest.FakePostgres) []string {
+	var res []string
+	for _, sql := range srv.Queries() {
+		if name := strings.TrimPrefix(sql, "deallocate "); name != sql {
+			res = append(res, strings.Trim(name, `"`))
+		}
+	}
+	return res
+}
+
+func TestStatementCacheEviction(t *testing.T) {
+	srv, pool := newTestPool(t)
+	stmts := []string{"UPDATE t SET a = $1", "UPDATE t SET b = $1", "UPDATE t SET c = $1"}
+	for _, sql := range stmts {
+		srv.Expect(sql, sqlqtest.Result{Tag: "UPDATE 1"})
+	}
+
+	cache := NewStatementCache(2)
+	err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+		tx.SetStatementCache(cache)
+		q := NewQueryTx(tx, tx.ctx)
+
+		// a, b, a (hit), c evicts b, b evicts a
+		for _, i := range []int{0, 1, 0, 2, 1} {
+			if err := q.ExecArgs(stmts[i], i); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := StatementCacheStats{Hits: 1, Misses: 4, Evictions: 2, Prepared: 2, Connections: 1}
+	if got := cache.Stats(); got != want {
+		t.Errorf("stats %+v, want %+v", got, want)
+	}
+	if got, want := deallocated(srv), []string{statementName(stmts[1]), statementName(stmts[0])}; !reflect.DeepEqual(got, want) {
+		t.Errorf("deallocated %v, want %v", got, want)
+	}
+}
+
+func TestStatementCacheClear(t *testing.T) {
+	srv, pool := newTestPool(t)
+	const sql = "UPDATE t SET a = $1"
+	srv.Expect(sql, sqlqtest.Result{Tag: "UPDATE 1"})
+
+	cache := NewStatementCache(0)
+	err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+		q := NewQueryTx(tx, tx.ctx)
+		q.SetStatementCache(cache)
+
+		if err := q.ExecArgs(sql, 1); err != nil {
+			return err
+		}
+		cache.Clear()
+		if got := cache.Stats().Prepared; got != 0 {
+			t.Errorf("%d statements after clear", got)
+		}
+		// prepared again after the stale statement is deallocated
+		return q.ExecArgs(sql, 2)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := StatementCacheStats{Misses: 2, Prepared: 1, Connections: 1}
+	if got := cache.Stats(); got != want {
+		t.Errorf("stats %+v, want %+v", got, want)
+	}
+	if got, want := deallocated(srv), []string{statementName(sql)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("deallocated %v, want %v", got, want)
+	}
+}
+
+func TestStatementCacheSkipsStatementsWithoutArgs(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect("UPDATE t SET a = 1", sqlqtest.Result{Tag: "UPDATE 1"})
+
+	cache := NewStatementCache(0)
+	q := NewQuery(pool, context.Background())
+	q.SetStatementCache(cache)
+	if err := q.Exec("UPDATE t SET a = 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := cache.Stats(); got != (StatementCacheStats{}) {
+		t.Errorf("stats %+v", got)
+	}
+}
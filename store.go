@@ -0,0 +1,258 @@
+package sqlq
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/n-r-w/nerr"
+)
+
+// TemplateStore - named sql templates loaded from *.sql files. Template name is the file path inside fs.FS: "queries/get_user.sql"
+// Placeholders (key + name, e.g. :id) are validated at loading, parameters are checked against them before binding.
+// Placeholders of optional sections [[ ... ]] may have no parameters.
+// Files may contain text/template actions with TemplateFuncs for the parts that can't be bound as values,
+// e.g. SELECT * FROM {{ident .table}} WHERE id = :id. The actions are rendered with the parameters before binding,
+// the parameters they use are required
+type TemplateStore struct {
+	key       string
+	templates map[string]*storedTemplate
+}
+
+type storedTemplate struct {
+	sql    string
+	params map[string]bool
+	// placeholders outside of optional sections
+	required map[string]bool
+	// text/template of the file with actions, nil if there are none
+	text *template.Template
+	// parameters used by the actions
+	fields map[string]bool
+}
+
+// templateActions - text/template actions of the template files
+var templateActions = regexp.MustCompile(`(?s)\{\{.*?\}\}`)
+
+// NewTemplateStore - load all *.sql files from fsys (embed.FS, os.DirFS, ...). key - placeholder prefix, e.g. ":"
+func NewTemplateStore(fsys fs.FS, key string) (*TemplateStore, error) {
+	if key == "" {
+		return nil, nerr.New("empty placeholder key")
+	}
+
+	s := &TemplateStore{
+		key:       key,
+		templates: make(map[string]*storedTemplate),
+	}
+
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(name) != ".sql" {
+			return nil
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		sql := strings.TrimSpace(string(data))
+		if sql == "" {
+			return fmt.Errorf("%s: empty template", name)
+		}
+
+		t := &storedTemplate{sql: sql}
+		// placeholders are searched in the text outside of the actions
+		text := sql
+		if strings.Contains(sql, "{{") {
+			if t.text, err = ParseTemplate(name, sql); err != nil {
+				return err
+			}
+			t.fields = templateFields(t.text)
+			text = templateActions.ReplaceAllString(sql, " ")
+		}
+
+		t.params, err = templatePlaceholders(text, key)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		withoutSections, err := expandSections(text, nil, []string{key})
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		t.required, err = templatePlaceholders(withoutSections, key)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		s.templates[name] = t
+		return nil
+	})
+	if err != nil {
+		return nil, nerr.New(err)
+	}
+
+	return s, nil
+}
+
+// Names - names of the loaded templates
+func (s *TemplateStore) Names() []string {
+	res := make([]string, 0, len(s.templates))
+	for name := range s.templates {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Sql - template text without binding
+func (s *TemplateStore) Sql(name string) (string, error) {
+	t, err := s.template(name)
+	if err != nil {
+		return "", err
+	}
+	return t.sql, nil
+}
+
+// Params - placeholder names and parameters of the actions of the template
+func (s *TemplateStore) Params(name string) ([]string, error) {
+	t, err := s.template(name)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0, len(t.params)+len(t.fields))
+	for p := range t.params {
+		res = append(res, p)
+	}
+	for p := range t.fields {
+		if !t.params[p] {
+			res = append(res, p)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// Bind - sql of the template with substituted parameters. All placeholders outside of optional sections must have values,
+// unknown parameters are not allowed. Expr values are not allowed, use BindArgs
+func (s *TemplateStore) Bind(name string, params map[string]any) (string, error) {
+	sql, args, err := s.bind(defaultBinder, name, params)
+	if err != nil {
+		return "", err
+	}
+	if len(args) > 0 {
+		return "", nerr.New(fmt.Errorf("%s: expression parameters require BindArgs", name))
+	}
+	return sql, nil
+}
+
+// BindArgs - Bind with $n statement parameters of the Expr values
+func (s *TemplateStore) BindArgs(name string, params map[string]any) (string, []any, error) {
+	return s.bind(defaultBinder, name, params)
+}
+
+func (s *TemplateStore) bind(binder Binder, name string, params map[string]any) (string, []any, error) {
+	t, err := s.template(name)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, required := range []map[string]bool{t.required, t.fields} {
+		for p := range required {
+			if _, ok := params[p]; !ok {
+				return "", nil, nerr.New(fmt.Errorf("%s: missing parameter %s", name, p))
+			}
+		}
+	}
+	for p := range params {
+		if !t.params[p] && !t.fields[p] {
+			return "", nil, nerr.New(fmt.Errorf("%s: unknown parameter %s", name, p))
+		}
+	}
+
+	sql := t.sql
+	if t.text != nil {
+		if sql, err = RenderTemplate(t.text, params); err != nil {
+			return "", nil, nerr.New(fmt.Errorf("%s: %w", name, err))
+		}
+	}
+
+	sql, args, err := bindSql(binder, sql, params, s.key)
+	if err != nil {
+		return "", nil, nerr.New(fmt.Errorf("%s: %w", name, err))
+	}
+	return sql, args, nil
+}
+
+// Select - executing the select command from the template
+func (s *TemplateStore) Select(q *Query, name string, params map[string]any) error {
+	sql, args, err := s.bind(q.Binder(), name, params)
+	if err != nil {
+		return err
+	}
+	return q.SelectArgs(sql, args...)
+}
+
+// Exec - execution of the insert, update, delete command from the template
+func (s *TemplateStore) Exec(q *Query, name string, params map[string]any) error {
+	sql, args, err := s.bind(q.Binder(), name, params)
+	if err != nil {
+		return err
+	}
+	return q.ExecArgs(sql, args...)
+}
+
+func (s *TemplateStore) template(name string) (*storedTemplate, error) {
+	t, ok := s.templates[name]
+	if !ok {
+		return nil, nerr.New(fmt.Errorf("template not found: %s", name))
+	}
+	return t, nil
+}
+
+// templatePlaceholders - placeholder names in the sql, found the same way as bindPlaceholders does: string literals,
+// quoted identifiers, dollar quoted strings and comments are skipped, doubled key (::type cast for ":") and
+// the key without a name (:= in pl/pgsql) are not placeholders
+func templatePlaceholders(sql string, key string) (map[string]bool, error) {
+	params := make(map[string]bool)
+
+	for i := 0; i < len(sql); {
+		end, err := skipSqlToken(sql, i)
+		if err != nil {
+			return nil, err
+		}
+		if end > i {
+			i = end
+			continue
+		}
+
+		if !strings.HasPrefix(sql[i:], key) {
+			i++
+			continue
+		}
+
+		start := i + len(key)
+		if strings.HasPrefix(sql[start:], key) {
+			i = start + len(key)
+			continue
+		}
+
+		end = start
+		for end < len(sql) && isIdentRune(rune(sql[end])) {
+			end++
+		}
+		if end > start {
+			params[sql[start:end]] = true
+		}
+		i = end
+	}
+
+	return params, nil
+}
@@ -0,0 +1,87 @@
+package sqlq
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestTemplateStoreIdent(t *testing.T) {
+	s, err := NewTemplateStore(fstest.MapFS{
+		"get.sql":  {Data: []byte("SELECT * FROM {{ident .table}} WHERE id = :id [[AND name = :name]]")},
+		"sort.sql": {Data: []byte("SELECT * FROM t ORDER BY {{ident .column}}")},
+	}, ":")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params, err := s.Params("get.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"id", "name", "table"}; !reflect.DeepEqual(params, want) {
+		t.Errorf("params: got %v, want %v", params, want)
+	}
+
+	sql, _, err := s.bind(literalBinder, "get.sql", map[string]any{"table": "public.users", "id": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `SELECT * FROM "public"."users" WHERE id = 1 `; sql != want {
+		t.Errorf("got %q, want %q", sql, want)
+	}
+
+	// placeholder text inside the identifier is not bound
+	sql, _, err = s.bind(literalBinder, "get.sql", map[string]any{"table": "t:id", "id": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `SELECT * FROM "t:id" WHERE id = 1 `; sql != want {
+		t.Errorf("got %q, want %q", sql, want)
+	}
+
+	if _, err := s.Bind("get.sql", map[string]any{"id": 1}); err == nil {
+		t.Error("expected missing parameter error")
+	}
+	if _, err := s.Bind("sort.sql", map[string]any{"column": ""}); err == nil {
+		t.Error("expected invalid identifier error")
+	}
+}
+
+func TestTemplateStoreQuotedContent(t *testing.T) {
+	s, err := NewTemplateStore(fstest.MapFS{
+		"do.sql":     {Data: []byte("DO $body$ DECLARE n int; BEGIN n := 1; PERFORM ':x'; END $body$; SELECT :id")},
+		"escape.sql": {Data: []byte(`SELECT E'it\'s :x', $$:y$$, /* :z /* :w */ */ :id`)},
+	}, ":")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"do.sql", "DO $body$ DECLARE n int; BEGIN n := 1; PERFORM ':x'; END $body$; SELECT 1"},
+		{"escape.sql", `SELECT E'it\'s :x', $$:y$$, /* :z /* :w */ */ 1`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := s.Params(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"id"}; !reflect.DeepEqual(params, want) {
+				t.Errorf("params: got %v, want %v", params, want)
+			}
+
+			sql, _, err := s.bind(literalBinder, tt.name, map[string]any{"id": 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sql != tt.want {
+				t.Errorf("got %q, want %q", sql, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package sqlq
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/n-r-w/nerr"
+)
+
+// SyncResult - SyncTable statistics
+type SyncResult struct {
+	Inserted int64
+	Updated  int64
+	Deleted  int64
+}
+
+// SyncTable - make the table in the destination database equal to the table in the source database.
+// The source is split into batches of rows ordered by the key columns. For each batch an aggregate md5 hash of the rows
+// is calculated on both sides, batches with equal hashes are skipped without transferring rows. For the other batches
+// the md5 hashes of the rows are compared by key and only the changed rows are transferred.
+// Each batch is applied in a separate transaction. The tables must have the same structure and no generated columns
+func SyncTable(srcPool *pgxpool.Pool, dstPool *pgxpool.Pool, ctx context.Context, table string, keyCols []string, batch int) (SyncResult, error) {
+	var res SyncResult
+
+	if len(keyCols) == 0 {
+		return res, nerr.New("no key columns")
+	}
+	if batch <= 0 {
+		return res, nerr.New(fmt.Errorf("invalid batch size: %d", batch))
+	}
+
+	s := newSyncSql(table, keyCols)
+	last := ""
+	for {
+		src, err := SelectRow(srcPool, ctx, s.sourceBatch(last, batch))
+		if err != nil {
+			return res, err
+		}
+
+		if src.Int64("sqlq_count") == 0 {
+			// rows after the last source key
+			q, err := Exec(dstPool, ctx, "DELETE FROM "+s.table+" WHERE "+s.after(last))
+			if err != nil {
+				return res, err
+			}
+			res.Deleted += q.RowsAffected()
+			return res, nil
+		}
+		upper := src.String("sqlq_upper")
+
+		dst, err := SelectRow(dstPool, ctx, s.destinationBatch(last, upper))
+		if err != nil {
+			return res, err
+		}
+
+		if dst.String("sqlq_hash") != src.String("sqlq_hash") {
+			if err := s.syncBatch(srcPool, dstPool, ctx, last, upper, &res); err != nil {
+				return res, err
+			}
+		}
+
+		last = upper
+	}
+}
+
+// syncBatch - transfer the changed rows of the batch with the keys in (last, upper]
+func (s *syncSql) syncBatch(srcPool *pgxpool.Pool, dstPool *pgxpool.Pool, ctx context.Context, last string, upper string, res *SyncResult) error {
+	srcHashes, err := s.rowHashes(srcPool, ctx, last, upper)
+	if err != nil {
+		return err
+	}
+	dstHashes, err := s.rowHashes(dstPool, ctx, last, upper)
+	if err != nil {
+		return err
+	}
+
+	var removeKeys, changedKeys []string
+	for key, hash := range srcHashes {
+		dstHash, ok := dstHashes[key]
+		switch {
+		case !ok:
+			changedKeys = append(changedKeys, key)
+			res.Inserted++
+		case dstHash != hash:
+			removeKeys = append(removeKeys, key)
+			changedKeys = append(changedKeys, key)
+			res.Updated++
+		}
+		delete(dstHashes, key)
+	}
+	for key := range dstHashes {
+		removeKeys = append(removeKeys, key)
+		res.Deleted++
+	}
+
+	var insertRows []string
+	if len(changedKeys) > 0 {
+		insertRows, err = Column[string](srcPool, ctx, s.selectRows(changedKeys), "sqlq_row")
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.apply(dstPool, ctx, removeKeys, insertRows)
+}
+
+// rowHashes - md5 hashes of the rows with the keys in (last, upper] by the json encoded key
+func (s *syncSql) rowHashes(pool *pgxpool.Pool, ctx context.Context, last string, upper string) (map[string]string, error) {
+	hashes := map[string]string{}
+	err := ForEach(pool, ctx, s.selectHashes(last, upper), func(q *Query) error {
+		hashes[q.String("sqlq_key")] = q.String("sqlq_hash")
+		return nil
+	})
+	return hashes, err
+}
+
+type syncSql struct {
+	table    string
+	keys     string
+	keysDesc string
+	keysJson string
+}
+
+func newSyncSql(table string, keyCols []string) *syncSql {
+	keys := make([]string, len(keyCols))
+	keysDesc := make([]string, len(keyCols))
+	pairs := make([]string, len(keyCols))
+	for i, k := range keyCols {
+		keys[i] = quoteIdent(k)
+		keysDesc[i] = quoteIdent(k) + " DESC"
+		pairs[i] = fmt.Sprintf("%s, %s", quoteLiteral(k), quoteIdent(k))
+	}
+
+	return &syncSql{
+		table:    quoteName(table),
+		keys:     strings.Join(keys, ", "),
+		keysDesc: strings.Join(keysDesc, ", "),
+		keysJson: fmt.Sprintf("jsonb_build_object(%s)::text", strings.Join(pairs, ", ")),
+	}
+}
+
+// keyRecord - key columns of the json encoded key
+func (s *syncSql) keyRecord(key string) string {
+	return fmt.Sprintf("(SELECT %s FROM jsonb_populate_record(NULL::%s, %s::jsonb))", s.keys, s.table, quoteLiteral(key))
+}
+
+func (s *syncSql) after(last string) string {
+	if last == "" {
+		return "TRUE"
+	}
+	return fmt.Sprintf("(%s) > %s", s.keys, s.keyRecord(last))
+}
+
+// batchHash - aggregate hash of the rows ordered by the key, empty for no rows
+func (s *syncSql) batchHash() string {
+	return fmt.Sprintf("coalesce(md5(string_agg(md5(t::text), '' ORDER BY %s)), '')", s.keys)
+}
+
+// sourceBatch - number of rows, the last key and the hash of the next batch after the key
+func (s *syncSql) sourceBatch(last string, batch int) string {
+	return fmt.Sprintf("SELECT count(*) AS sqlq_count, (array_agg(%s ORDER BY %s))[1] AS sqlq_upper, %s AS sqlq_hash "+
+		"FROM (SELECT * FROM %s AS t WHERE %s ORDER BY %s LIMIT %d) AS t",
+		s.keysJson, s.keysDesc, s.batchHash(), s.table, s.after(last), s.keys, batch)
+}
+
+// destinationBatch - hash of the rows with the keys in (last, upper]
+func (s *syncSql) destinationBatch(last string, upper string) string {
+	return fmt.Sprintf("SELECT %s AS sqlq_hash FROM %s AS t WHERE %s AND (%s) <= %s",
+		s.batchHash(), s.table, s.after(last), s.keys, s.keyRecord(upper))
+}
+
+// selectHashes - keys and hashes of the rows with the keys in (last, upper]
+func (s *syncSql) selectHashes(last string, upper string) string {
+	return fmt.Sprintf("SELECT %s AS sqlq_key, md5(t::text) AS sqlq_hash FROM %s AS t WHERE %s AND (%s) <= %s",
+		s.keysJson, s.table, s.after(last), s.keys, s.keyRecord(upper))
+}
+
+// selectRows - json encoded rows with the keys
+func (s *syncSql) selectRows(keys []string) string {
+	return fmt.Sprintf("SELECT row_to_json(t)::text AS sqlq_row FROM %s AS t WHERE (%s) IN (SELECT %s FROM jsonb_populate_recordset(NULL::%s, %s::jsonb))",
+		s.table, s.keys, s.keys, s.table, quoteLiteral("["+strings.Join(keys, ",")+"]"))
+}
+
+func (s *syncSql) apply(pool *pgxpool.Pool, ctx context.Context, removeKeys []string, insertRows []string) error {
+	if len(removeKeys) == 0 && len(insertRows) == 0 {
+		return nil
+	}
+
+	tx := NewTx(pool, ctx)
+	if err := tx.Begin(); err != nil {
+		return err
+	}
+
+	if len(removeKeys) > 0 {
+		sql := fmt.Sprintf("DELETE FROM %s WHERE (%s) IN (SELECT %s FROM jsonb_populate_recordset(NULL::%s, %s::jsonb))",
+			s.table, s.keys, s.keys, s.table, quoteLiteral("["+strings.Join(removeKeys, ",")+"]"))
+		if _, err := ExecTx(tx, sql); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	if len(insertRows) > 0 {
+		sql := fmt.Sprintf("INSERT INTO %s SELECT * FROM jsonb_populate_recordset(NULL::%s, %s::jsonb)",
+			s.table, s.table, quoteLiteral("["+strings.Join(insertRows, ",")+"]"))
+		if _, err := ExecTx(tx, sql); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,43 @@
+package sqlq
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+func TestSyncTableSkipsEqualBatches(t *testing.T) {
+	srv, pool := newTestPool(t)
+
+	s := newSyncSql("t", []string{"id"})
+	upper := `{"id": 2}`
+	srv.Expect(s.sourceBatch("", 10), sqlqtest.Result{
+		Columns: []sqlqtest.Column{{Name: "sqlq_count"}, {Name: "sqlq_upper"}, {Name: "sqlq_hash"}},
+		Rows:    [][]any{{int64(2), upper, "h"}},
+	})
+	srv.Expect(s.destinationBatch("", upper), sqlqtest.Result{
+		Columns: []sqlqtest.Column{{Name: "sqlq_hash"}},
+		Rows:    [][]any{{"h"}},
+	})
+	srv.Expect(s.sourceBatch(upper, 10), sqlqtest.Result{
+		Columns: []sqlqtest.Column{{Name: "sqlq_count"}, {Name: "sqlq_upper"}, {Name: "sqlq_hash"}},
+		Rows:    [][]any{{int64(0), nil, ""}},
+	})
+	srv.Expect("DELETE FROM "+s.table+" WHERE "+s.after(upper), sqlqtest.Result{Tag: "DELETE 0"})
+
+	res, err := SyncTable(pool, pool, context.Background(), "t", []string{"id"}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != (SyncResult{}) {
+		t.Errorf("unexpected changes: %+v", res)
+	}
+
+	for _, q := range srv.Queries() {
+		if strings.Contains(q, "row_to_json") || strings.Contains(q, "AS sqlq_key") {
+			t.Errorf("rows of the equal batch are transferred: %s", q)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package sqlq
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/n-r-w/nerr"
+)
+
+// maximum identifier length in PostgreSQL (NAMEDATALEN - 1)
+const maxIdentLength = 63
+
+// TemplateFuncs - functions available in sql templates:
+//   - ident: validated and quoted identifier, schema-qualified names (schema.table) are allowed: {{ident .Table}}
+//   - literal: quoted string literal: {{literal .Name}}
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"ident":   templateIdent,
+		"literal": templateLiteral,
+	}
+}
+
+// ParseTemplate - parse an sql template (text/template syntax) with TemplateFuncs
+func ParseTemplate(name string, text string) (*template.Template, error) {
+	t, err := template.New(name).Funcs(TemplateFuncs()).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, nerr.New(err)
+	}
+	return t, nil
+}
+
+// RenderTemplate - render sql from the template
+func RenderTemplate(t *template.Template, data any) (string, error) {
+	buf := new(bytes.Buffer)
+	if err := t.Execute(buf, data); err != nil {
+		return "", nerr.New(err)
+	}
+	return buf.String(), nil
+}
+
+func templateIdent(v any) (string, error) {
+	name := fmt.Sprint(v)
+	for _, part := range strings.Split(name, ".") {
+		if err := validateIdent(part); err != nil {
+			return "", err
+		}
+	}
+	return quoteName(name), nil
+}
+
+func templateLiteral(v any) string {
+	return quoteLiteral(fmt.Sprint(v))
+}
+
+// validateIdent - check that the string can be used as an identifier
+func validateIdent(name string) error {
+	if name == "" {
+		return fmt.Errorf("empty identifier")
+	}
+	if len(name) > maxIdentLength {
+		return fmt.Errorf("identifier is too long: %s", name)
+	}
+	if strings.ContainsRune(name, 0) {
+		return fmt.Errorf("identifier contains zero byte: %q", name)
+	}
+	return nil
+}
+
+// templateFields - names of the data fields used by the template: {{ident .Table}} -> Table
+func templateFields(t *template.Template) map[string]bool {
+	fields := make(map[string]bool)
+	for _, tt := range t.Templates() {
+		if tt.Tree != nil {
+			collectTemplateFields(tt.Tree.Root, fields)
+		}
+	}
+	return fields
+}
+
+func collectTemplateFields(node parse.Node, fields map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			collectTemplateFields(c, fields)
+		}
+	case *parse.ActionNode:
+		collectTemplateFields(n.Pipe, fields)
+	case *parse.IfNode:
+		collectTemplateFields(&n.BranchNode, fields)
+	case *parse.RangeNode:
+		collectTemplateFields(&n.BranchNode, fields)
+	case *parse.WithNode:
+		collectTemplateFields(&n.BranchNode, fields)
+	case *parse.BranchNode:
+		collectTemplateFields(n.Pipe, fields)
+		collectTemplateFields(n.List, fields)
+		collectTemplateFields(n.ElseList, fields)
+	case *parse.TemplateNode:
+		collectTemplateFields(n.Pipe, fields)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Cmds {
+			collectTemplateFields(c, fields)
+		}
+	case *parse.CommandNode:
+		for _, a := range n.Args {
+			collectTemplateFields(a, fields)
+		}
+	case *parse.ChainNode:
+		collectTemplateFields(n.Node, fields)
+	case *parse.FieldNode:
+		fields[n.Ident[0]] = true
+	}
+}
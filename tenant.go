@@ -0,0 +1,202 @@
+package sqlq
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/n-r-w/nerr"
+)
+
+// default time after which the state of an inactive tenant is evicted
+const defaultTenantIdle = 10 * time.Minute
+
+// TenantLimiter - middleware that limits the number of concurrent statements and the statement rate per tenant.
+// Each tenant has its own queue, so a busy tenant does not delay the others. The state of the tenants without
+// statements for 10 minutes (or the time to refill the burst, if longer) is evicted
+type TenantLimiter struct {
+	tenant        func(ctx context.Context) string
+	maxConcurrent int
+	rate          float64
+	burst         int
+	// inactivity time after which the tenant state is equal to a new one and can be evicted
+	idle time.Duration
+
+	mu      sync.Mutex
+	tenants map[string]*tenantState
+	// time of the last eviction of the idle tenants
+	swept time.Time
+}
+
+type tenantState struct {
+	sem chan struct{}
+	// statements of the tenant holding the state and the time the last of them finished, protected by TenantLimiter.mu
+	refs     int
+	released time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewTenantLimiter - create a tenant limiter. tenant - tenant id from the context, statements with an empty id are not limited.
+// maxConcurrent - concurrent statements per tenant (0 - unlimited), rate - statements per second (0 - unlimited), burst - rate burst size
+func NewTenantLimiter(tenant func(ctx context.Context) string, maxConcurrent int, rate float64, burst int) *TenantLimiter {
+	if burst <= 0 {
+		burst = 1
+	}
+
+	idle := defaultTenantIdle
+	if rate > 0 {
+		if refill := time.Duration(float64(burst) / rate * float64(time.Second)); refill > idle {
+			idle = refill
+		}
+	}
+
+	return &TenantLimiter{
+		tenant:        tenant,
+		maxConcurrent: maxConcurrent,
+		rate:          rate,
+		burst:         burst,
+		idle:          idle,
+		tenants:       map[string]*tenantState{},
+		swept:         time.Now(),
+	}
+}
+
+// Exec - implementation of Middleware
+func (l *TenantLimiter) Exec(next ExecFunc) ExecFunc {
+	return func(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+		release, err := l.acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+
+		return next(ctx, sql, args...)
+	}
+}
+
+// Query - implementation of Middleware. The concurrency slot is held until the rows are closed
+func (l *TenantLimiter) Query(next QueryFunc) QueryFunc {
+	return func(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+		release, err := l.acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		rows, err := next(ctx, sql, args...)
+		if err != nil {
+			release()
+			return nil, err
+		}
+
+		return &closeHookRows{Rows: rows, onClose: func(pgx.Rows) { release() }}, nil
+	}
+}
+
+// state - state of the tenant, held until unref
+func (l *TenantLimiter) state(tenant string) *tenantState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.swept) >= l.idle {
+		l.evict(now)
+	}
+
+	st, ok := l.tenants[tenant]
+	if !ok {
+		st = &tenantState{
+			tokens: float64(l.burst),
+			last:   time.Now(),
+		}
+		if l.maxConcurrent > 0 {
+			st.sem = make(chan struct{}, l.maxConcurrent)
+		}
+		l.tenants[tenant] = st
+	}
+	st.refs++
+	return st
+}
+
+func (l *TenantLimiter) unref(st *tenantState) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	st.refs--
+	st.released = time.Now()
+}
+
+// evict - remove the tenants without statements for the idle time: their tokens are refilled and no slots are taken
+func (l *TenantLimiter) evict(now time.Time) {
+	for tenant, st := range l.tenants {
+		if st.refs == 0 && now.Sub(st.released) >= l.idle {
+			delete(l.tenants, tenant)
+		}
+	}
+	l.swept = now
+}
+
+// acquire - wait for the rate and concurrency limits of the tenant
+func (l *TenantLimiter) acquire(ctx context.Context) (func(), error) {
+	tenant := l.tenant(ctx)
+	if tenant == "" {
+		return func() {}, nil
+	}
+
+	st := l.state(tenant)
+
+	if l.rate > 0 {
+		if err := st.waitToken(ctx, l.rate, l.burst); err != nil {
+			l.unref(st)
+			return nil, err
+		}
+	}
+
+	if st.sem == nil {
+		return func() { l.unref(st) }, nil
+	}
+
+	select {
+	case st.sem <- struct{}{}:
+		return func() {
+			<-st.sem
+			l.unref(st)
+		}, nil
+	case <-ctx.Done():
+		l.unref(st)
+		return nil, nerr.New(ctx.Err())
+	}
+}
+
+// waitToken - token bucket
+func (st *tenantState) waitToken(ctx context.Context, rate float64, burst int) error {
+	for {
+		st.mu.Lock()
+		now := time.Now()
+		st.tokens += now.Sub(st.last).Seconds() * rate
+		if st.tokens > float64(burst) {
+			st.tokens = float64(burst)
+		}
+		st.last = now
+
+		if st.tokens >= 1 {
+			st.tokens--
+			st.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - st.tokens) / rate * float64(time.Second))
+		st.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nerr.New(ctx.Err())
+		}
+	}
+}
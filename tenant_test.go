@@ -0,0 +1,60 @@
+package sqlq
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type tenantKey struct{}
+
+func TestTenantLimiterEvictsIdleTenants(t *testing.T) {
+	l := NewTenantLimiter(func(ctx context.Context) string {
+		s, _ := ctx.Value(tenantKey{}).(string)
+		return s
+	}, 1, 0, 0)
+	l.idle = time.Millisecond
+
+	busy, err := l.acquire(context.WithValue(context.Background(), tenantKey{}, "busy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tenant := range []string{"a", "b", "c"} {
+		release, err := l.acquire(context.WithValue(context.Background(), tenantKey{}, tenant))
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	release, err := l.acquire(context.WithValue(context.Background(), tenantKey{}, "d"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	l.mu.Lock()
+	n := len(l.tenants)
+	_, ok := l.tenants["busy"]
+	l.mu.Unlock()
+
+	// the idle tenants are evicted, the tenant with a running statement keeps its slot
+	if n != 2 || !ok {
+		t.Errorf("got %d tenants, busy kept %v", n, ok)
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), tenantKey{}, "busy"), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.acquire(ctx); err == nil {
+		t.Error("busy tenant limit is lost")
+	}
+	busy()
+}
+
+func TestTenantLimiterIdleCoversRefill(t *testing.T) {
+	l := NewTenantLimiter(func(ctx context.Context) string { return "" }, 0, 0.001, 10)
+	if l.idle < 10000*time.Second {
+		t.Errorf("idle %v is shorter than the refill time", l.idle)
+	}
+}
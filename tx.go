@@ -2,19 +2,94 @@ package sqlq
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/n-r-w/nerr"
 )
 
-// Tx - working with nested transactions
+// Tx - working with nested transactions. Tx can be shared between goroutines, but statements are executed one at a time:
+// a statement started while another one is running (or its rows are not closed) fails with ErrTxBusy
 type Tx struct {
 	pool *pgxpool.Pool
 
 	ctx     context.Context
 	counter int
-	tx      pgx.Tx
+	// active transaction or savepoint of the current nesting level
+	tx pgx.Tx
+	// transactions of the outer levels
+	outer []pgx.Tx
+	// OnCommit/OnRollback hooks and the after commit queue of each nesting level
+	hooks      []txHooks
+	dispatcher AfterCommitDispatcher
+	// start time of each nesting level
+	started     []time.Time
+	metrics     TxMetrics
+	stmtMetrics StatementMetrics
+	auditSink   AuditSink
+	// receiver of the notices of the statements
+	noticeHandler NoticeHandler
+	// convert panics in Run to PanicError
+	recoverPanic bool
+	logger       Logger
+	metadata     map[string]any
+
+	// protects the transaction state
+	mu sync.Mutex
+	// a statement is being executed or its rows are not closed
+	busy bool
+	// number of the last reserved statement
+	useSeq uint64
+	// closed when the outermost transaction completes
+	watchDone chan struct{}
+	// 1 - the context is done, the transaction is rolled back or waits for canceledTx rollback
+	canceled int32
+	// outermost transaction canceled while a statement was running. The connection is owned by the statement,
+	// the transaction is rolled back when the statement releases it
+	canceledTx pgx.Tx
+
+	// fingerprints of the statements executed in the transaction
+	journal        []string
+	journalDropped int
+
+	mws              []Middleware
+	localSettings    map[string]string
+	stmtCache        *StatementCache
+	statementTimeout time.Duration
+}
+
+// maximum number of statements in the transaction journal, older statements are dropped
+const maxJournalSize = 100
+
+// CommitError - commit failure with the statements executed in the transaction
+type CommitError struct {
+	Err error
+	// Statements - fingerprints of the statements in the order of execution (the last maxJournalSize)
+	Statements []string
+	// Dropped - number of older statements that are not in the list
+	Dropped int
+}
+
+func (e *CommitError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "commit failed: %v; statements:", e.Err)
+	if e.Dropped > 0 {
+		fmt.Fprintf(&b, " (%d earlier omitted)", e.Dropped)
+	}
+	for i, s := range e.Statements {
+		fmt.Fprintf(&b, "\n%d: %s", i+1+e.Dropped, s)
+	}
+	return b.String()
+}
+
+func (e *CommitError) Unwrap() error {
+	return e.Err
 }
 
 // NewTxNestedPool - create a nested transaction management object
@@ -33,11 +108,44 @@ func (t *Tx) Pool() *pgxpool.Pool {
 
 // Tx - active transaction
 func (t *Tx) Tx() pgx.Tx {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	return t.tx
 }
 
+// WithLocalSettings - settings (work_mem, planner options etc.) applied with SET LOCAL before each statement
+// executed in the transaction. Query settings take precedence
+func (t *Tx) WithLocalSettings(settings map[string]string) *Tx {
+	t.localSettings = settings
+	return t
+}
+
+// SetLocal - change the setting (work_mem, lock_timeout, role etc.) until the end of the active transaction.
+// The change is reverted at commit or rollback, in a nested transaction also at the rollback to its savepoint
+func (t *Tx) SetLocal(name, value string) error {
+	return NewQueryTx(t, t.ctx).Exec(setLocalSql(map[string]string{name: value}))
+}
+
+// SetStatementCache - statement cache for the queries of the transaction, see Query.SetStatementCache
+func (t *Tx) SetStatementCache(cache *StatementCache) {
+	t.stmtCache = cache
+}
+
+// SetStatementTimeout - statement_timeout set with SET LOCAL right after the outermost transaction starts.
+// Applies to the transactions started after the call, 0 - server default
+func (t *Tx) SetStatementTimeout(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.statementTimeout = d
+}
+
 // Level - nesting level. 0 - no transaction
 func (t *Tx) Level() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	return t.counter
 }
 
@@ -46,19 +154,52 @@ func (t *Tx) Context() context.Context {
 	return t.ctx
 }
 
-// Begin - start a transaction. If the transaction has already started, it is returned
+// Begin - start a transaction. If the transaction has already started, a nested transaction is started with a savepoint
 func (t *Tx) Begin() error {
 	return t.BeginTx(pgx.ReadCommitted, pgx.ReadWrite)
 }
 
-// Begin - start a transaction. If the transaction has already started, it is returned
+// BeginReadOnly - start a read only transaction. If the transaction has already started, a nested transaction
+// is started with a savepoint and the access mode of the outer transaction is kept
+func (t *Tx) BeginReadOnly() error {
+	return t.BeginTx(pgx.ReadCommitted, pgx.ReadOnly)
+}
+
+// BeginTx - start a transaction. If the transaction has already started, a nested transaction is started with a savepoint,
+// level and mode are ignored.
+// When the context of the outermost transaction is done, the transaction is rolled back in the background
+// (after the running statement, if any, completes), the following statements, Begin and Commit return ErrTxCanceled
 func (t *Tx) BeginTx(level pgx.TxIsoLevel, mode pgx.TxAccessMode) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	if t.counter > 0 {
+		if err := t.active(); err != nil {
+			return err
+		}
+		if t.busy {
+			return nerr.New(ErrTxBusy)
+		}
+
+		nested, err := t.tx.Begin(t.ctx)
+		if err != nil {
+			return nerr.New(err)
+		}
+
+		t.outer = append(t.outer, t.tx)
+		t.tx = nested
 		t.counter++
+		t.pushHooks()
+		t.beginLevel()
 		return nil
 	}
 
-	tx, err := t.pool.BeginTx(t.ctx, pgx.TxOptions{
+	if t.canceledTx != nil {
+		// the statement of the canceled transaction is still running
+		return nerr.New(ErrTxBusy)
+	}
+
+	tx, err := beginPoolTx(t.pool, t.ctx, pgx.TxOptions{
 		IsoLevel:       level,
 		AccessMode:     mode,
 		DeferrableMode: "",
@@ -67,39 +208,270 @@ func (t *Tx) BeginTx(level pgx.TxIsoLevel, mode pgx.TxAccessMode) error {
 		return nerr.New(err)
 	}
 
+	if t.statementTimeout > 0 {
+		ms := t.statementTimeout.Milliseconds()
+		if ms == 0 {
+			// 0 means no timeout
+			ms = 1
+		}
+		if _, err := tx.Exec(t.ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", ms)); err != nil {
+			_ = tx.Rollback(t.ctx)
+			return nerr.New(err)
+		}
+	}
+
 	t.tx = tx
 	t.counter++
+	t.journal = nil
+	t.journalDropped = 0
+	t.hooks = nil
+	t.pushHooks()
+	t.started = nil
+	t.beginLevel()
+	atomic.StoreInt32(&t.canceled, 0)
+	t.watch(tx)
 	return nil
 }
 
-// Commit - complete the transaction. If there are nested transactions, the operation is ignored
+// Journal - fingerprints of the statements executed in the current transaction
+func (t *Tx) Journal() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]string{}, t.journal...)
+}
+
+// record - add the statement to the journal. Must be called under the lock
+func (t *Tx) record(sql string) {
+	if len(t.journal) >= maxJournalSize {
+		t.journal = t.journal[1:]
+		t.journalDropped++
+	}
+	t.journal = append(t.journal, Fingerprint(sql))
+}
+
+// Commit - complete the transaction. The nested transaction releases its savepoint, its changes are committed
+// with the outer transaction
 func (t *Tx) Commit() error {
+	t.mu.Lock()
+	hooks, err := t.commit()
+	t.mu.Unlock()
+
+	for _, fn := range hooks {
+		fn()
+	}
+	return err
+}
+
+func (t *Tx) commit() ([]func(), error) {
 	if t.counter == 0 {
-		return nerr.New("no transaction to commit")
+		return nil, nerr.New("no transaction to commit")
 	}
 
-	t.counter--
-	if t.counter > 0 {
-		return nil
+	if err := t.active(); err != nil {
+		return t.reset(), err
+	}
+	if t.busy {
+		return nil, nerr.New(ErrTxBusy)
+	}
+
+	if t.counter > 1 {
+		err := t.tx.Commit(t.ctx)
+		t.endLevel(err == nil)
+		t.popLevel()
+		return t.popHooks(err == nil), nerr.New(err)
 	}
 
+	t.stopWatch()
+	t.counter--
 	err := t.tx.Commit(t.ctx)
 	t.tx = nil
-	return nerr.New(err)
+	t.endLevel(err == nil)
+	hooks := t.popHooks(err == nil)
+	if err != nil {
+		return hooks, nerr.New(&CommitError{Err: err, Statements: t.journal, Dropped: t.journalDropped})
+	}
+	return hooks, nil
 }
 
-// Rollback - roll back the transaction. The counter of nested transactions is reset, because the rollback cannot be partial
+// Rollback - roll back the transaction. The nested transaction is rolled back to its savepoint,
+// the outer transaction stays active. Unlike Commit, Rollback is executed even if a statement is running
+// or its rows are not closed
 func (t *Tx) Rollback() error {
+	t.mu.Lock()
+	hooks, err := t.rollback()
+	t.mu.Unlock()
+
+	for _, fn := range hooks {
+		fn()
+	}
+	return err
+}
+
+func (t *Tx) rollback() ([]func(), error) {
 	if t.counter == 0 {
-		return nerr.New("no transaction to rollback")
+		return nil, nerr.New("no transaction to rollback")
 	}
 
+	if t.active() != nil {
+		// canceled: rolled back by the context watcher or when the running statement completes
+		return t.reset(), nil
+	}
+
+	// not blocked by a running statement: the transaction must not stay open after a failure,
+	// pgx closes the connection if the rollback can't be executed
+	if t.counter > 1 {
+		err := t.tx.Rollback(t.ctx)
+		t.endLevel(false)
+		t.popLevel()
+		return t.popHooks(false), nerr.New(err)
+	}
+
+	t.stopWatch()
 	t.counter = 0
 	err := t.tx.Rollback(t.ctx)
 	t.tx = nil
+	t.busy = false
+	t.endLevel(false)
+	hooks := t.popHooks(false)
 	if err != nil {
-		return nerr.New(err)
+		return hooks, nerr.New(err)
 	} else {
-		return nil
+		return hooks, nil
+	}
+}
+
+// popLevel - return to the outer transaction
+func (t *Tx) popLevel() {
+	t.tx = t.outer[len(t.outer)-1]
+	t.outer = t.outer[:len(t.outer)-1]
+	t.counter--
+}
+
+// reset - forget the transaction rolled back by the context watcher, returns the rollback hooks of all levels
+func (t *Tx) reset() []func() {
+	t.stopWatch()
+
+	var hooks []func()
+	for len(t.hooks) > 0 {
+		t.endLevel(false)
+		hooks = append(hooks, t.popHooks(false)...)
+	}
+	t.tx = nil
+	t.outer = nil
+	t.counter = 0
+	t.busy = false
+	return hooks
+}
+
+// ErrTxBusy - the transaction is used by another statement: concurrent use from several goroutines
+// or a statement executed while the rows of the previous one are not closed
+var ErrTxBusy = errors.New("transaction is busy with another statement")
+
+// use - reserve the transaction for a statement, the statements are added to the journal.
+// release must be called when the statement completes
+func (t *Tx) use(sqls ...string) (pgx.Tx, func(), error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.counter == 0 {
+		return nil, nil, nerr.New("no active transaction")
 	}
+	if err := t.active(); err != nil {
+		return nil, nil, err
+	}
+	if t.busy {
+		return nil, nil, nerr.New(ErrTxBusy)
+	}
+
+	t.busy = true
+	t.useSeq++
+	seq := t.useSeq
+	for _, sql := range sqls {
+		t.record(sql)
+	}
+
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			// the transaction could be rolled back in the meantime
+			if t.useSeq == seq {
+				t.busy = false
+			}
+			// canceled while the statement was running: the connection is free now
+			if t.canceledTx != nil {
+				rollbackCanceled(t.canceledTx)
+				t.canceledTx = nil
+			}
+		})
+	}
+	return t.tx, release, nil
+}
+
+// ErrTxCanceled - the transaction was rolled back because its context is done
+var ErrTxCanceled = errors.New("transaction context is done, the transaction was rolled back")
+
+// maximum duration of the rollback of a canceled transaction
+const cancelRollbackTimeout = 5 * time.Second
+
+// watch - roll back the transaction when the context is done. The connection is not safe for concurrent use,
+// so a running statement is not interrupted by the watcher: it fails through its own context, the transaction
+// is rolled back when the statement releases it
+func (t *Tx) watch(tx pgx.Tx) {
+	if t.ctx.Done() == nil {
+		return
+	}
+
+	done := make(chan struct{})
+	t.watchDone = done
+
+	go func() {
+		select {
+		case <-done:
+		case <-t.ctx.Done():
+			t.mu.Lock()
+			defer t.mu.Unlock()
+
+			select {
+			case <-done:
+				// completed while waiting for the lock
+				return
+			default:
+			}
+
+			atomic.StoreInt32(&t.canceled, 1)
+			if t.busy {
+				t.canceledTx = tx
+				return
+			}
+			rollbackCanceled(tx)
+		}
+	}()
+}
+
+// rollbackCanceled - roll back the transaction whose context is done. Must be called under the lock by the owner
+// of the connection
+func rollbackCanceled(tx pgx.Tx) {
+	ctx, cancel := context.WithTimeout(context.Background(), cancelRollbackTimeout)
+	defer cancel()
+	_ = tx.Rollback(ctx)
+}
+
+// stopWatch - stop the context watcher of the completed transaction
+func (t *Tx) stopWatch() {
+	if t.watchDone != nil {
+		close(t.watchDone)
+		t.watchDone = nil
+	}
+}
+
+// active - error if the transaction was rolled back by the context watcher
+func (t *Tx) active() error {
+	if atomic.LoadInt32(&t.canceled) != 0 {
+		return nerr.New(fmt.Errorf("%w: %v", ErrTxCanceled, t.ctx.Err()))
+	}
+	return nil
 }
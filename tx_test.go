@@ -0,0 +1,169 @@
+package sqlq
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+// rollbacks - number of ROLLBACK statements executed by the server
+func rollbacks(srv *sqlqtest.FakePostgres) int {
+	n := 0
+	for _, q := range srv.Queries() {
+		if strings.EqualFold(strings.TrimSpace(q), "rollback") {
+			n++
+		}
+	}
+	return n
+}
+
+func TestTxCancelWaitsForRunningStatement(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect("SELECT 1 AS a", sqlqtest.Result{
+		Columns: []sqlqtest.Column{{Name: "a"}},
+		Rows:    [][]any{{1}},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tx := NewTx(pool, ctx)
+	if err := tx.Begin(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the statement has its own context and keeps the connection until the rows are closed
+	rows, err := NewQueryTx(tx, context.Background()).querySql(context.Background(), "SELECT 1 AS a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	if n := rollbacks(srv); n != 0 {
+		t.Fatalf("rolled back while the statement is running: %d", n)
+	}
+	if err := NewQueryTx(tx, context.Background()).Exec("SELECT 1 AS a"); !errors.Is(err, ErrTxCanceled) {
+		t.Fatalf("expected ErrTxCanceled, got %v", err)
+	}
+
+	rows.Close()
+	if n := rollbacks(srv); n != 1 {
+		t.Fatalf("expected rollback after the statement, got %d", n)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if pool.Stat().AcquiredConns() != 0 {
+		t.Error("connection is not released")
+	}
+}
+
+func TestTxCancelIdle(t *testing.T) {
+	srv, pool := newTestPool(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tx := NewTx(pool, ctx)
+	if err := tx.Begin(); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for pool.Stat().AcquiredConns() != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if pool.Stat().AcquiredConns() != 0 {
+		t.Fatal("canceled transaction is not rolled back")
+	}
+	if n := rollbacks(srv); n != 1 {
+		t.Fatalf("expected 1 rollback, got %d", n)
+	}
+	if err := tx.Commit(); !errors.Is(err, ErrTxCanceled) {
+		t.Fatalf("expected ErrTxCanceled, got %v", err)
+	}
+}
+
+func TestTxNestedSavepoints(t *testing.T) {
+	srv, pool := newTestPool(t)
+	srv.Expect("UPDATE t SET a = 1", sqlqtest.Result{Tag: "UPDATE 1"})
+	srv.ExpectError("UPDATE t SET b = 1", "23505", "duplicate key value")
+	srv.Expect("UPDATE t SET c = 1", sqlqtest.Result{Tag: "UPDATE 1"})
+
+	tx := NewTx(pool, context.Background())
+	q := NewQueryTx(tx, tx.ctx)
+	if err := tx.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Exec("UPDATE t SET a = 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the failed nested transaction is rolled back to its savepoint
+	if err := tx.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	if tx.Level() != 2 {
+		t.Errorf("level %d, want 2", tx.Level())
+	}
+	if err := q.Exec("UPDATE t SET b = 1"); SqlState(err) != "23505" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the outer transaction stays usable
+	if tx.Level() != 1 {
+		t.Errorf("level %d, want 1", tx.Level())
+	}
+	if err := tx.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Exec("UPDATE t SET c = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if tx.Level() != 0 {
+		t.Errorf("level %d, want 0", tx.Level())
+	}
+
+	var got []string
+	for _, sql := range srv.Queries() {
+		got = append(got, strings.ToLower(sql))
+	}
+	want := []string{
+		"begin isolation level read committed read write",
+		"update t set a = 1",
+		"savepoint sp_1",
+		"update t set b = 1",
+		"rollback to savepoint sp_1",
+		"savepoint sp_2",
+		"update t set c = 1",
+		"release savepoint sp_2",
+		"commit",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestTxCommitWithoutTransaction(t *testing.T) {
+	_, pool := newTestPool(t)
+	tx := NewTx(pool, context.Background())
+
+	if err := tx.Commit(); err == nil {
+		t.Error("commit: expected error")
+	}
+	if err := tx.Rollback(); err == nil {
+		t.Error("rollback: expected error")
+	}
+}
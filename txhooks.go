@@ -0,0 +1,105 @@
+package sqlq
+
+import "github.com/n-r-w/nerr"
+
+// hooks of a transaction nesting level
+type txHooks struct {
+	onCommit   []func()
+	onRollback []func()
+	// payloads of the after commit queue
+	queue []any
+}
+
+// AfterCommitDispatcher - receives the payloads queued with Tx.AfterCommit in the order of queuing
+type AfterCommitDispatcher func(payloads []any)
+
+// SetAfterCommitDispatcher - dispatcher of the after commit queue (outbox, message broker publisher).
+// It is called once after the outermost transaction commits successfully, before Commit returns
+func (t *Tx) SetAfterCommitDispatcher(dispatcher AfterCommitDispatcher) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.dispatcher = dispatcher
+}
+
+// AfterCommit - queue the payload that is passed to the dispatcher only after the outermost transaction commits.
+// The payloads keep the order of queuing across nesting levels, the payloads of the nested transaction
+// rolled back to its savepoint are discarded
+func (t *Tx) AfterCommit(payload any) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.counter == 0 {
+		return nerr.New("no active transaction")
+	}
+	if t.dispatcher == nil {
+		return nerr.New("after commit dispatcher is not set")
+	}
+
+	h := &t.hooks[len(t.hooks)-1]
+	h.queue = append(h.queue, payload)
+	return nil
+}
+
+// OnCommit - register fn that is called after the outermost transaction commits successfully (cache invalidation,
+// event publishing). Functions are called in the order of registration. If the nested transaction that registered fn
+// is rolled back to its savepoint, fn is discarded
+func (t *Tx) OnCommit(fn func()) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.counter == 0 {
+		return nerr.New("no active transaction")
+	}
+
+	h := &t.hooks[len(t.hooks)-1]
+	h.onCommit = append(h.onCommit, fn)
+	return nil
+}
+
+// OnRollback - register fn that is called when the changes of the current nesting level are rolled back:
+// the nested transaction is rolled back to its savepoint, the outermost transaction is rolled back or fails to commit
+func (t *Tx) OnRollback(fn func()) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.counter == 0 {
+		return nerr.New("no active transaction")
+	}
+
+	h := &t.hooks[len(t.hooks)-1]
+	h.onRollback = append(h.onRollback, fn)
+	return nil
+}
+
+// pushHooks - hooks of the new nesting level
+func (t *Tx) pushHooks() {
+	t.hooks = append(t.hooks, txHooks{})
+}
+
+// popHooks - complete the hooks of the current nesting level. Committed nested level passes its hooks to the outer level,
+// committed outermost level returns the commit hooks, rolled back level returns the rollback hooks.
+// The hooks are called by the caller after the transaction lock is released
+func (t *Tx) popHooks(committed bool) []func() {
+	h := t.hooks[len(t.hooks)-1]
+	t.hooks = t.hooks[:len(t.hooks)-1]
+
+	switch {
+	case committed && len(t.hooks) > 0:
+		outer := &t.hooks[len(t.hooks)-1]
+		outer.onCommit = append(outer.onCommit, h.onCommit...)
+		outer.onRollback = append(outer.onRollback, h.onRollback...)
+		outer.queue = append(outer.queue, h.queue...)
+		return nil
+
+	case committed:
+		if len(h.queue) > 0 && t.dispatcher != nil {
+			dispatcher, queue := t.dispatcher, h.queue
+			return append(h.onCommit, func() { dispatcher(queue) })
+		}
+		return h.onCommit
+
+	default:
+		return h.onRollback
+	}
+}
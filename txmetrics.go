@@ -0,0 +1,65 @@
+package sqlq
+
+import (
+	"sync"
+	"time"
+)
+
+// TxMetrics - receiver of the transaction metrics, e.g. an adapter to Prometheus collectors.
+// The methods are called synchronously under the transaction lock, they must be fast and must not use the transaction
+type TxMetrics interface {
+	// TxStarted - the transaction started. depth - nesting level, 1 - the outermost transaction
+	TxStarted(depth int)
+	// TxFinished - the transaction of the nesting level completed: committed (the savepoint of the nested transaction
+	// is released) or rolled back, including failed commits and rollbacks after the context is done
+	TxFinished(depth int, committed bool, duration time.Duration)
+}
+
+var (
+	globalTxMetricsMu sync.RWMutex
+	globalTxMetrics   TxMetrics
+)
+
+// SetTxMetrics - metrics receiver for all transactions. nil - disable
+func SetTxMetrics(m TxMetrics) {
+	globalTxMetricsMu.Lock()
+	defer globalTxMetricsMu.Unlock()
+	globalTxMetrics = m
+}
+
+// SetMetrics - metrics receiver of the transaction instead of the one set with SetTxMetrics
+func (t *Tx) SetMetrics(m TxMetrics) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.metrics = m
+}
+
+// txMetrics - metrics receiver of the transaction, nil if not set
+func (t *Tx) txMetrics() TxMetrics {
+	if t.metrics != nil {
+		return t.metrics
+	}
+
+	globalTxMetricsMu.RLock()
+	defer globalTxMetricsMu.RUnlock()
+	return globalTxMetrics
+}
+
+// beginLevel - start time of the new nesting level
+func (t *Tx) beginLevel() {
+	t.started = append(t.started, time.Now())
+	if m := t.txMetrics(); m != nil {
+		m.TxStarted(len(t.started))
+	}
+}
+
+// endLevel - complete the current nesting level
+func (t *Tx) endLevel(committed bool) {
+	depth := len(t.started)
+	start := t.started[depth-1]
+	t.started = t.started[:depth-1]
+
+	if m := t.txMetrics(); m != nil {
+		m.TxFinished(depth, committed, time.Since(start))
+	}
+}
@@ -0,0 +1,147 @@
+package sqlq
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime/debug"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/n-r-w/nerr"
+)
+
+// RunInTx - execute fn in a transaction: commit if fn returns nil, rollback if it returns an error or panics.
+// The panic is raised again after the rollback, see Tx.SetRecoverPanic to get it as an error
+func RunInTx(pool *pgxpool.Pool, ctx context.Context, fn func(tx *Tx) error) error {
+	return NewTx(pool, ctx).Run(fn)
+}
+
+// RunInReadTx - RunInTx with a read only transaction
+func RunInReadTx(pool *pgxpool.Pool, ctx context.Context, fn func(tx *Tx) error) error {
+	return NewTx(pool, ctx).run(pgx.ReadCommitted, pgx.ReadOnly, fn)
+}
+
+// Run - execute fn in a transaction, nested if the transaction has already started. See RunInTx
+func (t *Tx) Run(fn func(tx *Tx) error) error {
+	return t.run(pgx.ReadCommitted, pgx.ReadWrite, fn)
+}
+
+// PanicError - panic in the function executed by Run, see Tx.SetRecoverPanic
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in transaction: %v", e.Value)
+}
+
+// SetRecoverPanic - return a panic in the function executed by Run as PanicError instead of raising it again
+// after the rollback
+func (t *Tx) SetRecoverPanic(enabled bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.recoverPanic = enabled
+}
+
+func (t *Tx) run(level pgx.TxIsoLevel, mode pgx.TxAccessMode, fn func(tx *Tx) error) (err error) {
+	if err := t.BeginTx(level, mode); err != nil {
+		return err
+	}
+	depth := t.Level()
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = t.rollbackTo(depth)
+
+			t.mu.Lock()
+			recoverPanic := t.recoverPanic
+			t.mu.Unlock()
+			if !recoverPanic {
+				panic(p)
+			}
+			err = nerr.New(&PanicError{Value: p, Stack: debug.Stack()})
+		}
+	}()
+
+	if err := fn(t); err != nil {
+		if rbErr := t.rollbackTo(depth); rbErr != nil {
+			return nerr.New(fmt.Errorf("%w; rollback: %v", err, rbErr))
+		}
+		return err
+	}
+
+	if level := t.Level(); level != depth {
+		if rbErr := t.rollbackTo(depth); rbErr != nil {
+			return nerr.New(fmt.Errorf("transaction level changed inside Run: %d, expected %d; rollback: %v", level, depth, rbErr))
+		}
+		return nerr.New(fmt.Errorf("transaction level changed inside Run: %d, expected %d", level, depth))
+	}
+	return t.Commit()
+}
+
+// rollbackTo - roll back the transaction of the level and the nested transactions started after it.
+// Nothing is done if the level has already been finished. Returns the first rollback error
+func (t *Tx) rollbackTo(depth int) error {
+	var res error
+	// each rollback finishes the level, even if it fails
+	for t.Level() >= depth && t.Level() > 0 {
+		if err := t.Rollback(); err != nil && res == nil {
+			res = err
+		}
+	}
+	return res
+}
+
+// RetryOptions - retry policy of RunInTxRetry
+type RetryOptions struct {
+	// MaxAttempts - maximum number of executions, 0 - 3
+	MaxAttempts int
+	// BaseDelay - delay before the first retry, doubled for each next one. 0 - 50ms
+	BaseDelay time.Duration
+	// MaxDelay - maximum delay between retries. 0 - 2s
+	MaxDelay time.Duration
+}
+
+// RunInTxRetry - RunInTx that executes the whole transaction again if it fails with a serialization failure (40001)
+// or a deadlock (40P01). Delays between attempts grow exponentially with a random jitter.
+// fn must not have side effects outside of the transaction, it can be called several times
+func RunInTxRetry(pool *pgxpool.Pool, ctx context.Context, opts RetryOptions, fn func(tx *Tx) error) error {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = 50 * time.Millisecond
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = 2 * time.Second
+	}
+
+	delay := opts.BaseDelay
+	for attempt := 1; ; attempt++ {
+		err := RunInTx(pool, ctx, fn)
+		if err == nil || attempt >= opts.MaxAttempts || !isRetryableTxError(err) {
+			return err
+		}
+
+		// full jitter
+		wait := time.Duration(rand.Int63n(int64(delay)) + 1)
+		select {
+		case <-ctx.Done():
+			return nerr.New(fmt.Errorf("%w; retry canceled: %v", err, ctx.Err()))
+		case <-time.After(wait):
+		}
+
+		if delay *= 2; delay > opts.MaxDelay {
+			delay = opts.MaxDelay
+		}
+	}
+}
+
+// isRetryableTxError - serialization failure or deadlock, the transaction can be executed again
+func isRetryableTxError(err error) bool {
+	return IsSerializationFailure(err) || IsDeadlock(err)
+}
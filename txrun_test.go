@@ -0,0 +1,166 @@
+package sqlq
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+func newTestPool(t testing.TB) (*sqlqtest.FakePostgres, *pgxpool.Pool) {
+	t.Helper()
+
+	srv, err := sqlqtest.NewFakePostgres()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = srv.Close() })
+
+	pool, err := pgxpool.Connect(context.Background(), srv.ConnString())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	return srv, pool
+}
+
+func TestRunUnwindsNestedTransactions(t *testing.T) {
+	_, pool := newTestPool(t)
+	failure := errors.New("failure")
+
+	tests := []struct {
+		name string
+		ret  error
+	}{
+		{"error", failure},
+		{"nil", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := NewTx(pool, context.Background())
+			err := tx.Run(func(tx *Tx) error {
+				// nested transactions left open
+				if err := tx.Begin(); err != nil {
+					return err
+				}
+				if err := tx.Begin(); err != nil {
+					return err
+				}
+				return tt.ret
+			})
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if tt.ret != nil && !errors.Is(err, failure) {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tx.Level() != 0 {
+				t.Errorf("transaction is not rolled back: level %d", tx.Level())
+			}
+			if pool.Stat().AcquiredConns() != 0 {
+				t.Errorf("connection is not released")
+			}
+		})
+	}
+}
+
+// failingStatement - handler of the statement that fails with the code the first failures times
+type failingStatement struct {
+	sql      string
+	code     string
+	failures int
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (f *failingStatement) handle(sql string, args []any) (sqlqtest.Result, bool) {
+	if sql != f.sql {
+		return sqlqtest.Result{}, false
+	}
+	if args == nil {
+		return sqlqtest.Result{}, true
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls++
+	if f.calls <= f.failures {
+		return sqlqtest.Result{Err: &pgconn.PgError{Severity: "ERROR", Code: f.code, Message: "failure"}}, true
+	}
+	return sqlqtest.Result{Tag: "UPDATE 1"}, true
+}
+
+func (f *failingStatement) executed() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls
+}
+
+func TestRunInTxRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		code      string
+		failures  int
+		wantCalls int
+		wantErr   bool
+	}{
+		{"serialization failure", "40001", 2, 3, false},
+		{"deadlock", "40P01", 1, 2, false},
+		{"attempts exceeded", "40001", 3, 3, true},
+		{"not retryable", "23505", 1, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, pool := newTestPool(t)
+			stmt := &failingStatement{sql: "UPDATE t SET a = $1", code: tt.code, failures: tt.failures}
+			srv.Handle(stmt.handle)
+
+			err := RunInTxRetry(pool, context.Background(), RetryOptions{BaseDelay: time.Millisecond},
+				func(tx *Tx) error {
+					return NewQueryTx(tx, tx.ctx).ExecArgs("UPDATE t SET a = $1", 1)
+				})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr && SqlState(err) != tt.code {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if n := stmt.executed(); n != tt.wantCalls {
+				t.Errorf("%d executions, want %d", n, tt.wantCalls)
+			}
+			if pool.Stat().AcquiredConns() != 0 {
+				t.Errorf("connection is not released")
+			}
+		})
+	}
+}
+
+func TestRunInTxRetryCanceled(t *testing.T) {
+	srv, pool := newTestPool(t)
+	stmt := &failingStatement{sql: "UPDATE t SET a = $1", code: "40001", failures: 10}
+	srv.Handle(stmt.handle)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err := RunInTxRetry(pool, ctx, RetryOptions{MaxAttempts: 10, BaseDelay: time.Hour}, func(tx *Tx) error {
+		// canceled before the delay, not in the transaction
+		defer cancel()
+		return NewQueryTx(tx, context.Background()).ExecArgs("UPDATE t SET a = $1", 1)
+	})
+	if !IsSerializationFailure(err) || !strings.Contains(err.Error(), "retry canceled") {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if n := stmt.executed(); n != 1 {
+		t.Errorf("%d executions, want 1", n)
+	}
+}
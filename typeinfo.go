@@ -0,0 +1,23 @@
+package sqlq
+
+import (
+	"github.com/jackc/pgtype"
+)
+
+// connInfo - type information for the fields of the selection. Captured from the connection of Select,
+// the builtin types if the rows didn't come from a connection (FromPgxRows, request cache, middleware results)
+func (q *Query) connInfo() *pgtype.ConnInfo {
+	if q.typeInfo != nil {
+		return q.typeInfo
+	}
+	return scanConnInfo
+}
+
+// fieldDataType - type of the field of the selection
+func (q *Query) fieldDataType(index int) (*pgtype.DataType, bool) {
+	fields := q.Fields()
+	if index < 0 || index >= len(fields) {
+		return nil, false
+	}
+	return q.connInfo().DataTypeForOID(fields[index].DataTypeOID)
+}
@@ -0,0 +1,67 @@
+package sqlq
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+func TestFieldTypeFromSelectConnection(t *testing.T) {
+	srv, err := sqlqtest.NewFakePostgres()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = srv.Close() })
+
+	const moodOID = 90001
+	cfg, err := pgxpool.ParseConfig(srv.ConnString())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		conn.ConnInfo().RegisterDataType(pgtype.DataType{Value: &pgtype.Text{}, Name: "mood", OID: moodOID})
+		return nil
+	}
+	pool, err := pgxpool.ConnectConfig(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	srv.Expect("SELECT mood FROM t", sqlqtest.Result{
+		Columns: []sqlqtest.Column{{Name: "mood", OID: moodOID}},
+		Rows:    [][]any{{nil}},
+	})
+
+	q := NewQuery(pool, context.Background())
+	if err := q.Select("SELECT mood FROM t"); err != nil {
+		t.Fatal(err)
+	}
+	acquired := pool.Stat().AcquireCount()
+
+	if got := q.FieldTypeNameIndex(0); got != "mood" {
+		t.Errorf("active select: got %q, want mood", got)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := q.FieldTypeIndex(0); got != moodOID {
+		t.Errorf("closed select: got %d, want %d", got, moodOID)
+	}
+	if n := pool.Stat().AcquireCount(); n != acquired {
+		t.Errorf("type lookup acquired %d connections", n-acquired)
+	}
+}
+
+func TestFieldTypeBuiltinWithoutConnection(t *testing.T) {
+	q := FromPgxRows(newCachedRows(scanUserRows(1)))
+	defer q.Release()
+
+	if got := q.FieldTypeNameIndex(0); got != "int8" {
+		t.Errorf("got %q, want int8", got)
+	}
+}
@@ -0,0 +1,349 @@
+package sqlq
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"github.com/n-r-w/nerr"
+)
+
+const (
+	// number of rows from which UpsertMany loads the rows with COPY into a temporary table
+	upsertCopyThreshold = 1000
+	// maximum number of parameters of a statement in the extended protocol
+	maxStatementParams = 65535
+	// maximum number of row errors in the UpsertError message
+	maxUpsertErrorsInMessage = 10
+)
+
+// number of the last temporary table of UpsertMany, for unique table names
+var upsertCounter uint64
+
+// errUpsertCheck - rolls back the savepoint of the row check
+var errUpsertCheck = errors.New("upsert row check")
+
+// UpsertError - rows of UpsertMany that can't be inserted or updated
+type UpsertError struct {
+	// Err - error of the bulk statement
+	Err error
+	// Rows - error of each failed row by its index
+	Rows map[int]error
+}
+
+func (e *UpsertError) Error() string {
+	indexes := make([]int, 0, len(e.Rows))
+	for i := range e.Rows {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "upsert failed for %d rows", len(indexes))
+	for n, i := range indexes {
+		if n == maxUpsertErrorsInMessage {
+			b.WriteString("; ...")
+			break
+		}
+		fmt.Fprintf(&b, "; row %d: %v", i, e.Rows[i])
+	}
+	return b.String()
+}
+
+func (e *UpsertError) Unwrap() error {
+	return e.Err
+}
+
+// UpsertMany - insert the rows into the table, the rows that conflict by conflictColumns update the other columns
+// (INSERT ... ON CONFLICT DO UPDATE). Small inputs are inserted with multi-row statements, large ones are loaded
+// with COPY into a temporary table and merged with one statement.
+// Must be executed in an active transaction. If any row fails, the changes of UpsertMany are rolled back
+// and UpsertError reports the failed rows. Rows with the same conflict key are reported before any statement.
+// The failed rows are found by checking halves of the input in savepoints: about 2*log2(len(rows)) statements
+// for each failed row. If the rows fail only together, the error of the bulk statement is returned as is.
+// Returns the number of inserted and updated rows
+func UpsertMany(tx *Tx, table string, columns []string, conflictColumns []string, rows [][]any) (int64, error) {
+	if tx.Level() == 0 {
+		return 0, nerr.New("no active transaction")
+	}
+	if len(columns) == 0 || len(conflictColumns) == 0 {
+		return 0, nerr.New("upsert requires columns and conflict columns")
+	}
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	rowErrs, err := upsertCheckRows(columns, conflictColumns, rows)
+	if err != nil {
+		return 0, err
+	}
+	if len(rowErrs) > 0 {
+		return 0, nerr.New(&UpsertError{Err: errors.New("invalid rows"), Rows: rowErrs})
+	}
+
+	var affected int64
+	err = tx.Run(func(tx *Tx) error {
+		var err error
+		if len(rows) >= upsertCopyThreshold {
+			affected, err = upsertCopy(tx, table, columns, conflictColumns, rows)
+		} else {
+			affected, err = upsertValues(tx, table, columns, conflictColumns, rows)
+		}
+		return err
+	})
+	if err == nil {
+		return affected, nil
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		// not a data error
+		return 0, err
+	}
+
+	// find the failed rows in savepoints that are rolled back
+	mid := len(rows) / 2
+	if checkErr := upsertFindFailed(tx, table, columns, conflictColumns, rows[:mid], 0, rowErrs); checkErr != nil {
+		return 0, checkErr
+	}
+	if checkErr := upsertFindFailed(tx, table, columns, conflictColumns, rows[mid:], mid, rowErrs); checkErr != nil {
+		return 0, checkErr
+	}
+	if len(rowErrs) == 0 {
+		// the rows fail only together
+		return 0, err
+	}
+	return 0, nerr.New(&UpsertError{Err: err, Rows: rowErrs})
+}
+
+// upsertFindFailed - rows that fail on their own. The rows are checked by halves, only the failed halves are split
+// further, so the number of savepoints grows with the number of failed rows, not with the number of all rows.
+// offset - index of the first row. Returns an error that is not caused by the data, e.g. a broken connection
+func upsertFindFailed(tx *Tx, table string, columns []string, conflictColumns []string, rows [][]any, offset int,
+	rowErrs map[int]error) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	checkErr := tx.Run(func(tx *Tx) error {
+		if _, err := upsertValues(tx, table, columns, conflictColumns, rows); err != nil {
+			return err
+		}
+		return errUpsertCheck
+	})
+	if errors.Is(checkErr, errUpsertCheck) {
+		return nil
+	}
+
+	var pgErr *pgconn.PgError
+	if !errors.As(checkErr, &pgErr) {
+		return checkErr
+	}
+	if len(rows) == 1 {
+		rowErrs[offset] = checkErr
+		return nil
+	}
+
+	mid := len(rows) / 2
+	if err := upsertFindFailed(tx, table, columns, conflictColumns, rows[:mid], offset, rowErrs); err != nil {
+		return err
+	}
+	return upsertFindFailed(tx, table, columns, conflictColumns, rows[mid:], offset+mid, rowErrs)
+}
+
+// upsertCheckRows - rows with the wrong number of values and rows with duplicate conflict keys:
+// ON CONFLICT DO UPDATE can't affect the same row twice in one statement
+func upsertCheckRows(columns []string, conflictColumns []string, rows [][]any) (map[int]error, error) {
+	keyIndexes := make([]int, 0, len(conflictColumns))
+	for _, cc := range conflictColumns {
+		pos := -1
+		for i, c := range columns {
+			if c == cc {
+				pos = i
+				break
+			}
+		}
+		if pos < 0 {
+			return nil, nerr.New(fmt.Errorf("conflict column %s is not in the columns", cc))
+		}
+		keyIndexes = append(keyIndexes, pos)
+	}
+
+	rowErrs := map[int]error{}
+	keys := make(map[string]int, len(rows))
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			rowErrs[i] = fmt.Errorf("%d values for %d columns", len(row), len(columns))
+			continue
+		}
+
+		key := make([]any, len(keyIndexes))
+		hasNull := false
+		for n, pos := range keyIndexes {
+			if key[n] = upsertKeyValue(row[pos]); key[n] == nil {
+				hasNull = true
+			}
+		}
+		if hasNull {
+			// NULL doesn't conflict
+			continue
+		}
+		k := fmt.Sprintf("%#v", key)
+		if first, ok := keys[k]; ok {
+			rowErrs[i] = fmt.Errorf("duplicate conflict key of row %d", first)
+			continue
+		}
+		keys[k] = i
+	}
+	return rowErrs, nil
+}
+
+// upsertKeyValue - value of the conflict key for the comparison: pointers and driver.Valuer are resolved,
+// numbers of different types and []byte and string with the same content are equal. nil - NULL
+func upsertKeyValue(v any) any {
+	if valuer, ok := v.(driver.Valuer); ok {
+		if value, err := valuer.Value(); err == nil {
+			v = value
+		}
+	}
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if u := rv.Uint(); u <= math.MaxInt64 {
+			return int64(u)
+		}
+		return rv.Uint()
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	case reflect.String:
+		return rv.String()
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return string(rv.Bytes())
+		}
+	}
+
+	if t, ok := rv.Interface().(time.Time); ok {
+		return t.UTC()
+	}
+	return rv.Interface()
+}
+
+// upsertValues - multi-row INSERT ... ON CONFLICT statements, split by the parameter limit
+func upsertValues(tx *Tx, table string, columns []string, conflictColumns []string, rows [][]any) (int64, error) {
+	prefix, suffix := upsertSql(table, columns, conflictColumns)
+	chunk := maxStatementParams / len(columns)
+
+	var affected int64
+	for start := 0; start < len(rows); start += chunk {
+		end := start + chunk
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		var b strings.Builder
+		args := make([]any, 0, (end-start)*len(columns))
+		b.WriteString(prefix)
+		b.WriteString(" VALUES ")
+		for i, row := range rows[start:end] {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteByte('(')
+			for j, v := range row {
+				if j > 0 {
+					b.WriteString(", ")
+				}
+				args = append(args, v)
+				fmt.Fprintf(&b, "$%d", len(args))
+			}
+			b.WriteByte(')')
+		}
+		b.WriteString(suffix)
+
+		q := NewQueryTx(tx, tx.ctx)
+		if err := q.ExecArgs(b.String(), args...); err != nil {
+			return affected, err
+		}
+		affected += q.RowsAffected()
+	}
+	return affected, nil
+}
+
+// upsertCopy - COPY into a temporary table and INSERT ... SELECT ... ON CONFLICT
+func upsertCopy(tx *Tx, table string, columns []string, conflictColumns []string, rows [][]any) (int64, error) {
+	tmp := fmt.Sprintf("sqlq_upsert_%d", atomic.AddUint64(&upsertCounter, 1))
+	cols := quoteColumns(columns)
+
+	// only the column types, without the constraints of the table
+	if err := NewQueryTx(tx, tx.ctx).Exec(fmt.Sprintf("CREATE TEMP TABLE %s ON COMMIT DROP AS SELECT %s FROM %s WITH NO DATA",
+		tmp, cols, quoteName(table))); err != nil {
+		return 0, err
+	}
+
+	if _, err := CopyFromSource(tx, tmp, columns, pgx.CopyFromRows(rows)); err != nil {
+		return 0, err
+	}
+
+	prefix, suffix := upsertSql(table, columns, conflictColumns)
+	q := NewQueryTx(tx, tx.ctx)
+	if err := q.Exec(fmt.Sprintf("%s SELECT %s FROM %s%s", prefix, cols, tmp, suffix)); err != nil {
+		return 0, err
+	}
+	affected := q.RowsAffected()
+
+	return affected, NewQueryTx(tx, tx.ctx).Exec("DROP TABLE " + tmp)
+}
+
+// upsertSql - INSERT INTO table (columns) and ON CONFLICT clause
+func upsertSql(table string, columns []string, conflictColumns []string) (string, string) {
+	prefix := fmt.Sprintf("INSERT INTO %s (%s)", quoteName(table), quoteColumns(columns))
+
+	conflict := make(map[string]bool, len(conflictColumns))
+	for _, c := range conflictColumns {
+		conflict[c] = true
+	}
+
+	var set []string
+	for _, c := range columns {
+		if !conflict[c] {
+			set = append(set, fmt.Sprintf("%s = EXCLUDED.%s", quoteIdent(c), quoteIdent(c)))
+		}
+	}
+
+	suffix := fmt.Sprintf(" ON CONFLICT (%s) DO NOTHING", quoteColumns(conflictColumns))
+	if len(set) > 0 {
+		suffix = fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s", quoteColumns(conflictColumns), strings.Join(set, ", "))
+	}
+	return prefix, suffix
+}
+
+// quoteColumns - comma separated quoted column names
+func quoteColumns(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = quoteIdent(c)
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,172 @@
+package sqlq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgtype"
+	"github.com/n-r-w/sqlq/sqlqtest"
+)
+
+// upsertServer - handler of the upsert statements of the table t, fails the statements with the values of fail
+type upsertServer struct {
+	fail func(args []any) bool
+
+	mu    sync.Mutex
+	execs int
+}
+
+func (s *upsertServer) handle(sql string, args []any) (sqlqtest.Result, bool) {
+	if !strings.HasPrefix(sql, `INSERT INTO "t" ("id", "name") VALUES `) {
+		return sqlqtest.Result{}, false
+	}
+	if args == nil {
+		params := make([]uint32, strings.Count(sql, "$"))
+		for i := range params {
+			params[i] = pgtype.Int8OID
+			if i%2 == 1 {
+				params[i] = pgtype.TextOID
+			}
+		}
+		return sqlqtest.Result{Params: params}, true
+	}
+
+	s.mu.Lock()
+	s.execs++
+	s.mu.Unlock()
+
+	if s.fail(args) {
+		return sqlqtest.Result{Err: &pgconn.PgError{Severity: "ERROR", Code: "23514", Message: "check constraint violation"}}, true
+	}
+	return sqlqtest.Result{Tag: fmt.Sprintf("INSERT 0 %d", len(args)/2)}, true
+}
+
+// executed - number of the executed upsert statements
+func (s *upsertServer) executed() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.execs
+}
+
+// upsertRows - n rows of (id, name), the rows of bad have the name "bad"
+func upsertRows(n int, bad ...int) [][]any {
+	rows := make([][]any, n)
+	for i := range rows {
+		rows[i] = []any{i, "ok"}
+	}
+	for _, i := range bad {
+		rows[i][1] = "bad"
+	}
+	return rows
+}
+
+func hasBadName(args []any) bool {
+	for _, a := range args {
+		if a == "bad" {
+			return true
+		}
+	}
+	return false
+}
+
+func TestUpsertManyRowErrors(t *testing.T) {
+	srv, pool := newTestPool(t)
+	us := &upsertServer{fail: hasBadName}
+	srv.Handle(us.handle)
+	srv.Expect("UPDATE t SET checked = true", sqlqtest.Result{Tag: "UPDATE 1"})
+
+	rows := upsertRows(64, 5, 40)
+	err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+		_, err := UpsertMany(tx, "t", []string{"id", "name"}, []string{"id"}, rows)
+
+		var upsertErr *UpsertError
+		if !errors.As(err, &upsertErr) {
+			return fmt.Errorf("unexpected error: %w", err)
+		}
+		var got []int
+		for i, rowErr := range upsertErr.Rows {
+			if SqlState(rowErr) != "23514" {
+				t.Errorf("row %d: unexpected error: %v", i, rowErr)
+			}
+			got = append(got, i)
+		}
+		sort.Ints(got)
+		if want := []int{5, 40}; !reflect.DeepEqual(got, want) {
+			t.Errorf("failed rows %v, want %v", got, want)
+		}
+
+		// the transaction is still usable
+		return NewQueryTx(tx, tx.ctx).Exec("UPDATE t SET checked = true")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the bulk statement and the halves, fewer statements than rows
+	if n := us.executed(); n >= len(rows) {
+		t.Errorf("%d statements for %d rows", n, len(rows))
+	}
+}
+
+func TestUpsertManyRowsFailTogether(t *testing.T) {
+	srv, pool := newTestPool(t)
+	// only the statements with several rows fail
+	us := &upsertServer{fail: func(args []any) bool { return len(args) > 2 }}
+	srv.Handle(us.handle)
+
+	err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+		_, err := UpsertMany(tx, "t", []string{"id", "name"}, []string{"id"}, upsertRows(4))
+		return err
+	})
+
+	var upsertErr *UpsertError
+	if errors.As(err, &upsertErr) || SqlState(err) != "23514" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestUpsertManyDuplicateKeys(t *testing.T) {
+	srv, pool := newTestPool(t)
+	us := &upsertServer{fail: hasBadName}
+	srv.Handle(us.handle)
+
+	one := 1
+	rows := [][]any{
+		{1, "a"},
+		{int64(2), "b"},
+		{&one, "c"},
+		{uint8(2), "d"},
+		{nil, "e"},
+		{nil, "f"},
+		{3},
+	}
+	err := RunInTx(pool, context.Background(), func(tx *Tx) error {
+		_, err := UpsertMany(tx, "t", []string{"id", "name"}, []string{"id"}, rows)
+		return err
+	})
+
+	var upsertErr *UpsertError
+	if !errors.As(err, &upsertErr) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []int
+	for i := range upsertErr.Rows {
+		got = append(got, i)
+	}
+	sort.Ints(got)
+	// NULL keys don't conflict
+	if want := []int{2, 3, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("failed rows %v, want %v", got, want)
+	}
+	if n := us.executed(); n != 0 {
+		t.Errorf("%d statements executed", n)
+	}
+}